@@ -0,0 +1,162 @@
+// Command fake-danegov is an in-memory stand-in for the resource endpoints
+// of the dane.gov.pl provider API, for trying out the publisher locally:
+//
+//	go run ./cmd/fake-danegov -token secret &
+//	DANEGOV_URL=http://localhost:8900 DANEGOV_TOKEN=secret go run ./cmd/server publish
+//
+// Resources can be inspected with GET /datasets/{id}/resources and
+// deleted with DELETE /resources/{id}; -fail makes a share of the write
+// requests fail to exercise retries.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type resource struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Dataset    string          `json:"-"`
+	Attributes json.RawMessage `json:"attributes"`
+	Modified   time.Time       `json:"-"`
+}
+
+type document struct {
+	Data resource `json:"data"`
+}
+
+type server struct {
+	token    string
+	datasets map[string]bool
+	failRate float64
+
+	mu        sync.Mutex
+	nextID    int
+	resources map[string]*resource
+}
+
+func main() {
+	addr := flag.String("addr", ":8900", "listen address")
+	token := flag.String("token", "", "required bearer token (any when empty)")
+	datasets := flag.String("datasets", "", "comma-separated dataset IDs that exist (any when empty)")
+	failRate := flag.Float64("fail", 0, "share of write requests answered with 503, 0..1")
+	flag.Parse()
+
+	s := &server{token: *token, failRate: *failRate, nextID: 1, resources: map[string]*resource{}}
+	if *datasets != "" {
+		s.datasets = map[string]bool{}
+		for _, id := range strings.Split(*datasets, ",") {
+			s.datasets[strings.TrimSpace(id)] = true
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /datasets/{id}/resources", s.create)
+	mux.HandleFunc("GET /datasets/{id}/resources", s.list)
+	mux.HandleFunc("PATCH /resources/{id}", s.update)
+	mux.HandleFunc("DELETE /resources/{id}", s.delete)
+
+	log.Printf("fake dane.gov.pl listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, s.logged(mux)))
+}
+
+func (s *server) logged(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" && r.Header.Get("Authorization") != "Bearer "+s.token {
+			writeError(w, http.StatusUnauthorized, "invalid token")
+			log.Printf("%s %s -> 401", r.Method, r.URL.Path)
+			return
+		}
+		if r.Method != http.MethodGet && rand.Float64() < s.failRate {
+			writeError(w, http.StatusServiceUnavailable, "temporarily unavailable")
+			log.Printf("%s %s -> 503 (injected)", r.Method, r.URL.Path)
+			return
+		}
+		log.Printf("%s %s", r.Method, r.URL.Path)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *server) create(w http.ResponseWriter, r *http.Request) {
+	dataset := r.PathValue("id")
+	if s.datasets != nil && !s.datasets[dataset] {
+		writeError(w, http.StatusNotFound, "dataset not found")
+		return
+	}
+	var doc document
+	if err := json.NewDecoder(r.Body).Decode(&doc); err != nil || doc.Data.Type != "resource" {
+		writeError(w, http.StatusBadRequest, "expected a resource document")
+		return
+	}
+
+	s.mu.Lock()
+	res := &resource{ID: strconv.Itoa(s.nextID), Type: "resource", Dataset: dataset, Attributes: doc.Data.Attributes, Modified: time.Now()}
+	s.nextID++
+	s.resources[res.ID] = res
+	s.mu.Unlock()
+	writeJSON(w, http.StatusCreated, document{Data: *res})
+}
+
+func (s *server) update(w http.ResponseWriter, r *http.Request) {
+	var doc document
+	if err := json.NewDecoder(r.Body).Decode(&doc); err != nil || doc.Data.Type != "resource" {
+		writeError(w, http.StatusBadRequest, "expected a resource document")
+		return
+	}
+
+	s.mu.Lock()
+	res, ok := s.resources[r.PathValue("id")]
+	if ok {
+		res.Attributes = doc.Data.Attributes
+		res.Modified = time.Now()
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "resource not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, document{Data: *res})
+}
+
+func (s *server) delete(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	_, ok := s.resources[r.PathValue("id")]
+	delete(s.resources, r.PathValue("id"))
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "resource not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) list(w http.ResponseWriter, r *http.Request) {
+	dataset := r.PathValue("id")
+	s.mu.Lock()
+	out := []resource{}
+	for _, res := range s.resources {
+		if res.Dataset == dataset {
+			out = append(out, *res)
+		}
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{"data": out})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/vnd.api+json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, detail string) {
+	writeJSON(w, status, map[string]any{"errors": []map[string]string{{"status": strconv.Itoa(status), "detail": detail}}})
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/config"
+	"github.com/kacperfilipiuk/zguba-gov/internal/database"
+	"github.com/kacperfilipiuk/zguba-gov/internal/directory"
+	"github.com/kacperfilipiuk/zguba-gov/internal/events"
+	"github.com/kacperfilipiuk/zguba-gov/internal/geo"
+	"github.com/kacperfilipiuk/zguba-gov/internal/importer"
+	"github.com/kacperfilipiuk/zguba-gov/internal/municipality"
+	"github.com/kacperfilipiuk/zguba-gov/internal/repository"
+	"github.com/kacperfilipiuk/zguba-gov/internal/webhook"
+)
+
+func runImport(cfg *config.Config, args []string) int {
+	fset := flag.NewFlagSet("import", flag.ExitOnError)
+	dryRun := fset.Bool("dry-run", false, "validate the file without storing anything")
+	format := fset.String("format", "", "input format: csv or xlsx (default: from file extension)")
+	mapArg := fset.String("map", "", `column mapping, e.g. "Nazwa=itemName,Gmina=municipalityName"`)
+	offices := fset.Bool("offices", false, "import office directory entries instead of found items, trusting their verified column")
+	fset.Usage = func() {
+		fmt.Fprintln(fset.Output(), "usage: zguba-gov import [flags] <file>")
+		fset.PrintDefaults()
+	}
+	_ = fset.Parse(args)
+
+	if fset.NArg() != 1 {
+		fset.Usage()
+		return 2
+	}
+	path := fset.Arg(0)
+
+	if *format == "" {
+		*format = importer.FormatFromFilename(path)
+	}
+	mapping, err := importer.ParseMapping(*mapArg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "import:", err)
+		return 2
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "import:", err)
+		return 1
+	}
+	defer func() { _ = f.Close() }()
+
+	var rows []importer.Row
+	var records [][]string
+	if *offices {
+		records, err = importer.ReadRecords(f, *format)
+	} else {
+		rows, err = importer.Read(f, *format, mapping)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "import:", err)
+		return 1
+	}
+
+	db, err := database.Open(cfg.DatabaseURL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "database:", err)
+		return 1
+	}
+	defer func() { _ = db.Close() }()
+
+	munSvc, err := municipality.NewService(cfg.SearchLimit)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "municipality service:", err)
+		return 1
+	}
+
+	// Imported items are announced to webhook subscribers; the running
+	// server delivers the queued deliveries.
+	eventRepo := repository.NewEventRepo(db)
+	bus := events.New(eventRepo)
+	bus.Subscribe(webhook.New(repository.NewWebhookRepo(db), eventRepo).Enqueue)
+
+	dir := directory.New(repository.NewOfficeRepo(db), munSvc)
+	gazetteer, err := geo.NewGazetteer(munSvc, cfg.GazetteerFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gazetteer:", err)
+		return 1
+	}
+	imp := importer.New(repository.NewFoundItemRepo(db, nil), repository.NewCategoryRepo(db), munSvc, dir, gazetteer, bus)
+	// The operator running the command may set up any office's entry,
+	// unlike offices importing over the API.
+	var result *importer.Result
+	if *offices {
+		result, err = imp.ImportOffices(records, *dryRun, nil)
+	} else {
+		result, err = imp.Import(rows, *dryRun)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "import:", err)
+		return 1
+	}
+
+	for _, e := range result.Errors {
+		if e.Field != "" {
+			fmt.Fprintf(os.Stderr, "row %d: %s: %s\n", e.Row, e.Field, e.Message)
+		} else {
+			fmt.Fprintf(os.Stderr, "row %d: %s\n", e.Row, e.Message)
+		}
+	}
+
+	switch {
+	case len(result.Errors) > 0:
+		fmt.Printf("%d rows read, %d errors, nothing imported\n", result.Rows, len(result.Errors))
+		return 1
+	case result.DryRun:
+		fmt.Printf("%d rows read, all valid (dry run, nothing imported)\n", result.Rows)
+	default:
+		fmt.Printf("%d rows imported\n", result.Imported)
+	}
+	return 0
+}
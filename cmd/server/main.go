@@ -1,60 +1,157 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"io/fs"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	zgubagov "github.com/kacperfilipiuk/zguba-gov"
 	"github.com/kacperfilipiuk/zguba-gov/internal/config"
 	"github.com/kacperfilipiuk/zguba-gov/internal/database"
+	"github.com/kacperfilipiuk/zguba-gov/internal/directory"
+	"github.com/kacperfilipiuk/zguba-gov/internal/events"
+	"github.com/kacperfilipiuk/zguba-gov/internal/form"
+	"github.com/kacperfilipiuk/zguba-gov/internal/geo"
 	"github.com/kacperfilipiuk/zguba-gov/internal/handler"
+	"github.com/kacperfilipiuk/zguba-gov/internal/importer"
 	"github.com/kacperfilipiuk/zguba-gov/internal/municipality"
+	"github.com/kacperfilipiuk/zguba-gov/internal/notify"
+	"github.com/kacperfilipiuk/zguba-gov/internal/publisher"
 	"github.com/kacperfilipiuk/zguba-gov/internal/repository"
+	"github.com/kacperfilipiuk/zguba-gov/internal/teryt"
+	"github.com/kacperfilipiuk/zguba-gov/internal/webhook"
 )
 
 func main() {
 	cfg := config.Load()
 
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImport(cfg, os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "office-token" {
+		os.Exit(runOfficeToken(cfg, os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "publish" {
+		os.Exit(runPublish(cfg, os.Args[2:]))
+	}
+
+	finderC, err := finderCipher(cfg)
+	if err != nil {
+		log.Fatal("finder key:", err)
+	}
+	if finderC == nil {
+		log.Printf("FINDER_KEY not set, finder data cannot be stored")
+	}
+
 	db, err := database.Open(cfg.DatabaseURL)
 	if err != nil {
 		log.Fatal("database:", err)
 	}
 	defer func() { _ = db.Close() }()
 
-	munSvc, err := municipality.NewService()
+	munSvc, err := municipality.NewService(cfg.SearchLimit)
 	if err != nil {
 		log.Fatal("municipality service:", err)
 	}
 
-	repo := repository.NewFoundItemRepo(db)
-	apiH := handler.NewAPIHandler(repo)
+	repo := repository.NewFoundItemRepo(db, finderC)
+	draftRepo := repository.NewDraftRepo(db)
+	categoryRepo := repository.NewCategoryRepo(db)
+	outboxRepo := repository.NewOutboxRepo(db)
+	officeNotifRepo := repository.NewOfficeNotificationsRepo(db)
+
+	transport, err := mailTransport(cfg)
+	if err != nil {
+		log.Fatal("mail transport:", err)
+	}
+	dir := directory.New(repository.NewOfficeRepo(db), munSvc)
+	gazetteer, err := geo.NewGazetteer(munSvc, cfg.GazetteerFile)
+	if err != nil {
+		log.Fatal("gazetteer:", err)
+	}
+	notifier, err := notify.New(transport, cfg.MailFrom, cfg.BaseURL, outboxRepo, officeNotifRepo, dir, repo, categoryRepo)
+	if err != nil {
+		log.Fatal("notifications:", err)
+	}
+
+	eventRepo := repository.NewEventRepo(db)
+	webhookRepo := repository.NewWebhookRepo(db)
+	webhooks := webhook.New(webhookRepo, eventRepo)
+	bus := events.New(eventRepo)
+	bus.Subscribe(webhooks.Enqueue)
+
+	apiH := handler.NewAPIHandler(repo, categoryRepo, notifier, bus, gazetteer)
+	webhookH := handler.NewWebhookHandler(webhookRepo, webhooks)
+	streamH := handler.NewStreamHandler(bus, eventRepo, categoryRepo)
+	feedH := handler.NewFeedHandler(repo, categoryRepo, cfg.BaseURL)
+	notificationH := handler.NewNotificationHandler(outboxRepo, officeNotifRepo)
+	categoryH := handler.NewCategoryHandler(categoryRepo)
+	finderH := handler.NewFinderHandler(repo, finderC)
 	odataH := handler.NewODataHandler(repo)
-	metaH := handler.NewMetadataHandler()
+	metaH := handler.NewMetadataHandler(repo, munSvc, cfg.BaseURL, cfg.CatalogPublisher, cfg.CatalogEmail)
+	imp := importer.New(repo, categoryRepo, munSvc, dir, gazetteer, bus)
+	importH := handler.NewImportHandler(imp)
+	receiptH := handler.NewReceiptHandler(repo, categoryRepo, munSvc, cfg.BaseURL)
+	publicationRepo := repository.NewPublicationRepo(db)
+	publicationH := handler.NewPublicationHandler(publicationRepo)
+	staleRepo := repository.NewStaleUnitRepo(db)
+	terytLoader := teryt.New(munSvc, repo, staleRepo, cfg.TerytFile, cfg.TerytReloadInterval)
+	if err := terytLoader.Load(); err != nil {
+		log.Printf("TERYT_FILE %s: %v; using the embedded territorial dataset", cfg.TerytFile, err)
+	}
+	territorialH := handler.NewTerritorialHandler(munSvc, terytLoader, staleRepo)
+	officeH := handler.NewOfficeHandler(dir, finderC, imp)
 
 	templateFS, err := fs.Sub(zgubagov.WebFS, "web")
 	if err != nil {
 		log.Fatal("template fs:", err)
 	}
 
-	pagesH, err := handler.NewPagesHandler(templateFS, repo, munSvc)
+	formDef, err := form.Load(cfg.FormDefinition)
+	if err != nil {
+		log.Fatal("form definition:", err)
+	}
+
+	pagesH, err := handler.NewPagesHandler(templateFS, repo, draftRepo, categoryRepo, munSvc, dir, gazetteer, notifier, bus, formDef, cfg.BaseURL,
+		handler.MapTiles{URL: cfg.MapTileURL, Attribution: cfg.MapAttribution, MaxZoom: cfg.MapMaxZoom})
 	if err != nil {
 		log.Fatal("pages handler:", err)
 	}
 
+	go purgeExpiredDrafts(draftRepo, cfg.DraftTTL)
+	go anonymiseFinders(repo, cfg.FinderRetention)
+	go notifier.Run(context.Background(), 30*time.Second, cfg.DeadlineNotice)
+	go webhooks.Run(context.Background(), 5*time.Second)
+	go purgeEvents(eventRepo, cfg.EventRetention)
+	go terytLoader.Run(context.Background())
+	if cfg.DaneGovURL != "" {
+		client := publisher.NewClient(cfg.DaneGovURL, cfg.DaneGovToken)
+		go publisher.New(client, publicationRepo, repo, cfg.BaseURL, cfg.PublishInterval).Run(context.Background())
+	} else {
+		log.Printf("DANEGOV_URL not set, datasets are not pushed to dane.gov.pl")
+	}
+
 	r := gin.Default()
 
 	r.Use(cors.New(cors.Config{
 		AllowOriginFunc:  func(_ string) bool { return true },
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
 		AllowCredentials: true,
 	}))
+	r.Use(handler.RequestID, handler.Locale)
 
 	staticFS, _ := fs.Sub(zgubagov.WebFS, "web/static")
 	r.StaticFS("/static", http.FS(staticFS))
+	if cfg.MapTilesDir != "" {
+		r.Static("/tiles", cfg.MapTilesDir)
+	}
 
 	// HTML pages
 	r.GET("/", pagesH.Index)
@@ -64,14 +161,71 @@ func main() {
 	r.POST("/submit", pagesH.Submit)
 	r.GET("/export/json", pagesH.ExportJSON)
 	r.GET("/export/csv", pagesH.ExportCSV)
+	r.GET("/items/:id/edit", pagesH.EditItem)
+	r.POST("/items/:id/status", pagesH.ChangeStatus)
+	r.GET("/items/:id/delete", pagesH.ConfirmDelete)
+	r.DELETE("/items/:id", pagesH.DeleteItem)
+	r.GET("/records", pagesH.Records)
+	r.GET("/drafts", pagesH.Drafts)
+	r.GET("/drafts/:id", pagesH.ResumeDraft)
+	r.DELETE("/drafts/:id", pagesH.DeleteDraft)
+
+	// Public pages
+	r.GET("/rzeczy", pagesH.ItemsPage)
+	r.GET("/rzeczy/mapa", pagesH.MapPage)
+	r.GET("/rzeczy/:id", pagesH.ItemPage)
+	r.POST("/rzeczy/:id/zgloszenie", pagesH.SubmitClaim)
+	r.GET("/sitemap.xml", pagesH.Sitemap)
+	r.GET("/sitemap/:page", pagesH.SitemapPage)
+	r.GET("/feeds/found-items.atom", feedH.Atom)
+	r.GET("/feeds/found-items.rss", feedH.RSS)
 
 	// REST API
 	r.GET("/api/found-items", apiH.ListItems)
 	r.POST("/api/found-items", apiH.CreateItem)
+	r.POST("/api/found-items/import", importH.Import)
+	r.GET("/api/found-items/export", apiH.ExportItems)
+	r.GET("/api/found-items.geojson", apiH.GeoJSON)
+	r.GET("/api/found-items/clusters.geojson", apiH.Clusters)
+	r.GET("/api/found-items/stream", streamH.Stream)
 	r.GET("/api/found-items/categories/list", apiH.CategoriesList)
 	r.GET("/api/found-items/:id", apiH.GetItem)
+	r.GET("/api/found-items/:id/receipt.pdf", receiptH.Receipt)
+	r.GET("/api/found-items/:id/finder", finderH.Get)
+	r.PUT("/api/found-items/:id/finder", finderH.Put)
+	r.DELETE("/api/found-items/:id/finder", finderH.Delete)
 	r.PUT("/api/found-items/:id", apiH.UpdateItem)
 	r.DELETE("/api/found-items/:id", apiH.DeleteItem)
+	r.GET("/api/categories", categoryH.List)
+	r.POST("/api/categories", categoryH.Create)
+	r.GET("/api/categories/:code", categoryH.Get)
+	r.PUT("/api/categories/:code", categoryH.Update)
+	r.DELETE("/api/categories/:code", categoryH.Delete)
+	r.GET("/api/notifications/outbox", notificationH.Outbox)
+	r.POST("/api/notifications/outbox/:id/retry", notificationH.Retry)
+	r.GET("/api/offices/:email/notifications", notificationH.GetSettings)
+	r.PUT("/api/offices/:email/notifications", notificationH.PutSettings)
+	r.GET("/api/territorial-units", territorialH.List)
+	r.GET("/api/territorial-units/dataset", territorialH.Dataset)
+	r.GET("/api/territorial-units/:teryt", territorialH.Get)
+	r.GET("/api/territorial-units/:teryt/children", territorialH.Children)
+	r.GET("/api/office-directory", officeH.List)
+	r.POST("/api/office-directory/import", officeH.Import)
+	r.GET("/api/office-directory/:teryt", officeH.Get)
+	r.PUT("/api/office-directory/:teryt", officeH.Put)
+	r.DELETE("/api/office-directory/:teryt", officeH.Delete)
+	r.GET("/api/publications", publicationH.List)
+	r.GET("/api/offices/:email/publication", publicationH.Get)
+	r.PUT("/api/offices/:email/publication", publicationH.Put)
+	r.DELETE("/api/offices/:email/publication", publicationH.Delete)
+	r.GET("/api/webhooks", webhookH.List)
+	r.POST("/api/webhooks", webhookH.Create)
+	r.GET("/api/webhooks/deliveries", webhookH.Deliveries)
+	r.POST("/api/webhooks/deliveries/:id/retry", webhookH.Retry)
+	r.GET("/api/webhooks/:id", webhookH.Get)
+	r.PUT("/api/webhooks/:id", webhookH.Update)
+	r.DELETE("/api/webhooks/:id", webhookH.Delete)
+	r.POST("/api/webhooks/:id/test", webhookH.Test)
 	r.GET("/api/stats", apiH.Stats)
 	r.GET("/health", apiH.Health)
 
@@ -81,10 +235,70 @@ func main() {
 
 	// Metadata
 	r.GET("/metadata", metaH.Catalog)
+	r.GET("/metadata/dataset/:id", metaH.Dataset)
 	r.GET("/metadata/distribution/:id", metaH.Distribution)
+	metaH.UseRoutes(r.Routes())
 
 	log.Printf("Starting server on :%s", cfg.Port)
 	if err := r.Run(":" + cfg.Port); err != nil {
 		log.Fatal("server:", err)
 	}
 }
+
+func purgeExpiredDrafts(drafts *repository.DraftRepo, ttl time.Duration) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		n, err := drafts.DeleteExpired(time.Now().Add(-ttl))
+		if err != nil {
+			log.Printf("purge drafts: %v", err)
+		} else if n > 0 {
+			log.Printf("purged %d expired drafts", n)
+		}
+		<-ticker.C
+	}
+}
+
+func anonymiseFinders(repo *repository.FoundItemRepo, retention time.Duration) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		n, err := repo.AnonymiseFinders(time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("anonymise finders: %v", err)
+		} else if n > 0 {
+			log.Printf("anonymised finder data of %d items", n)
+		}
+		<-ticker.C
+	}
+}
+
+func purgeEvents(events *repository.EventRepo, retention time.Duration) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		n, err := events.Purge(time.Now().Add(-retention))
+		if err != nil {
+			log.Printf("purge events: %v", err)
+		} else if n > 0 {
+			log.Printf("purged %d old events", n)
+		}
+		<-ticker.C
+	}
+}
+
+// mailTransport returns nil when notifications are disabled.
+func mailTransport(cfg *config.Config) (notify.Transport, error) {
+	switch cfg.MailTransport {
+	case "":
+		log.Printf("MAIL_TRANSPORT not set, e-mail notifications are disabled")
+		return nil, nil
+	case "smtp":
+		return &notify.SMTPTransport{Addr: cfg.SMTPAddr, Username: cfg.SMTPUsername, Password: cfg.SMTPPassword}, nil
+	case "file":
+		return &notify.FileTransport{Dir: cfg.MailDir}, nil
+	case "stdout":
+		return &notify.WriterTransport{W: os.Stdout}, nil
+	}
+	return nil, fmt.Errorf("unknown MAIL_TRANSPORT %q (want smtp, file or stdout)", cfg.MailTransport)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/config"
+	"github.com/kacperfilipiuk/zguba-gov/internal/finder"
+)
+
+// runOfficeToken prints the token an office uses to read finder data of
+// the items it registered.
+func runOfficeToken(cfg *config.Config, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: zguba-gov office-token <office e-mail>")
+		return 2
+	}
+	c, err := finderCipher(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "finder key:", err)
+		return 1
+	}
+	if c == nil {
+		fmt.Fprintln(os.Stderr, "office-token: FINDER_KEY is not set")
+		return 1
+	}
+	fmt.Println(c.OfficeToken(args[0]))
+	return 0
+}
+
+// finderCipher returns nil without error when no key is configured.
+func finderCipher(cfg *config.Config) (*finder.Cipher, error) {
+	if cfg.FinderKey == "" {
+		return nil, nil
+	}
+	key, err := finder.ParseKey(cfg.FinderKey)
+	if err != nil {
+		return nil, err
+	}
+	return finder.New(key)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/config"
+	"github.com/kacperfilipiuk/zguba-gov/internal/database"
+	"github.com/kacperfilipiuk/zguba-gov/internal/publisher"
+	"github.com/kacperfilipiuk/zguba-gov/internal/repository"
+)
+
+// runPublish pushes the office datasets to dane.gov.pl right away instead
+// of waiting for the server's schedule.
+func runPublish(cfg *config.Config, args []string) int {
+	fset := flag.NewFlagSet("publish", flag.ExitOnError)
+	office := fset.String("office", "", "only publish the dataset of this office e-mail")
+	all := fset.Bool("all", false, "also push resources whose items have not changed")
+	fset.Usage = func() {
+		fmt.Fprintln(fset.Output(), "usage: zguba-gov publish [flags]")
+		fset.PrintDefaults()
+	}
+	_ = fset.Parse(args)
+	if fset.NArg() != 0 {
+		fset.Usage()
+		return 2
+	}
+	if cfg.DaneGovURL == "" {
+		fmt.Fprintln(os.Stderr, "publish: DANEGOV_URL is not set")
+		return 1
+	}
+
+	db, err := database.Open(cfg.DatabaseURL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "database:", err)
+		return 1
+	}
+	defer func() { _ = db.Close() }()
+
+	pub := publisher.New(publisher.NewClient(cfg.DaneGovURL, cfg.DaneGovToken),
+		repository.NewPublicationRepo(db), repository.NewFoundItemRepo(db, nil), cfg.BaseURL, cfg.PublishInterval)
+	res, err := pub.Sync(context.Background(), time.Now(), publisher.Options{Office: *office, Now: true, All: *all})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "publish:", err)
+		return 1
+	}
+	fmt.Printf("%d resources pushed, %d failed\n", res.Pushed, res.Failed)
+	if res.Failed > 0 {
+		return 1
+	}
+	return 0
+}
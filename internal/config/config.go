@@ -1,18 +1,98 @@
 package config
 
-import "os"
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Config struct {
 	Port        string
 	DatabaseURL string
 	CORSOrigins string
+	BaseURL     string
+	DraftTTL    time.Duration
+	// FormDefinition is a JSON file describing the wizard steps. The
+	// embedded default is used when empty.
+	FormDefinition string
+	// FinderKey encrypts finder personal data (32 bytes, base64 or hex).
+	// Finder data cannot be stored when it is empty.
+	FinderKey       string
+	FinderRetention time.Duration
+	// MailTransport selects how notifications are sent: smtp, file or
+	// stdout. Notifications are disabled when it is empty.
+	MailTransport  string
+	MailFrom       string
+	MailDir        string
+	SMTPAddr       string
+	SMTPUsername   string
+	SMTPPassword   string
+	DeadlineNotice time.Duration
+	// EventRetention is how long item events are kept for stream resume
+	// and webhook redelivery.
+	EventRetention time.Duration
+	// CatalogPublisher and CatalogEmail identify the publisher and
+	// contact point in the DCAT-AP catalog.
+	CatalogPublisher string
+	CatalogEmail     string
+	// DaneGovURL is the base URL of the dane.gov.pl provider API. Pushing
+	// resources to dane.gov.pl is disabled when it is empty.
+	DaneGovURL      string
+	DaneGovToken    string
+	PublishInterval time.Duration
+	// SearchLimit caps territorial unit search results.
+	SearchLimit int
+	// TerytFile is a TERC export (XML or CSV) replacing the embedded
+	// territorial dataset. It is checked for changes every
+	// TerytReloadInterval.
+	TerytFile           string
+	TerytReloadInterval time.Duration
+	// GazetteerFile is a CSV file of place positions added to the
+	// built-in gazetteer used to geocode item locations.
+	GazetteerFile string
+	// MapTileURL is the {z}/{x}/{y} URL template of the public map's
+	// tiles. When MapTilesDir is set, tiles are served from it at /tiles,
+	// so the map works without access to a tile server.
+	MapTileURL     string
+	MapTilesDir    string
+	MapAttribution string
+	MapMaxZoom     int
 }
 
 func Load() *Config {
+	port := getEnv("PORT", "8000")
 	return &Config{
-		Port:        getEnv("PORT", "8000"),
-		DatabaseURL: getEnv("DATABASE_URL", "zguba_gov.db"),
-		CORSOrigins: getEnv("CORS_ORIGINS", "http://localhost:4200,http://localhost:3000"),
+		Port:                port,
+		DatabaseURL:         getEnv("DATABASE_URL", "zguba_gov.db"),
+		CORSOrigins:         getEnv("CORS_ORIGINS", "http://localhost:4200,http://localhost:3000"),
+		BaseURL:             strings.TrimSuffix(getEnv("BASE_URL", "http://localhost:"+port), "/"),
+		DraftTTL:            getDuration("DRAFT_TTL", 72*time.Hour),
+		FormDefinition:      os.Getenv("FORM_DEFINITION"),
+		FinderKey:           os.Getenv("FINDER_KEY"),
+		FinderRetention:     getDuration("FINDER_RETENTION", 3*365*24*time.Hour),
+		MailTransport:       os.Getenv("MAIL_TRANSPORT"),
+		MailFrom:            getEnv("MAIL_FROM", "Portal Rzeczy Znalezionych <no-reply@localhost>"),
+		MailDir:             getEnv("MAIL_DIR", "mail"),
+		SMTPAddr:            getEnv("SMTP_ADDR", "localhost:25"),
+		SMTPUsername:        os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:        os.Getenv("SMTP_PASSWORD"),
+		DeadlineNotice:      getDuration("DEADLINE_NOTICE", 72*time.Hour),
+		EventRetention:      getDuration("EVENT_RETENTION", 7*24*time.Hour),
+		CatalogPublisher:    getEnv("CATALOG_PUBLISHER", "Portal Rzeczy Znalezionych"),
+		CatalogEmail:        os.Getenv("CATALOG_EMAIL"),
+		DaneGovURL:          os.Getenv("DANEGOV_URL"),
+		DaneGovToken:        os.Getenv("DANEGOV_TOKEN"),
+		PublishInterval:     getDuration("PUBLISH_INTERVAL", time.Hour),
+		SearchLimit:         getInt("SEARCH_LIMIT", 20),
+		TerytFile:           os.Getenv("TERYT_FILE"),
+		TerytReloadInterval: getDuration("TERYT_RELOAD_INTERVAL", time.Minute),
+		GazetteerFile:       os.Getenv("GAZETTEER_FILE"),
+		MapTileURL:          getEnv("MAP_TILE_URL", "/tiles/{z}/{x}/{y}.png"),
+		MapTilesDir:         os.Getenv("MAP_TILES_DIR"),
+		MapAttribution:      getEnv("MAP_ATTRIBUTION", "© autorzy OpenStreetMap"),
+		MapMaxZoom:          getInt("MAP_MAX_ZOOM", 18),
 	}
 }
 
@@ -22,3 +102,29 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+func getInt(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		log.Printf("config: invalid %s %q, using %d", key, v, fallback)
+		return fallback
+	}
+	return n
+}
+
+func getDuration(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("config: invalid %s %q, using %s", key, v, fallback)
+		return fallback
+	}
+	return d
+}
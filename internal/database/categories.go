@@ -0,0 +1,176 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+)
+
+// defaultCategories seeds an empty taxonomy with the categories the wizard
+// used to offer, plus the electronics parent for phones.
+var defaultCategories = []struct {
+	code, parent, pl, en, uk string
+}{
+	{"dokumenty", "", "Dokumenty", "Documents", "Документи"},
+	{"elektronika", "", "Elektronika", "Electronics", "Електроніка"},
+	{"telefony", "elektronika", "Telefony", "Phones", "Телефони"},
+	{"odziez", "", "Odzież", "Clothing", "Одяг"},
+	{"klucze", "", "Klucze", "Keys", "Ключі"},
+	{"bizuteria", "", "Biżuteria", "Jewellery", "Прикраси"},
+	{"inne", "", "Inne", "Other", "Інше"},
+}
+
+// migrateCategories seeds the taxonomy on first run and rewrites free-text
+// categories on existing items to taxonomy codes. Values with no matching
+// code become new top-level categories labelled with the original text.
+// Seeded categories from before Ukrainian labels existed get theirs.
+func migrateCategories(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var n int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM categories").Scan(&n); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	if n == 0 {
+		for i, c := range defaultCategories {
+			if _, err := tx.Exec(
+				"INSERT INTO categories (code, parent_code, label_pl, label_en, label_uk, sort_order, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+				c.code, nullIfEmpty(c.parent), c.pl, c.en, c.uk, i, now, now,
+			); err != nil {
+				return fmt.Errorf("seed category %s: %w", c.code, err)
+			}
+		}
+	}
+	for _, c := range defaultCategories {
+		if _, err := tx.Exec(
+			"UPDATE categories SET label_uk = ? WHERE code = ? AND label_pl = ? AND label_uk = ''",
+			c.uk, c.code, c.pl,
+		); err != nil {
+			return fmt.Errorf("label category %s: %w", c.code, err)
+		}
+	}
+
+	known := map[string]bool{}
+	rows, err := tx.Query("SELECT code FROM categories")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		known[code] = true
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// resolve maps a legacy value to a code, creating the category if needed.
+	resolve := func(value string) (string, error) {
+		if known[value] {
+			return value, nil
+		}
+		code := model.CategoryCode(value)
+		if code == "" {
+			code = "inne"
+		}
+		if !known[code] {
+			label := model.Capitalize(value)
+			if label == "" {
+				label = "Inne"
+			}
+			if _, err := tx.Exec(
+				"INSERT INTO categories (code, label_pl, sort_order, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+				code, label, len(known), now, now,
+			); err != nil {
+				return "", fmt.Errorf("create category %s: %w", code, err)
+			}
+			known[code] = true
+		}
+		return code, nil
+	}
+
+	type legacyItem struct {
+		id, category string
+		categories   sql.NullString
+	}
+	var items []legacyItem
+	rows, err = tx.Query(`
+		SELECT id, item_category, categories FROM found_items
+		WHERE item_category NOT IN (SELECT code FROM categories)
+		   OR (json_valid(categories) AND EXISTS (
+				SELECT 1 FROM json_each(categories)
+				WHERE json_each.value NOT IN (SELECT code FROM categories)))`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var it legacyItem
+		if err := rows.Scan(&it.id, &it.category, &it.categories); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		items = append(items, it)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, it := range items {
+		changed := false
+		category, err := resolve(it.category)
+		if err != nil {
+			return err
+		}
+		changed = category != it.category
+
+		categories := it.categories
+		if it.categories.Valid && it.categories.String != "" {
+			var values []string
+			if err := json.Unmarshal([]byte(it.categories.String), &values); err == nil {
+				for i, v := range values {
+					code, err := resolve(v)
+					if err != nil {
+						return err
+					}
+					if code != v {
+						values[i] = code
+						changed = true
+					}
+				}
+				b, _ := json.Marshal(values)
+				categories.String = string(b)
+			}
+		}
+
+		if changed {
+			if _, err := tx.Exec(
+				"UPDATE found_items SET item_category = ?, categories = ? WHERE id = ?",
+				category, categories, it.id,
+			); err != nil {
+				return fmt.Errorf("migrate categories of %s: %w", it.id, err)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -30,7 +30,7 @@ func Open(dsn string) (*sql.DB, error) {
 }
 
 func migrate(db *sql.DB) error {
-	_, err := db.Exec(`
+	if _, err := db.Exec(`
 		CREATE TABLE IF NOT EXISTS found_items (
 			id              TEXT PRIMARY KEY,
 			municipality_name  TEXT NOT NULL,
@@ -55,6 +55,177 @@ func migrate(db *sql.DB) error {
 		CREATE INDEX IF NOT EXISTS idx_found_items_category ON found_items(item_category);
 		CREATE INDEX IF NOT EXISTS idx_found_items_name ON found_items(item_name);
 		CREATE INDEX IF NOT EXISTS idx_found_items_created ON found_items(created_at);
-	`)
+
+		CREATE TABLE IF NOT EXISTS drafts (
+			id          TEXT PRIMARY KEY,
+			session_id  TEXT NOT NULL,
+			step        INTEGER NOT NULL DEFAULT 1,
+			fields      TEXT NOT NULL,
+			created_at  DATETIME NOT NULL DEFAULT (datetime('now')),
+			updated_at  DATETIME NOT NULL DEFAULT (datetime('now'))
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_drafts_session ON drafts(session_id);
+		CREATE INDEX IF NOT EXISTS idx_drafts_updated ON drafts(updated_at);
+
+		CREATE TABLE IF NOT EXISTS categories (
+			code        TEXT PRIMARY KEY,
+			parent_code TEXT REFERENCES categories(code),
+			label_pl    TEXT NOT NULL,
+			label_en    TEXT NOT NULL DEFAULT '',
+			sort_order  INTEGER NOT NULL DEFAULT 0,
+			created_at  DATETIME NOT NULL DEFAULT (datetime('now')),
+			updated_at  DATETIME NOT NULL DEFAULT (datetime('now'))
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_categories_parent ON categories(parent_code);
+
+		CREATE TABLE IF NOT EXISTS outbox (
+			id              INTEGER PRIMARY KEY AUTOINCREMENT,
+			kind            TEXT NOT NULL,
+			dedupe_key      TEXT UNIQUE,
+			recipient       TEXT NOT NULL,
+			reply_to        TEXT,
+			subject         TEXT NOT NULL,
+			body            TEXT NOT NULL,
+			status          TEXT NOT NULL DEFAULT 'pending',
+			attempts        INTEGER NOT NULL DEFAULT 0,
+			next_attempt_at DATETIME NOT NULL,
+			last_error      TEXT,
+			created_at      DATETIME NOT NULL DEFAULT (datetime('now')),
+			sent_at         DATETIME
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_outbox_due ON outbox(status, next_attempt_at);
+
+		CREATE TABLE IF NOT EXISTS office_notifications (
+			email                TEXT PRIMARY KEY,
+			item_registered      INTEGER NOT NULL DEFAULT 0,
+			claim_submitted      INTEGER NOT NULL DEFAULT 0,
+			deadline_approaching INTEGER NOT NULL DEFAULT 0,
+			updated_at           DATETIME NOT NULL DEFAULT (datetime('now'))
+		);
+
+		CREATE TABLE IF NOT EXISTS events (
+			id              INTEGER PRIMARY KEY AUTOINCREMENT,
+			type            TEXT NOT NULL,
+			item_id         TEXT NOT NULL,
+			previous_status TEXT,
+			data            TEXT NOT NULL,
+			created_at      DATETIME NOT NULL DEFAULT (datetime('now'))
+		);
+
+		CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+			id          TEXT PRIMARY KEY,
+			url         TEXT NOT NULL,
+			secret      TEXT NOT NULL,
+			events      TEXT NOT NULL,
+			description TEXT,
+			active      INTEGER NOT NULL DEFAULT 1,
+			created_at  DATETIME NOT NULL DEFAULT (datetime('now')),
+			updated_at  DATETIME NOT NULL DEFAULT (datetime('now'))
+		);
+
+		CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id              INTEGER PRIMARY KEY AUTOINCREMENT,
+			subscription_id TEXT NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+			event_id        INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+			status          TEXT NOT NULL DEFAULT 'pending',
+			attempts        INTEGER NOT NULL DEFAULT 0,
+			next_attempt_at DATETIME NOT NULL,
+			last_error      TEXT,
+			response_status INTEGER,
+			created_at      DATETIME NOT NULL DEFAULT (datetime('now')),
+			delivered_at    DATETIME
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+		CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription ON webhook_deliveries(subscription_id);
+		CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_event ON webhook_deliveries(event_id);
+
+		CREATE TABLE IF NOT EXISTS publications (
+			office      TEXT PRIMARY KEY,
+			dataset_id  TEXT NOT NULL,
+			created_at  DATETIME NOT NULL DEFAULT (datetime('now')),
+			updated_at  DATETIME NOT NULL DEFAULT (datetime('now'))
+		);
+
+		CREATE TABLE IF NOT EXISTS published_resources (
+			office          TEXT NOT NULL REFERENCES publications(office) ON DELETE CASCADE,
+			kind            TEXT NOT NULL,
+			resource_id     TEXT,
+			synced_modified DATETIME,
+			synced_at       DATETIME,
+			attempts        INTEGER NOT NULL DEFAULT 0,
+			next_attempt_at DATETIME NOT NULL,
+			last_error      TEXT,
+			PRIMARY KEY (office, kind)
+		);
+
+		CREATE TABLE IF NOT EXISTS stale_units (
+			municipality_name  TEXT NOT NULL,
+			municipality_type  TEXT NOT NULL,
+			municipality_email TEXT NOT NULL,
+			dataset_version    TEXT NOT NULL,
+			flagged_at         DATETIME NOT NULL DEFAULT (datetime('now')),
+			PRIMARY KEY (municipality_name, municipality_type, municipality_email)
+		);
+
+		CREATE TABLE IF NOT EXISTS offices (
+			teryt          TEXT PRIMARY KEY,
+			name           TEXT NOT NULL DEFAULT '',
+			email          TEXT NOT NULL DEFAULT '',
+			email_verified INTEGER NOT NULL DEFAULT 0,
+			epuap          TEXT NOT NULL DEFAULT '',
+			phone          TEXT NOT NULL DEFAULT '',
+			address        TEXT NOT NULL DEFAULT '',
+			hours          TEXT NOT NULL DEFAULT '',
+			updated_at     DATETIME NOT NULL DEFAULT (datetime('now'))
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_offices_email ON offices(LOWER(email));
+	`); err != nil {
+		return err
+	}
+
+	// Columns added after the initial schema.
+	for _, col := range []struct{ name, decl string }{
+		{"attributes", "TEXT"},
+		{"finder", "TEXT"},
+		{"finder_anonymised_at", "DATETIME"},
+		{"item_lat", "REAL"},
+		{"item_lon", "REAL"},
+		{"pickup_lat", "REAL"},
+		{"pickup_lon", "REAL"},
+		{"registered_by", "TEXT"},
+	} {
+		if err := addColumn(db, "found_items", col.name, col.decl); err != nil {
+			return err
+		}
+	}
+	// Items registered before registered_by existed belong to the office
+	// they name.
+	if _, err := db.Exec("UPDATE found_items SET registered_by = municipality_email WHERE registered_by IS NULL"); err != nil {
+		return err
+	}
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_found_items_item_geo ON found_items(item_lat, item_lon)"); err != nil {
+		return err
+	}
+	if err := addColumn(db, "categories", "label_uk", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+
+	return migrateCategories(db)
+}
+
+func addColumn(db *sql.DB, table, column, decl string) error {
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	_, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, decl))
 	return err
 }
@@ -0,0 +1,188 @@
+// Package directory resolves how to reach the office of a territorial
+// unit. Offices keep their contact details in the office directory; units
+// without an entry fall back to the address of the territorial dataset and,
+// as a last resort, to an address guessed from the unit name. Guessed
+// addresses are marked unverified and never receive mail.
+package directory
+
+import (
+	"net/mail"
+	"strings"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/i18n"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+	"github.com/kacperfilipiuk/zguba-gov/internal/municipality"
+	"github.com/kacperfilipiuk/zguba-gov/internal/repository"
+)
+
+// ErrUnknownUnit is returned for TERYT codes that are not in the
+// territorial dataset.
+var ErrUnknownUnit = i18n.NewError("directory.unknown_unit")
+
+type Directory struct {
+	repo   *repository.OfficeRepo
+	munSvc *municipality.Service
+}
+
+func New(repo *repository.OfficeRepo, munSvc *municipality.Service) *Directory {
+	return &Directory{repo: repo, munSvc: munSvc}
+}
+
+// Contact returns the contact of the unit's office. Fields missing from
+// the directory entry are filled in from the territorial dataset.
+func (d *Directory) Contact(unit municipality.TerritorialUnit) (model.OfficeContact, error) {
+	entry, err := d.repo.Get(string(unit.ID))
+	if err != nil {
+		return model.OfficeContact{}, err
+	}
+	c := d.fallback(unit)
+	if entry == nil {
+		return c, nil
+	}
+	if entry.Name == "" {
+		entry.Name = c.Name
+	}
+	if entry.Email == "" && c.Source == model.ContactDataset {
+		entry.Email, entry.EmailVerified = c.Email, true
+	}
+	return *entry, nil
+}
+
+// fallback is the contact of a unit without a directory entry.
+func (d *Directory) fallback(unit municipality.TerritorialUnit) model.OfficeContact {
+	c := model.OfficeContact{Teryt: string(unit.ID), Name: strings.TrimSpace(unit.OfficeName)}
+	if c.Name == "" {
+		c.Name = strings.TrimSpace(unit.Name)
+	}
+	if email := firstAddress(unit.Email); email != "" {
+		c.Email, c.EmailVerified, c.Source = email, true, model.ContactDataset
+		return c
+	}
+	unit.Email = ""
+	c.Email, c.Source = d.munSvc.GenerateEmail(unit), model.ContactGuessed
+	return c
+}
+
+// firstAddress returns the first valid address of a dataset e-mail field,
+// which sometimes lists several.
+func firstAddress(s string) string {
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' || r == ' ' }) {
+		if a, err := mail.ParseAddress(f); err == nil {
+			return strings.ToLower(a.Address)
+		}
+	}
+	return ""
+}
+
+// Lookup returns the contact of the unit with the given TERYT code.
+func (d *Directory) Lookup(teryt string) (model.OfficeContact, error) {
+	unit, ok := d.munSvc.Get(teryt)
+	if !ok {
+		return model.OfficeContact{}, ErrUnknownUnit
+	}
+	return d.Contact(unit)
+}
+
+// List returns the contacts of the given units in their order.
+func (d *Directory) List(units []municipality.TerritorialUnit) ([]model.OfficeContact, error) {
+	out := make([]model.OfficeContact, 0, len(units))
+	for _, u := range units {
+		c, err := d.Contact(u)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// Entries returns the directory entries, with names missing from an entry
+// filled in from the territorial dataset.
+func (d *Directory) Entries() ([]model.OfficeContact, error) {
+	entries, err := d.repo.List()
+	if err != nil {
+		return nil, err
+	}
+	for i, e := range entries {
+		if unit, ok := d.munSvc.Get(e.Teryt); ok && e.Name == "" {
+			entries[i].Name = d.fallback(unit).Name
+		}
+	}
+	return entries, nil
+}
+
+// Save replaces the directory entry of a unit.
+func (d *Directory) Save(teryt string, s model.OfficeContactSave) (model.OfficeContact, error) {
+	if _, ok := d.munSvc.Get(teryt); !ok {
+		return model.OfficeContact{}, ErrUnknownUnit
+	}
+	c := Entry(teryt, s)
+	if err := d.repo.Save(c); err != nil {
+		return model.OfficeContact{}, err
+	}
+	return d.Lookup(teryt)
+}
+
+// Delete removes the directory entry of a unit, which falls back to the
+// territorial dataset again.
+func (d *Directory) Delete(teryt string) error {
+	return d.repo.Delete(teryt)
+}
+
+// SaveAll creates or replaces directory entries in one transaction.
+func (d *Directory) SaveAll(offices ...model.OfficeContact) error {
+	return d.repo.Save(offices...)
+}
+
+// Entry builds the directory entry of a unit from the submitted fields.
+func Entry(teryt string, s model.OfficeContactSave) model.OfficeContact {
+	c := model.OfficeContact{
+		Teryt:         teryt,
+		Name:          strings.TrimSpace(s.Name),
+		Email:         strings.ToLower(strings.TrimSpace(s.Email)),
+		EmailVerified: s.EmailVerified,
+		EPUAP:         strings.TrimSpace(s.EPUAP),
+		Phone:         strings.TrimSpace(s.Phone),
+		Address:       strings.TrimSpace(s.Address),
+		Hours:         strings.TrimSpace(s.Hours),
+		Source:        model.ContactDirectory,
+	}
+	if c.Email == "" {
+		c.EmailVerified = false
+	}
+	return c
+}
+
+// Sendable reports whether mail may be sent to email. Addresses marked
+// unverified in the directory and addresses guessed from unit names are
+// refused; other addresses, entered by the clerk registering an item, are
+// accepted.
+func (d *Directory) Sendable(email string) (bool, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if _, err := mail.ParseAddress(email); err != nil {
+		return false, nil
+	}
+	entries, err := d.repo.ByEmail(email)
+	if err != nil {
+		return false, err
+	}
+	if len(entries) > 0 {
+		for _, e := range entries {
+			if e.EmailVerified {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return !d.munSvc.IsGuessedEmail(email), nil
+}
+
+// VerifiedEmail returns the unit's office address if it may be used for
+// sending, or "" when only a guessed or unverified one is known.
+func (d *Directory) VerifiedEmail(unit municipality.TerritorialUnit) (string, error) {
+	c, err := d.Contact(unit)
+	if err != nil || !c.EmailVerified {
+		return "", err
+	}
+	return c.Email, nil
+}
@@ -0,0 +1,109 @@
+// Package events records changes to found items and passes them on to
+// in-process listeners such as the webhook dispatcher and the SSE stream.
+package events
+
+import (
+	"log"
+	"sync"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+	"github.com/kacperfilipiuk/zguba-gov/internal/repository"
+)
+
+type Listener func(model.Event)
+
+type Bus struct {
+	repo *repository.EventRepo
+
+	// pub serialises publish so listeners and streams see events in the
+	// order of their IDs.
+	pub sync.Mutex
+
+	mu        sync.RWMutex
+	listeners []Listener
+	streams   map[chan model.Event]struct{}
+}
+
+func New(repo *repository.EventRepo) *Bus {
+	return &Bus{repo: repo, streams: map[chan model.Event]struct{}{}}
+}
+
+// Subscribe registers fn for every event published after the call.
+// Listeners run synchronously in the publishing goroutine, so they must
+// not block.
+func (b *Bus) Subscribe(fn Listener) {
+	b.mu.Lock()
+	b.listeners = append(b.listeners, fn)
+	b.mu.Unlock()
+}
+
+// Listen returns a channel receiving every event published from now on and
+// a function that stops the subscription. A consumer that falls more than
+// buffer events behind is dropped and its channel closed; it can catch up
+// from the event log.
+func (b *Bus) Listen(buffer int) (<-chan model.Event, func()) {
+	ch := make(chan model.Event, buffer)
+	b.mu.Lock()
+	b.streams[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch, func() { b.drop(ch) }
+}
+
+func (b *Bus) drop(ch chan model.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.streams[ch]; ok {
+		delete(b.streams, ch)
+		close(ch)
+	}
+}
+
+func (b *Bus) ItemCreated(item model.FoundItemResponse) {
+	b.publish(model.EventItemCreated, item, "")
+}
+
+// ItemUpdated publishes an update of item, followed by a status change
+// when its status differs from previousStatus.
+func (b *Bus) ItemUpdated(item model.FoundItemResponse, previousStatus string) {
+	b.publish(model.EventItemUpdated, item, "")
+	if previousStatus != "" && previousStatus != item.Item.Status {
+		b.publish(model.EventItemStatusChanged, item, previousStatus)
+	}
+}
+
+// ItemDeleted publishes the deletion of item, given in its last state.
+func (b *Bus) ItemDeleted(item model.FoundItemResponse) {
+	b.publish(model.EventItemDeleted, item, "")
+}
+
+// publish records the event and notifies listeners. Failures are logged
+// rather than returned: the item change itself has already succeeded.
+func (b *Bus) publish(typ string, item model.FoundItemResponse, previousStatus string) {
+	b.pub.Lock()
+	defer b.pub.Unlock()
+
+	e := model.Event{Type: typ, ItemID: item.ID, PreviousStatus: previousStatus, Data: &item}
+	if err := b.repo.Append(&e); err != nil {
+		log.Printf("events: %s %s: %v", typ, item.ID, err)
+		return
+	}
+
+	b.mu.RLock()
+	listeners := b.listeners
+	var slow []chan model.Event
+	for ch := range b.streams {
+		select {
+		case ch <- e:
+		default:
+			slow = append(slow, ch)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, ch := range slow {
+		b.drop(ch)
+	}
+	for _, fn := range listeners {
+		fn(e)
+	}
+}
@@ -0,0 +1,289 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+	"github.com/parquet-go/parquet-go"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	FormatCSV     = "csv"
+	FormatXLSX    = "xlsx"
+	FormatJSONL   = "jsonl"
+	FormatParquet = "parquet"
+)
+
+var Formats = []string{FormatCSV, FormatXLSX, FormatJSONL, FormatParquet}
+
+var mediaTypes = map[string]string{
+	FormatCSV:     "text/csv; charset=utf-8",
+	FormatXLSX:    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	FormatJSONL:   "application/jsonl",
+	FormatParquet: "application/vnd.apache.parquet",
+}
+
+func MediaType(format string) string {
+	return mediaTypes[format]
+}
+
+// Writer serialises found items one at a time. Close must be called to
+// flush buffered output; it does not close the underlying io.Writer.
+type Writer interface {
+	Write(item model.FoundItemResponse) error
+	Close() error
+}
+
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatXLSX:
+		return newXLSXWriter(w)
+	case FormatJSONL:
+		return &jsonlWriter{w: bufio.NewWriter(w)}, nil
+	case FormatParquet:
+		return &parquetWriter{w: parquet.NewGenericWriter[parquetRow](w, parquet.MaxRowsPerRowGroup(10000))}, nil
+	}
+	return nil, fmt.Errorf("unsupported format: %q", format)
+}
+
+type column struct {
+	header string
+	value  func(model.FoundItemResponse) string
+}
+
+// columns uses the same Polish headers as the wizard CSV export so files can
+// be fed back to the importer.
+var columns = []column{
+	{"ID", func(r model.FoundItemResponse) string { return r.ID }},
+	{"Samorząd", func(r model.FoundItemResponse) string { return r.Municipality.Name }},
+	{"Typ samorządu", func(r model.FoundItemResponse) string { return r.Municipality.Type }},
+	{"Email kontaktowy", func(r model.FoundItemResponse) string { return r.Municipality.ContactEmail }},
+	{"Nazwa przedmiotu", func(r model.FoundItemResponse) string { return r.Item.Name }},
+	{"Kategoria", func(r model.FoundItemResponse) string { return r.Item.Category }},
+	{"Data znalezienia", func(r model.FoundItemResponse) string { return r.Item.Date }},
+	{"Miejsce znalezienia", func(r model.FoundItemResponse) string { return r.Item.Location }},
+	{"Status", func(r model.FoundItemResponse) string { return r.Item.Status }},
+	{"Opis", func(r model.FoundItemResponse) string { return r.Item.Description }},
+	{"Termin przechowania (dni)", func(r model.FoundItemResponse) string { return strconv.Itoa(r.Pickup.Deadline) }},
+	{"Miejsce odbioru", func(r model.FoundItemResponse) string { return r.Pickup.Location }},
+	{"Godziny odbioru", func(r model.FoundItemResponse) string { return r.Pickup.Hours }},
+	{"Osoba kontaktowa", func(r model.FoundItemResponse) string { return r.Pickup.Contact }},
+	{"Kategorie", func(r model.FoundItemResponse) string { return strings.Join(r.Categories, ", ") }},
+	{"Atrybuty", attributesJSON},
+	{"Szerokość miejsca znalezienia", func(r model.FoundItemResponse) string { return coordinate(r.Item.Geo, lat) }},
+	{"Długość miejsca znalezienia", func(r model.FoundItemResponse) string { return coordinate(r.Item.Geo, lon) }},
+	{"Szerokość miejsca odbioru", func(r model.FoundItemResponse) string { return coordinate(r.Pickup.Geo, lat) }},
+	{"Długość miejsca odbioru", func(r model.FoundItemResponse) string { return coordinate(r.Pickup.Geo, lon) }},
+	{"Utworzono", func(r model.FoundItemResponse) string { return r.CreatedAt }},
+	{"Zaktualizowano", func(r model.FoundItemResponse) string { return r.UpdatedAt }},
+}
+
+// attributesJSON flattens the custom wizard fields into one JSON cell, as
+// their set differs between offices.
+func attributesJSON(r model.FoundItemResponse) string {
+	if len(r.Attributes) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(r.Attributes)
+	return string(b)
+}
+
+func lat(p *model.GeoPoint) float64 { return p.Lat }
+func lon(p *model.GeoPoint) float64 { return p.Lon }
+
+// coordinate formats one coordinate of a position, or "" when there is
+// none.
+func coordinate(p *model.GeoPoint, part func(*model.GeoPoint) float64) string {
+	if p == nil {
+		return ""
+	}
+	return strconv.FormatFloat(part(p), 'f', -1, 64)
+}
+
+// coordinatePtr is like coordinate for the optional Parquet columns.
+func coordinatePtr(p *model.GeoPoint, part func(*model.GeoPoint) float64) *float64 {
+	if p == nil {
+		return nil
+	}
+	v := part(p)
+	return &v
+}
+
+func headers() []string {
+	h := make([]string, len(columns))
+	for i, c := range columns {
+		h[i] = c.header
+	}
+	return h
+}
+
+func values(item model.FoundItemResponse) []string {
+	v := make([]string, len(columns))
+	for i, c := range columns {
+		v[i] = c.value(item)
+	}
+	return v
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	if _, err := w.Write([]byte{0xEF, 0xBB, 0xBF}); err != nil { // UTF-8 BOM
+		return nil, err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(headers()); err != nil {
+		return nil, err
+	}
+	return &csvWriter{w: cw}, nil
+}
+
+func (cw *csvWriter) Write(item model.FoundItemResponse) error {
+	return cw.w.Write(values(item))
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type jsonlWriter struct {
+	w *bufio.Writer
+}
+
+func (jw *jsonlWriter) Write(item model.FoundItemResponse) error {
+	b, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	if _, err := jw.w.Write(b); err != nil {
+		return err
+	}
+	return jw.w.WriteByte('\n')
+}
+
+func (jw *jsonlWriter) Close() error {
+	return jw.w.Flush()
+}
+
+// xlsxWriter uses the excelize stream writer, which spills rows to a
+// temporary file instead of keeping the whole sheet in memory.
+type xlsxWriter struct {
+	out  io.Writer
+	file *excelize.File
+	sw   *excelize.StreamWriter
+	row  int
+}
+
+func newXLSXWriter(w io.Writer) (*xlsxWriter, error) {
+	f := excelize.NewFile()
+	sw, err := f.NewStreamWriter("Sheet1")
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	xw := &xlsxWriter{out: w, file: f, sw: sw, row: 1}
+	if err := xw.setRow(headers()); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return xw, nil
+}
+
+func (xw *xlsxWriter) setRow(vals []string) error {
+	cells := make([]any, len(vals))
+	for i, v := range vals {
+		cells[i] = v
+	}
+	cell, err := excelize.CoordinatesToCellName(1, xw.row)
+	if err != nil {
+		return err
+	}
+	xw.row++
+	return xw.sw.SetRow(cell, cells)
+}
+
+func (xw *xlsxWriter) Write(item model.FoundItemResponse) error {
+	return xw.setRow(values(item))
+}
+
+func (xw *xlsxWriter) Close() error {
+	defer func() { _ = xw.file.Close() }()
+	if err := xw.sw.Flush(); err != nil {
+		return err
+	}
+	_, err := xw.file.WriteTo(xw.out)
+	return err
+}
+
+type parquetRow struct {
+	ID                string   `parquet:"id"`
+	MunicipalityName  string   `parquet:"municipality_name"`
+	MunicipalityType  string   `parquet:"municipality_type"`
+	MunicipalityEmail string   `parquet:"municipality_email"`
+	ItemName          string   `parquet:"item_name"`
+	ItemCategory      string   `parquet:"item_category"`
+	ItemDate          string   `parquet:"item_date"`
+	ItemLocation      string   `parquet:"item_location"`
+	ItemStatus        string   `parquet:"item_status"`
+	ItemDescription   string   `parquet:"item_description,optional"`
+	PickupDeadline    int32    `parquet:"pickup_deadline"`
+	PickupLocation    string   `parquet:"pickup_location"`
+	PickupHours       string   `parquet:"pickup_hours,optional"`
+	PickupContact     string   `parquet:"pickup_contact,optional"`
+	Categories        []string `parquet:"categories,list"`
+	Attributes        string   `parquet:"attributes,optional"`
+	ItemLat           *float64 `parquet:"item_lat,optional"`
+	ItemLon           *float64 `parquet:"item_lon,optional"`
+	PickupLat         *float64 `parquet:"pickup_lat,optional"`
+	PickupLon         *float64 `parquet:"pickup_lon,optional"`
+	CreatedAt         string   `parquet:"created_at"`
+	UpdatedAt         string   `parquet:"updated_at"`
+}
+
+type parquetWriter struct {
+	w *parquet.GenericWriter[parquetRow]
+}
+
+func (pw *parquetWriter) Write(item model.FoundItemResponse) error {
+	_, err := pw.w.Write([]parquetRow{{
+		ID:                item.ID,
+		MunicipalityName:  item.Municipality.Name,
+		MunicipalityType:  item.Municipality.Type,
+		MunicipalityEmail: item.Municipality.ContactEmail,
+		ItemName:          item.Item.Name,
+		ItemCategory:      item.Item.Category,
+		ItemDate:          item.Item.Date,
+		ItemLocation:      item.Item.Location,
+		ItemStatus:        item.Item.Status,
+		ItemDescription:   item.Item.Description,
+		PickupDeadline:    int32(item.Pickup.Deadline),
+		PickupLocation:    item.Pickup.Location,
+		PickupHours:       item.Pickup.Hours,
+		PickupContact:     item.Pickup.Contact,
+		Categories:        item.Categories,
+		Attributes:        attributesJSON(item),
+		ItemLat:           coordinatePtr(item.Item.Geo, lat),
+		ItemLon:           coordinatePtr(item.Item.Geo, lon),
+		PickupLat:         coordinatePtr(item.Pickup.Geo, lat),
+		PickupLon:         coordinatePtr(item.Pickup.Geo, lon),
+		CreatedAt:         item.CreatedAt,
+		UpdatedAt:         item.UpdatedAt,
+	}})
+	return err
+}
+
+func (pw *parquetWriter) Close() error {
+	return pw.w.Close()
+}
@@ -0,0 +1,102 @@
+// Package finder protects the personal data of finders (znalazcy). Records
+// are sealed with AES-256-GCM and offices prove ownership of an item with a
+// token derived from the same key and the office e-mail address.
+package finder
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const sealPrefix = "v1:"
+
+var ErrCiphertext = errors.New("finder: malformed ciphertext")
+
+type Cipher struct {
+	aead     cipher.AEAD
+	tokenKey []byte
+}
+
+// ParseKey decodes a 32-byte key given as base64 or hex.
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	for _, decode := range []func(string) ([]byte, error){
+		base64.StdEncoding.DecodeString,
+		base64.RawURLEncoding.DecodeString,
+		hex.DecodeString,
+	} {
+		if key, err := decode(s); err == nil && len(key) == 32 {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("finder key must be 32 bytes encoded as base64 or hex")
+}
+
+// New derives separate encryption and token keys from the master key, so a
+// leaked office token reveals nothing about the encryption key.
+func New(key []byte) (*Cipher, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("finder key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(derive(key, "zguba-gov finder encryption"))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead, tokenKey: derive(key, "zguba-gov office token")}, nil
+}
+
+// Seal encrypts plaintext bound to aad (the item ID), so a ciphertext
+// copied to another row does not decrypt.
+func (c *Cipher) Seal(plaintext, aad []byte) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, plaintext, aad)
+	return sealPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *Cipher) Open(s string, aad []byte) ([]byte, error) {
+	if !strings.HasPrefix(s, sealPrefix) {
+		return nil, ErrCiphertext
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, sealPrefix))
+	if err != nil || len(raw) < c.aead.NonceSize() {
+		return nil, ErrCiphertext
+	}
+	n := c.aead.NonceSize()
+	return c.aead.Open(nil, raw[:n], raw[n:], aad)
+}
+
+// OfficeToken is the access token of the office with the given e-mail.
+func (c *Cipher) OfficeToken(email string) string {
+	mac := hmac.New(sha256.New, c.tokenKey)
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CheckOfficeToken reports whether token belongs to the office with email.
+func (c *Cipher) CheckOfficeToken(email, token string) bool {
+	if email == "" || token == "" {
+		return false
+	}
+	return hmac.Equal([]byte(c.OfficeToken(email)), []byte(token))
+}
+
+func derive(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,430 @@
+package form
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/i18n"
+)
+
+//go:embed default.json
+var defaultDefinition []byte
+
+// Field types understood by the wizard templates and validators.
+const (
+	TypeText         = "text"
+	TypeTextarea     = "textarea"
+	TypeEmail        = "email"
+	TypeDate         = "date"
+	TypeNumber       = "number"
+	TypeSelect       = "select"
+	TypeMunicipality = "municipality"
+	// TypeCategory is a select over the category taxonomy. Its options
+	// live in the database, so the caller checks the value exists.
+	TypeCategory = "category"
+)
+
+// CoreFields are stored in dedicated found_items columns. Every other field
+// is a custom attribute kept in the attributes JSON column.
+var CoreFields = map[string]string{
+	"municipalityName": TypeMunicipality,
+	"municipalityType": TypeSelect,
+	"contactEmail":     TypeEmail,
+	"itemName":         TypeText,
+	"itemCategory":     TypeCategory,
+	"itemDate":         TypeDate,
+	"itemLocation":     TypeText,
+	"itemStatus":       TypeSelect,
+	"itemDescription":  TypeTextarea,
+	"storageDeadline":  TypeNumber,
+	"pickupLocation":   TypeText,
+	"pickupHours":      TypeText,
+	"contactPerson":    TypeText,
+
+	"finderName":           TypeText,
+	"finderAddress":        TypeText,
+	"finderPhone":          TypeText,
+	"finderEmail":          TypeEmail,
+	"finderClaimsReward":   TypeSelect,
+	"finderWantsOwnership": TypeSelect,
+}
+
+// personalFields hold finder data. They are encrypted at rest and must not
+// be written to drafts or form exports.
+var personalFields = map[string]bool{
+	"finderName": true, "finderAddress": true, "finderPhone": true,
+	"finderEmail": true, "finderClaimsReward": true, "finderWantsOwnership": true,
+}
+
+// requiredCoreFields must appear in every definition because found_items
+// cannot be stored without them.
+var requiredCoreFields = []string{
+	"municipalityName", "municipalityType", "contactEmail",
+	"itemName", "itemCategory", "itemDate", "itemLocation",
+	"storageDeadline", "pickupLocation",
+}
+
+var reservedNames = map[string]bool{"currentStep": true, "editId": true, "draftId": true}
+
+var namePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
+
+type Option struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+}
+
+type Field struct {
+	Name        string   `json:"name"`
+	Label       string   `json:"label"`
+	Type        string   `json:"type"`
+	Required    bool     `json:"required,omitempty"`
+	Width       string   `json:"width,omitempty"`
+	Placeholder string   `json:"placeholder,omitempty"`
+	Default     string   `json:"default,omitempty"`
+	Options     []Option `json:"options,omitempty"`
+	Min         *int     `json:"min,omitempty"`
+	Max         *int     `json:"max,omitempty"`
+	MaxLength   int      `json:"maxLength,omitempty"`
+	Pattern     string   `json:"pattern,omitempty"`
+	Message     string   `json:"message,omitempty"`
+	Template    string   `json:"template,omitempty"`
+
+	pattern *regexp.Regexp
+	lang    string
+}
+
+type Step struct {
+	Title       string  `json:"title"`
+	Label       string  `json:"label"`
+	Description string  `json:"description,omitempty"`
+	Type        string  `json:"type,omitempty"`
+	Template    string  `json:"template,omitempty"`
+	Fields      []Field `json:"fields,omitempty"`
+}
+
+type Definition struct {
+	Steps []Step `json:"steps"`
+	// Translations holds the texts of the steps and fields in other
+	// languages, keyed by language and then by the text as written in
+	// the steps. Texts without a translation are shown as written.
+	Translations map[string]map[string]string `json:"translations,omitempty"`
+
+	fields map[string]*Field
+}
+
+// Load reads the form definition from path, or the embedded default when
+// path is empty.
+func Load(path string) (*Definition, error) {
+	data := defaultDefinition
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return Parse(data)
+}
+
+func Parse(data []byte) (*Definition, error) {
+	var def Definition
+	if err := json.Unmarshal(data, &def); err != nil {
+		return nil, fmt.Errorf("parse form definition: %w", err)
+	}
+	if err := def.init(); err != nil {
+		return nil, fmt.Errorf("form definition: %w", err)
+	}
+	return &def, nil
+}
+
+func (d *Definition) init() error {
+	if len(d.Steps) == 0 {
+		return fmt.Errorf("no steps defined")
+	}
+
+	d.fields = map[string]*Field{}
+	for si := range d.Steps {
+		s := &d.Steps[si]
+		if s.Type != "" && s.Type != "summary" {
+			return fmt.Errorf("step %d: unknown type %q", si+1, s.Type)
+		}
+		for fi := range s.Fields {
+			f := &s.Fields[fi]
+			if !namePattern.MatchString(f.Name) || reservedNames[f.Name] {
+				return fmt.Errorf("step %d: invalid field name %q", si+1, f.Name)
+			}
+			if _, dup := d.fields[f.Name]; dup {
+				return fmt.Errorf("field %q defined twice", f.Name)
+			}
+			if f.Type == "" {
+				f.Type = TypeText
+			}
+			if core, ok := CoreFields[f.Name]; ok && core != f.Type {
+				return fmt.Errorf("field %q must have type %q", f.Name, core)
+			}
+			switch f.Type {
+			case TypeText, TypeTextarea, TypeEmail, TypeDate, TypeNumber, TypeMunicipality, TypeCategory:
+			case TypeSelect:
+				if len(f.Options) == 0 {
+					return fmt.Errorf("field %q: select needs options", f.Name)
+				}
+			default:
+				return fmt.Errorf("field %q: unknown type %q", f.Name, f.Type)
+			}
+			if f.Pattern != "" {
+				re, err := regexp.Compile("^(?:" + f.Pattern + ")$")
+				if err != nil {
+					return fmt.Errorf("field %q: pattern: %w", f.Name, err)
+				}
+				f.pattern = re
+			}
+			d.fields[f.Name] = f
+		}
+	}
+
+	for _, name := range requiredCoreFields {
+		if _, ok := d.fields[name]; !ok {
+			return fmt.Errorf("required field %q is missing", name)
+		}
+	}
+	for lang := range d.Translations {
+		if !i18n.Supported(lang) {
+			return fmt.Errorf("translations: unsupported language %q", lang)
+		}
+	}
+	return nil
+}
+
+// Localize returns a copy of the definition with its texts in lang and
+// validation messages from the lang catalog.
+func (d *Definition) Localize(lang string) *Definition {
+	tr := d.Translations[lang]
+	text := func(s string) string {
+		if t, ok := tr[s]; ok && s != "" {
+			return t
+		}
+		return s
+	}
+
+	l := &Definition{Steps: make([]Step, len(d.Steps)), Translations: d.Translations, fields: map[string]*Field{}}
+	for si, s := range d.Steps {
+		s.Title, s.Label, s.Description = text(s.Title), text(s.Label), text(s.Description)
+		s.Fields = append([]Field(nil), s.Fields...)
+		for fi := range s.Fields {
+			f := &s.Fields[fi]
+			f.Label, f.Placeholder, f.Message = text(f.Label), text(f.Placeholder), text(f.Message)
+			f.Options = append([]Option(nil), f.Options...)
+			for oi := range f.Options {
+				f.Options[oi].Label = text(f.Options[oi].Label)
+			}
+			f.lang = lang
+			l.fields[f.Name] = f
+		}
+		l.Steps[si] = s
+	}
+	return l
+}
+
+func (d *Definition) TotalSteps() int {
+	return len(d.Steps)
+}
+
+// Step returns the 1-based step n, clamped to the valid range.
+func (d *Definition) Step(n int) *Step {
+	if n < 1 {
+		n = 1
+	}
+	if n > len(d.Steps) {
+		n = len(d.Steps)
+	}
+	return &d.Steps[n-1]
+}
+
+func (d *Definition) Field(name string) (*Field, bool) {
+	f, ok := d.fields[name]
+	return f, ok
+}
+
+// Fields returns every field in step order.
+func (d *Definition) Fields() []Field {
+	var out []Field
+	for _, s := range d.Steps {
+		out = append(out, s.Fields...)
+	}
+	return out
+}
+
+// CustomFields returns the fields stored as attributes.
+func (d *Definition) CustomFields() []Field {
+	var out []Field
+	for _, f := range d.Fields() {
+		if f.IsCustom() {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Validate checks the values entered on the 1-based step n and returns
+// user-facing messages for the fields that fail.
+func (d *Definition) Validate(n int, values func(name string) string) []string {
+	if n < 1 || n > len(d.Steps) {
+		return nil
+	}
+	var errors []string
+	for _, f := range d.Steps[n-1].Fields {
+		if msg := f.Check(values(f.Name)); msg != "" {
+			errors = append(errors, msg)
+		}
+	}
+	return errors
+}
+
+func (s *Step) IsSummary() bool {
+	return s.Type == "summary"
+}
+
+// Has reports whether the step has the named field.
+func (s *Step) Has(name string) bool {
+	for _, f := range s.Fields {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// StepTemplate is the template that renders the step.
+func (s *Step) StepTemplate() string {
+	switch {
+	case s.Template != "":
+		return s.Template
+	case s.IsSummary():
+		return "summary.html"
+	}
+	return "step.html"
+}
+
+// Rows groups the fields for layout: consecutive half-width fields share
+// a row, everything else gets a row of its own.
+func (s *Step) Rows() [][]Field {
+	var rows [][]Field
+	for i := 0; i < len(s.Fields); i++ {
+		f := s.Fields[i]
+		if f.Width == "half" && i+1 < len(s.Fields) && s.Fields[i+1].Width == "half" {
+			rows = append(rows, []Field{f, s.Fields[i+1]})
+			i++
+			continue
+		}
+		rows = append(rows, []Field{f})
+	}
+	return rows
+}
+
+func (f Field) IsCustom() bool {
+	_, core := CoreFields[f.Name]
+	return !core
+}
+
+// IsPersonal reports whether the field holds finder personal data.
+func (f Field) IsPersonal() bool {
+	return personalFields[f.Name]
+}
+
+// ShortLabel is the label without the optional field hint, such as
+// "(opcjonalnie)".
+func (f Field) ShortLabel() string {
+	return strings.TrimSuffix(f.Label, " "+i18n.T(f.lang, "form.optional"))
+}
+
+// FieldTemplate is the template that renders the input for the field.
+func (f Field) FieldTemplate() string {
+	if f.Template != "" {
+		return f.Template
+	}
+	return "field_" + f.Type + ".html"
+}
+
+// DisplayValue returns the option label for select values.
+func (f Field) DisplayValue(v string) string {
+	for _, o := range f.Options {
+		if o.Value == v {
+			return o.Label
+		}
+	}
+	return v
+}
+
+// Check validates a single value and returns an error message, or "" when
+// the value is acceptable.
+func (f Field) Check(v string) string {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		if f.Required {
+			return f.message("form.required")
+		}
+		return ""
+	}
+
+	switch f.Type {
+	case TypeEmail:
+		if !strings.Contains(v, "@") {
+			return f.message("form.email")
+		}
+	case TypeDate:
+		if _, err := time.Parse("2006-01-02", v); err != nil {
+			return f.message("form.date")
+		}
+	case TypeNumber:
+		n, err := strconv.Atoi(v)
+		if err != nil || (f.Min != nil && n < *f.Min) || (f.Max != nil && n > *f.Max) {
+			return f.message("form.number")
+		}
+	case TypeSelect:
+		if !f.hasOption(v) {
+			return f.InvalidMessage()
+		}
+	}
+
+	if f.MaxLength > 0 && len([]rune(v)) > f.MaxLength {
+		return f.message("form.max_length", f.MaxLength)
+	}
+	if f.pattern != nil && !f.pattern.MatchString(v) {
+		return f.message("form.pattern")
+	}
+	return ""
+}
+
+// Explain turns a message about the value of the field that does not name
+// it, such as "is required", into an error naming the field.
+func (f Field) Explain(msg string) string {
+	return i18n.T(f.lang, "form.rule", f.ShortLabel(), msg)
+}
+
+// InvalidMessage is the error shown for a value outside the allowed set.
+func (f Field) InvalidMessage() string {
+	return f.message("form.invalid")
+}
+
+func (f Field) hasOption(v string) bool {
+	for _, o := range f.Options {
+		if o.Value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// message returns the field's own message, or else the catalog message
+// key about the field and args.
+func (f Field) message(key string, args ...any) string {
+	if f.Message != "" {
+		return f.Message
+	}
+	return i18n.T(f.lang, key, append([]any{f.ShortLabel()}, args...)...)
+}
@@ -0,0 +1,249 @@
+package geo
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+	"github.com/kacperfilipiuk/zguba-gov/internal/municipality"
+)
+
+// seed places the seats of the voivodeship offices, so that items of the
+// largest cities are on the map without a gazetteer file.
+//
+//go:embed gazetteer.csv
+var seed []byte
+
+// placeHeaders maps the accepted gazetteer column headers, Polish or
+// English, to place fields.
+var placeHeaders = map[string]string{
+	"teryt": "teryt", "kod teryt": "teryt",
+	"name": "name", "nazwa": "name",
+	"lat": "lat", "latitude": "lat", "szerokość": "lat", "szerokosc": "lat",
+	"lon": "lon", "lng": "lon", "longitude": "lon", "długość": "lon", "dlugosc": "lon",
+}
+
+type place struct {
+	name  string
+	teryt string
+	point model.GeoPoint
+}
+
+// Gazetteer is an offline Geocoder. It knows places by name and territorial
+// units by TERYT code; places listed with a TERYT code only are named after
+// the unit in the territorial dataset, and places with both belong to that
+// unit, which tells apart places sharing a name.
+type Gazetteer struct {
+	munSvc *municipality.Service
+	places []place
+
+	mu  sync.Mutex
+	idx *gazIndex
+}
+
+// gazIndex looks up places of one version of the territorial dataset.
+type gazIndex struct {
+	version string
+	byName  map[string][]int
+	byTeryt map[string]int
+}
+
+// NewGazetteer returns the built-in gazetteer extended with the places of
+// the CSV file at path, if any. Places of the file take precedence.
+func NewGazetteer(munSvc *municipality.Service, path string) (*Gazetteer, error) {
+	places, err := parsePlaces(seed)
+	if err != nil {
+		return nil, fmt.Errorf("embedded gazetteer: %w", err)
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		more, err := parsePlaces(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		places = append(places, more...)
+	}
+	return &Gazetteer{munSvc: munSvc, places: places}, nil
+}
+
+// Len returns the number of places.
+func (g *Gazetteer) Len() int {
+	return len(g.places)
+}
+
+// parsePlaces reads a CSV file, separated by semicolons or commas, with a
+// header row naming the teryt, name, lat and lon columns. Decimal commas
+// are accepted in semicolon-separated files.
+func parsePlaces(data []byte) ([]place, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	r := csv.NewReader(bytes.NewReader(data))
+	header, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Count(header, []byte(";")) > bytes.Count(header, []byte(",")) {
+		r.Comma = ';'
+	}
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := make([]string, len(records[0]))
+	has := map[string]bool{}
+	for i, h := range records[0] {
+		columns[i] = placeHeaders[strings.ToLower(strings.TrimSpace(h))]
+		has[columns[i]] = true
+	}
+	if !has["lat"] || !has["lon"] || !has["name"] && !has["teryt"] {
+		return nil, fmt.Errorf("header row must name lat, lon and teryt or name columns")
+	}
+
+	var places []place
+	for i, rec := range records[1:] {
+		line := i + 2
+		fields := map[string]string{}
+		for j, v := range rec {
+			if j < len(columns) && columns[j] != "" {
+				fields[columns[j]] = strings.TrimSpace(v)
+			}
+		}
+		if strings.Join(rec, "") == "" {
+			continue
+		}
+		p := place{name: fields["name"], teryt: fields["teryt"]}
+		if len(p.teryt) == 6 {
+			p.teryt = "0" + p.teryt
+		}
+		if p.name == "" && p.teryt == "" {
+			return nil, fmt.Errorf("line %d: no name or teryt", line)
+		}
+		var err error
+		if p.point.Lat, err = parseCoord(fields["lat"], 90); err != nil {
+			return nil, fmt.Errorf("line %d: lat: %w", line, err)
+		}
+		if p.point.Lon, err = parseCoord(fields["lon"], 180); err != nil {
+			return nil, fmt.Errorf("line %d: lon: %w", line, err)
+		}
+		places = append(places, p)
+	}
+	return places, nil
+}
+
+func parseCoord(s string, limit float64) (float64, error) {
+	v, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid coordinate %q", s)
+	}
+	if v < -limit || v > limit {
+		return 0, fmt.Errorf("coordinate %q out of range", s)
+	}
+	return v, nil
+}
+
+// index returns the lookup tables for the current territorial dataset,
+// rebuilding them after it was replaced.
+func (g *Gazetteer) index() *gazIndex {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	version := g.munSvc.Version()
+	if g.idx != nil && g.idx.version == version {
+		return g.idx
+	}
+
+	idx := &gazIndex{version: version, byName: map[string][]int{}, byTeryt: map[string]int{}}
+	// Later places come first, so the gazetteer file overrides the seed.
+	for i := len(g.places) - 1; i >= 0; i-- {
+		p := g.places[i]
+		name := municipality.Normalize(p.name)
+		if u, ok := g.munSvc.Get(p.teryt); ok && (name == "" || name == municipality.Normalize(u.Name)) {
+			// The place is the unit itself rather than a place in it.
+			name = municipality.Normalize(u.Name)
+			if _, ok := idx.byTeryt[p.teryt]; !ok {
+				idx.byTeryt[p.teryt] = i
+			}
+		}
+		if name != "" {
+			idx.byName[name] = append(idx.byName[name], i)
+		}
+	}
+	g.idx = idx
+	return idx
+}
+
+// Geocode looks up the query, then each of its comma-separated parts from
+// the last, which is usually the town. A name shared by several places is
+// resolved with the municipality m. Failing that, the position of the
+// municipality itself is returned, if known.
+func (g *Gazetteer) Geocode(_ context.Context, query string, m model.MunicipalityInfo) (*model.GeoPoint, error) {
+	idx := g.index()
+	var units []municipality.TerritorialUnit
+	if strings.TrimSpace(m.Name) != "" {
+		units = g.munSvc.Match(m.Name, m.Type, m.ContactEmail)
+	}
+
+	parts := strings.FieldsFunc(query, func(r rune) bool { return r == ',' || r == ';' })
+	candidates := []string{query}
+	for i := len(parts) - 1; i >= 0 && len(parts) > 1; i-- {
+		candidates = append(candidates, parts[i])
+	}
+	for _, q := range candidates {
+		if i, ok := g.lookup(idx, municipality.Normalize(q), units); ok {
+			p := g.places[i].point
+			return &p, nil
+		}
+	}
+
+	for _, u := range units {
+		if i, ok := idx.byTeryt[string(u.ID)]; ok {
+			p := g.places[i].point
+			return &p, nil
+		}
+	}
+	return nil, nil
+}
+
+// lookup returns the place named name: the first one in one of units, or
+// else the only place of that name.
+func (g *Gazetteer) lookup(idx *gazIndex, name string, units []municipality.TerritorialUnit) (int, bool) {
+	found := idx.byName[name]
+	for _, i := range found {
+		for _, u := range units {
+			if within(g.places[i].teryt, u) {
+				return i, true
+			}
+		}
+	}
+	// A place named like the municipality but lying elsewhere is a
+	// namesake, not the municipality.
+	if len(found) == 1 && (len(units) == 0 || g.places[found[0]].teryt == "" || name != municipality.Normalize(units[0].Name)) {
+		return found[0], true
+	}
+	return 0, false
+}
+
+// within reports whether the unit with the given TERYT code is u or lies
+// inside it.
+func within(teryt string, u municipality.TerritorialUnit) bool {
+	id := string(u.ID)
+	switch {
+	case teryt == "" || len(id) < 4:
+		return false
+	case u.Type == "wojewodztwo":
+		return strings.HasPrefix(teryt, id[:2])
+	case u.Type == "powiat":
+		return strings.HasPrefix(teryt, id[:4])
+	}
+	return teryt == id
+}
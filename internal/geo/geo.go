@@ -0,0 +1,96 @@
+// Package geo places found items on the map: it geocodes the free-text
+// locations of items and builds the SQL for area filters.
+package geo
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+)
+
+// Geocoder finds the position of a place described in free text. The
+// municipality the item was registered in narrows down ambiguous names.
+// Geocode returns nil when the place is not known.
+type Geocoder interface {
+	Geocode(ctx context.Context, query string, m model.MunicipalityInfo) (*model.GeoPoint, error)
+}
+
+// earthRadius is the mean radius of the Earth in metres.
+const earthRadius = 6371008.8
+
+// metresPerDegree is the length of one degree of latitude.
+const metresPerDegree = earthRadius * math.Pi / 180
+
+// Distance returns the great-circle distance between a and b in metres.
+func Distance(a, b model.GeoPoint) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Lon - a.Lon) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// gridLat is the latitude at which map grid cells are square on screen,
+// the middle of Poland.
+const gridLat = 52
+
+// GridCell returns the size in degrees of a map grid cell spanning
+// cellPixels of a Web Mercator map at zoom.
+func GridCell(zoom int, cellPixels float64) (lon, lat float64) {
+	lon = 360 / (256 * math.Exp2(float64(zoom))) * cellPixels
+	return lon, lon * math.Cos(gridLat*math.Pi/180)
+}
+
+// SquaredDistanceSQL returns an SQL expression for the squared distance in
+// square metres between p and the position in the latCol and lonCol
+// columns. SQLite has no trigonometric functions, so the distance is
+// computed on an equirectangular projection centred on p; within a few
+// hundred kilometres it is off by well under one percent.
+func SquaredDistanceSQL(latCol, lonCol string, p model.GeoPoint) (string, []any) {
+	k := math.Cos(p.Lat * math.Pi / 180)
+	expr := fmt.Sprintf("((%[1]s - ?) * (%[1]s - ?) + (%[2]s - ?) * (%[2]s - ?) * ?) * ?", latCol, lonCol)
+	return expr, []any{p.Lat, p.Lat, p.Lon, p.Lon, k * k, metresPerDegree * metresPerDegree}
+}
+
+// WithinSQL returns an SQL condition selecting positions within radius
+// metres of p. A bounding box, which can use an index, is checked first.
+func WithinSQL(latCol, lonCol string, p model.GeoPoint, radius float64) (string, []any) {
+	dLat := radius / metresPerDegree
+	dLon := 180.0
+	if k := math.Cos(p.Lat * math.Pi / 180); k > 1e-6 {
+		dLon = math.Min(180, dLat/k)
+	}
+	box, args := BBoxSQL(latCol, lonCol, model.BBox{MinLon: p.Lon - dLon, MinLat: p.Lat - dLat, MaxLon: p.Lon + dLon, MaxLat: p.Lat + dLat})
+	dist, distArgs := SquaredDistanceSQL(latCol, lonCol, p)
+	args = append(args, distArgs...)
+	return box + " AND " + dist + " <= ?", append(args, radius*radius)
+}
+
+// BBoxSQL returns an SQL condition selecting positions inside b.
+func BBoxSQL(latCol, lonCol string, b model.BBox) (string, []any) {
+	return fmt.Sprintf("%[1]s BETWEEN ? AND ? AND %[2]s BETWEEN ? AND ?", latCol, lonCol),
+		[]any{b.MinLat, b.MaxLat, b.MinLon, b.MaxLon}
+}
+
+// Locate fills in the positions missing from an item and its pickup point
+// by geocoding their locations. Places the geocoder does not know are left
+// without a position.
+func Locate(ctx context.Context, g Geocoder, m model.MunicipalityInfo, item *model.ItemInfo, pickup *model.PickupInfo) error {
+	if item != nil && item.Geo == nil && item.Location != "" {
+		p, err := g.Geocode(ctx, item.Location, m)
+		if err != nil {
+			return fmt.Errorf("geocode item location: %w", err)
+		}
+		item.Geo = p
+	}
+	if pickup != nil && pickup.Geo == nil && pickup.Location != "" {
+		p, err := g.Geocode(ctx, pickup.Location, m)
+		if err != nil {
+			return fmt.Errorf("geocode pickup location: %w", err)
+		}
+		pickup.Geo = p
+	}
+	return nil
+}
@@ -2,20 +2,35 @@ package handler
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/kacperfilipiuk/zguba-gov/internal/events"
+	"github.com/kacperfilipiuk/zguba-gov/internal/export"
+	"github.com/kacperfilipiuk/zguba-gov/internal/geo"
+	"github.com/kacperfilipiuk/zguba-gov/internal/i18n"
 	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+	"github.com/kacperfilipiuk/zguba-gov/internal/notify"
 	"github.com/kacperfilipiuk/zguba-gov/internal/repository"
 )
 
 type APIHandler struct {
-	repo *repository.FoundItemRepo
+	repo       *repository.FoundItemRepo
+	categories *repository.CategoryRepo
+	notifier   *notify.Service
+	events     *events.Bus
+	geocoder   geo.Geocoder
 }
 
-func NewAPIHandler(repo *repository.FoundItemRepo) *APIHandler {
-	return &APIHandler{repo: repo}
+func NewAPIHandler(repo *repository.FoundItemRepo, categories *repository.CategoryRepo, notifier *notify.Service, bus *events.Bus, geocoder geo.Geocoder) *APIHandler {
+	return &APIHandler{repo: repo, categories: categories, notifier: notifier, events: bus, geocoder: geocoder}
 }
 
 func (h *APIHandler) ListItems(c *gin.Context) {
@@ -31,18 +46,17 @@ func (h *APIHandler) ListItems(c *gin.Context) {
 		skip = 0
 	}
 
-	params := model.ListParams{
-		Skip:         skip,
-		Limit:        limit,
-		Category:     c.Query("category"),
-		Municipality: c.Query("municipality"),
-		Status:       c.Query("status"),
-		Search:       c.Query("search"),
+	params, err := listFilters(c)
+	if err != nil {
+		jsonErr(c, http.StatusBadRequest, err)
+		return
 	}
+	params.Skip = skip
+	params.Limit = limit
 
 	items, err := h.repo.List(params)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		jsonErr(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -54,20 +68,71 @@ func (h *APIHandler) ListItems(c *gin.Context) {
 	c.JSON(http.StatusOK, resp)
 }
 
+func (h *APIHandler) ExportItems(c *gin.Context) {
+	format := c.DefaultQuery("format", export.FormatCSV)
+	mediaType := export.MediaType(format)
+	if mediaType == "" {
+		jsonError(c, http.StatusBadRequest, "api.invalid_format", strings.Join(export.Formats, ", "))
+		return
+	}
+	params, err := listFilters(c)
+	if err != nil {
+		jsonErr(c, http.StatusBadRequest, err)
+		return
+	}
+
+	filename := fmt.Sprintf("zguba-gov-found-items-%s.%s", time.Now().Format("2006-01-02"), format)
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Header("Content-Type", mediaType)
+	c.Status(http.StatusOK)
+
+	w, err := export.NewWriter(format, c.Writer)
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = h.repo.Each(params, func(item model.FoundItem) error {
+		return w.Write(item.ToResponse())
+	})
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		// Headers are already sent, so the client only sees a truncated body.
+		_ = c.Error(err)
+		log.Printf("export %s: %v", format, err)
+	}
+}
+
 func (h *APIHandler) CreateItem(c *gin.Context) {
 	var create model.FoundItemCreate
 	if err := c.ShouldBindJSON(&create); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		jsonErr(c, http.StatusBadRequest, err)
 		return
 	}
+	if !h.validate(c, create) {
+		return
+	}
+	if err := geo.Locate(c.Request.Context(), h.geocoder, create.Municipality, &create.Item, &create.Pickup); err != nil {
+		log.Printf("locate new item: %v", err)
+	}
 
 	item, err := h.repo.Create(create)
+	if errors.Is(err, repository.ErrFinderDisabled) {
+		jsonErr(c, http.StatusUnprocessableEntity, err)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		jsonErr(c, http.StatusInternalServerError, err)
 		return
 	}
 
 	resp := item.ToResponse()
+	if err := h.notifier.ItemRegistered(resp); err != nil {
+		log.Printf("notify item %s: %v", resp.ID, err)
+	}
+	h.events.ItemCreated(resp)
 	c.JSON(http.StatusCreated, resp)
 }
 
@@ -75,11 +140,11 @@ func (h *APIHandler) GetItem(c *gin.Context) {
 	id := c.Param("id")
 	item, err := h.repo.GetByID(id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		jsonErr(c, http.StatusInternalServerError, err)
 		return
 	}
 	if item == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		jsonError(c, http.StatusNotFound, "api.item_not_found")
 		return
 	}
 	c.JSON(http.StatusOK, item.ToResponse())
@@ -89,57 +154,181 @@ func (h *APIHandler) UpdateItem(c *gin.Context) {
 	id := c.Param("id")
 	var update model.FoundItemUpdate
 	if err := c.ShouldBindJSON(&update); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		jsonErr(c, http.StatusBadRequest, err)
+		return
+	}
+	if !h.validate(c, update) {
 		return
 	}
 
+	before, err := h.repo.GetByID(id)
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	if before == nil {
+		jsonError(c, http.StatusNotFound, "api.item_not_found")
+		return
+	}
+	m := before.ToResponse().Municipality
+	if update.Municipality != nil {
+		m = *update.Municipality
+	}
+	if err := geo.Locate(c.Request.Context(), h.geocoder, m, update.Item, update.Pickup); err != nil {
+		log.Printf("locate item %s: %v", id, err)
+	}
+
 	item, err := h.repo.Update(id, update)
+	if errors.Is(err, repository.ErrFinderDisabled) {
+		jsonErr(c, http.StatusUnprocessableEntity, err)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		jsonErr(c, http.StatusInternalServerError, err)
 		return
 	}
 	if item == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		jsonError(c, http.StatusNotFound, "api.item_not_found")
 		return
 	}
-	c.JSON(http.StatusOK, item.ToResponse())
+	resp := item.ToResponse()
+	h.events.ItemUpdated(resp, before.ItemStatus)
+	c.JSON(http.StatusOK, resp)
 }
 
 func (h *APIHandler) DeleteItem(c *gin.Context) {
 	id := c.Param("id")
-	err := h.repo.Delete(id)
+	item, err := h.repo.GetByID(id)
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	if item == nil {
+		jsonError(c, http.StatusNotFound, "api.item_not_found")
+		return
+	}
+
+	err = h.repo.Delete(id)
 	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		jsonError(c, http.StatusNotFound, "api.item_not_found")
 		return
 	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		jsonErr(c, http.StatusInternalServerError, err)
 		return
 	}
+	h.events.ItemDeleted(item.ToResponse())
 	c.Status(http.StatusNoContent)
 }
 
 func (h *APIHandler) CategoriesList(c *gin.Context) {
 	cats, err := h.repo.Categories()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		jsonErr(c, http.StatusInternalServerError, err)
 		return
 	}
 	if cats == nil {
 		cats = []map[string]string{}
 	}
+	localizeCategories(h.categories, cats, locale(c, i18n.Default))
 	c.JSON(http.StatusOK, cats)
 }
 
+// validate rejects an item that breaks the rules of the register and
+// reports whether the request may proceed.
+func (h *APIHandler) validate(c *gin.Context, item interface{ Validate(model.Taxonomy) error }) bool {
+	err := item.Validate(h.categories)
+	var invalid model.ValidationError
+	switch {
+	case err == nil:
+		return true
+	case errors.As(err, &invalid):
+		jsonErr(c, http.StatusUnprocessableEntity, err)
+	default:
+		jsonErr(c, http.StatusInternalServerError, err)
+	}
+	return false
+}
+
 func (h *APIHandler) Stats(c *gin.Context) {
 	stats, err := h.repo.Stats()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		jsonErr(c, http.StatusInternalServerError, err)
 		return
 	}
 	c.JSON(http.StatusOK, stats)
 }
 
+func listFilters(c *gin.Context) (model.ListParams, error) {
+	p := model.ListParams{
+		Category:     c.Query("category"),
+		Municipality: c.Query("municipality"),
+		Status:       c.Query("status"),
+		Search:       c.Query("search"),
+		Office:       c.Query("office"),
+		Stale:        c.Query("stale") == "true",
+	}
+	for _, d := range []struct {
+		param string
+		value *string
+	}{{"dateFrom", &p.DateFrom}, {"dateTo", &p.DateTo}} {
+		if s := c.Query(d.param); s != "" {
+			if _, err := time.Parse("2006-01-02", s); err != nil {
+				return p, i18n.NewError("api.invalid_date", d.param)
+			}
+			*d.value = s
+		}
+	}
+	if s := c.Query("bbox"); s != "" {
+		v, err := parseFloats(s, 4)
+		if err != nil || !validPosition(v[1], v[0]) || !validPosition(v[3], v[2]) || v[0] > v[2] || v[1] > v[3] {
+			return p, i18n.NewError("api.invalid_bbox")
+		}
+		p.BBox = &model.BBox{MinLon: v[0], MinLat: v[1], MaxLon: v[2], MaxLat: v[3]}
+	}
+	if c.Query("lat") != "" || c.Query("lon") != "" {
+		v, err := parseFloats(c.Query("lat")+","+c.Query("lon"), 2)
+		if err != nil || !validPosition(v[0], v[1]) {
+			return p, i18n.NewError("api.invalid_position")
+		}
+		radius, err := strconv.ParseFloat(c.DefaultQuery("radius", "1000"), 64)
+		if err != nil || math.IsNaN(radius) || radius <= 0 || radius > maxRadius {
+			return p, i18n.NewError("api.invalid_radius", maxRadius)
+		}
+		p.Near = &model.Near{Point: model.GeoPoint{Lat: v[0], Lon: v[1]}, Radius: radius}
+	}
+	return p, nil
+}
+
+// maxRadius caps the radius filter, in metres, to the range in which
+// distances are computed accurately enough.
+const maxRadius = 200000
+
+// validPosition reports whether lat and lon are coordinates on the globe.
+func validPosition(lat, lon float64) bool {
+	return lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180
+}
+
+// parseFloats parses n comma-separated finite numbers.
+func parseFloats(s string, n int) ([]float64, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != n {
+		return nil, fmt.Errorf("want %d numbers", n)
+	}
+	v := make([]float64, n)
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, err
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("%s is not a finite number", part)
+		}
+		v[i] = f
+	}
+	return v, nil
+}
+
 func (h *APIHandler) Health(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "healthy"})
 }
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+	"github.com/kacperfilipiuk/zguba-gov/internal/repository"
+)
+
+// CategoryHandler serves the admin endpoints for the item taxonomy.
+type CategoryHandler struct {
+	repo *repository.CategoryRepo
+}
+
+func NewCategoryHandler(repo *repository.CategoryRepo) *CategoryHandler {
+	return &CategoryHandler{repo: repo}
+}
+
+func (h *CategoryHandler) List(c *gin.Context) {
+	cats, err := h.repo.List()
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, cats)
+}
+
+func (h *CategoryHandler) Get(c *gin.Context) {
+	cat, err := h.repo.Get(c.Param("code"))
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	if cat == nil {
+		jsonError(c, http.StatusNotFound, "api.category_not_found")
+		return
+	}
+	c.JSON(http.StatusOK, cat)
+}
+
+func (h *CategoryHandler) Create(c *gin.Context) {
+	var create model.CategoryCreate
+	if err := c.ShouldBindJSON(&create); err != nil {
+		jsonErr(c, http.StatusBadRequest, err)
+		return
+	}
+
+	cat, err := h.repo.Create(create)
+	if err != nil {
+		categoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, cat)
+}
+
+func (h *CategoryHandler) Update(c *gin.Context) {
+	var update model.CategoryUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		jsonErr(c, http.StatusBadRequest, err)
+		return
+	}
+
+	cat, err := h.repo.Update(c.Param("code"), update)
+	if err != nil {
+		categoryError(c, err)
+		return
+	}
+	if cat == nil {
+		jsonError(c, http.StatusNotFound, "api.category_not_found")
+		return
+	}
+	c.JSON(http.StatusOK, cat)
+}
+
+func (h *CategoryHandler) Delete(c *gin.Context) {
+	err := h.repo.Delete(c.Param("code"))
+	if err == sql.ErrNoRows {
+		jsonError(c, http.StatusNotFound, "api.category_not_found")
+		return
+	}
+	if err != nil {
+		categoryError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func categoryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrCategoryExists), errors.Is(err, repository.ErrCategoryInUse):
+		jsonErr(c, http.StatusConflict, err)
+	case errors.Is(err, repository.ErrCategoryCode), errors.Is(err, repository.ErrCategoryParent),
+		errors.Is(err, repository.ErrCategoryCycle):
+		jsonErr(c, http.StatusUnprocessableEntity, err)
+	default:
+		jsonErr(c, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"database/sql"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kacperfilipiuk/zguba-gov/internal/i18n"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+)
+
+// EditItem opens an existing item in the wizard. HTMX requests get only the
+// page content; direct visits get the full layout so the URL can be reloaded.
+func (h *PagesHandler) EditItem(c *gin.Context) {
+	item, err := h.repo.GetByID(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusInternalServerError, "database error: %v", err)
+		return
+	}
+	if item == nil {
+		c.String(http.StatusNotFound, i18n.T(h.lang(c), "error.item_not_found"))
+		return
+	}
+
+	resp := item.ToResponse()
+	data := h.newWizard(c, 1)
+	data.MunicipalityName = resp.Municipality.Name
+	data.MunicipalityType = resp.Municipality.Type
+	data.ContactEmail = resp.Municipality.ContactEmail
+	data.ItemName = resp.Item.Name
+	data.ItemCategory = resp.Item.Category
+	data.ItemDate = resp.Item.Date
+	data.ItemLocation = resp.Item.Location
+	data.ItemStatus = resp.Item.Status
+	data.ItemDescription = resp.Item.Description
+	data.StorageDeadline = strconv.Itoa(resp.Pickup.Deadline)
+	data.PickupLocation = resp.Pickup.Location
+	data.PickupHours = resp.Pickup.Hours
+	data.ContactPerson = resp.Pickup.Contact
+	for k, v := range resp.Attributes {
+		data.Attributes[k] = v
+	}
+	data.EditID = resp.ID
+	data.Items = h.recentItems()
+	data.DraftCount = h.draftCount(c)
+
+	if c.GetHeader("HX-Request") == "true" {
+		h.renderPartial(c, "content", data)
+		return
+	}
+	h.render(c, "layout.html", data)
+}
+
+func (h *PagesHandler) ChangeStatus(c *gin.Context) {
+	status := c.PostForm("status")
+	if !slices.Contains(model.ItemStatuses, status) {
+		h.renderErrorModal(c, []string{i18n.T(h.lang(c), "error.status", status)})
+		return
+	}
+
+	before, err := h.repo.GetByID(c.Param("id"))
+	if err != nil {
+		h.renderErrorModal(c, []string{i18n.T(h.lang(c), "error.read", err.Error())})
+		return
+	}
+	if before == nil {
+		h.renderErrorModal(c, []string{i18n.T(h.lang(c), "error.item_missing")})
+		return
+	}
+
+	item, err := h.repo.SetStatus(before.ID, status)
+	if err != nil {
+		h.renderErrorModal(c, []string{i18n.T(h.lang(c), "error.save", err.Error())})
+		return
+	}
+	if item == nil {
+		h.renderErrorModal(c, []string{i18n.T(h.lang(c), "error.item_missing")})
+		return
+	}
+
+	resp := item.ToResponse()
+	h.events.ItemUpdated(resp, before.ItemStatus)
+	h.renderPartial(c, "record_clerk_card.html", resp)
+}
+
+// ConfirmDelete shows the modal asking to confirm the deletion of an item.
+func (h *PagesHandler) ConfirmDelete(c *gin.Context) {
+	item, err := h.repo.GetByID(c.Param("id"))
+	if err != nil {
+		h.renderErrorModal(c, []string{i18n.T(h.lang(c), "error.read", err.Error())})
+		return
+	}
+	if item == nil {
+		h.renderErrorModal(c, []string{i18n.T(h.lang(c), "error.item_missing")})
+		return
+	}
+
+	h.renderPartial(c, "confirm_modal.html", item.ToResponse())
+}
+
+func (h *PagesHandler) DeleteItem(c *gin.Context) {
+	item, err := h.repo.GetByID(c.Param("id"))
+	if err != nil {
+		h.renderErrorModal(c, []string{i18n.T(h.lang(c), "error.read", err.Error())})
+		return
+	}
+	if item == nil {
+		h.renderErrorModal(c, []string{i18n.T(h.lang(c), "error.item_missing")})
+		return
+	}
+
+	err = h.repo.Delete(item.ID)
+	if err == sql.ErrNoRows {
+		h.renderErrorModal(c, []string{i18n.T(h.lang(c), "error.item_missing")})
+		return
+	}
+	if err != nil {
+		h.renderErrorModal(c, []string{i18n.T(h.lang(c), "error.delete", err.Error())})
+		return
+	}
+	h.events.ItemDeleted(item.ToResponse())
+
+	h.renderPartial(c, "delete_result.html", wizardData{
+		Success: i18n.T(h.lang(c), "records.deleted"),
+		Items:   h.recentItems(),
+	})
+}
+
+func (h *PagesHandler) renderErrorModal(c *gin.Context, errors []string) {
+	c.Header("HX-Retarget", "#modals")
+	c.Header("HX-Reswap", "innerHTML")
+	h.renderPartial(c, "error_modal.html", wizardData{Errors: errors})
+}
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/kacperfilipiuk/zguba-gov/internal/i18n"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+)
+
+const sessionCookie = "zguba_session"
+
+type draftsData struct {
+	Drafts     []draftSummary
+	TotalSteps int
+}
+
+type draftSummary struct {
+	ID        string
+	Title     string
+	Step      int
+	UpdatedAt string
+}
+
+// sessionID returns the wizard session from the cookie, starting a new
+// session when the browser has none.
+func sessionID(c *gin.Context) string {
+	if id, err := c.Cookie(sessionCookie); err == nil && id != "" {
+		return id
+	}
+	id := uuid.New().String()
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(sessionCookie, id, 30*24*3600, "/", "", c.Request.TLS != nil, true)
+	return id
+}
+
+// saveDraft stores the wizard state and records the draft ID in data. The
+// draft URL is pushed to the browser history so a reload resumes it.
+func (h *PagesHandler) saveDraft(c *gin.Context, data *wizardData) {
+	d := &model.Draft{
+		ID:        data.DraftID,
+		SessionID: sessionID(c),
+		Step:      data.CurrentStep,
+		Fields:    draftFields(*data),
+	}
+	if err := h.drafts.Save(d); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	data.DraftID = d.ID
+	c.Header("HX-Push-Url", "/drafts/"+d.ID)
+}
+
+func (h *PagesHandler) Drafts(c *gin.Context) {
+	drafts, err := h.drafts.ListBySession(sessionID(c))
+	if err != nil {
+		h.renderErrorModal(c, []string{i18n.T(h.lang(c), "error.read", err.Error())})
+		return
+	}
+
+	data := draftsData{TotalSteps: h.form.TotalSteps()}
+	for _, d := range drafts {
+		title := d.Fields["itemName"]
+		if title == "" {
+			title = i18n.T(h.lang(c), "drafts.untitled")
+		}
+		if m := d.Fields["municipalityName"]; m != "" {
+			title += " – " + m
+		}
+		data.Drafts = append(data.Drafts, draftSummary{
+			ID:        d.ID,
+			Title:     title,
+			Step:      d.Step,
+			UpdatedAt: d.UpdatedAt.Local().Format("2006-01-02 15:04"),
+		})
+	}
+	h.renderPartial(c, "drafts_modal.html", data)
+}
+
+// ResumeDraft opens a saved draft. The draft URL works as a resume link:
+// opening it from another browser copies the draft to that session and
+// redirects to the copy, leaving the original to its owner.
+func (h *PagesHandler) ResumeDraft(c *gin.Context) {
+	d, err := h.drafts.Get(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusInternalServerError, "database error: %v", err)
+		return
+	}
+	if d == nil {
+		c.Redirect(http.StatusSeeOther, "/")
+		return
+	}
+
+	if sid := sessionID(c); d.SessionID != sid {
+		d.SessionID = sid
+		if err := h.drafts.Save(d); err != nil {
+			c.String(http.StatusInternalServerError, "database error: %v", err)
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/drafts/"+d.ID)
+		return
+	}
+
+	data := h.wizardFromDraft(c, *d)
+	data.Items = h.recentItems()
+	data.DraftCount = h.draftCount(c)
+	h.render(c, "layout.html", data)
+}
+
+func (h *PagesHandler) DeleteDraft(c *gin.Context) {
+	if err := h.drafts.Delete(c.Param("id"), sessionID(c)); err != nil {
+		h.renderErrorModal(c, []string{i18n.T(h.lang(c), "error.delete", err.Error())})
+		return
+	}
+	h.Drafts(c)
+}
+
+func (h *PagesHandler) draftCount(c *gin.Context) int {
+	drafts, _ := h.drafts.ListBySession(sessionID(c))
+	return len(drafts)
+}
+
+func draftFields(d wizardData) map[string]string {
+	fields := map[string]string{"editId": d.EditID}
+	for _, f := range d.Form.Fields() {
+		if !f.IsPersonal() {
+			fields[f.Name] = d.Value(f.Name)
+		}
+	}
+	return fields
+}
+
+// wizardFromDraft restores a draft. Fields no longer in the form definition
+// are dropped and an out-of-range step falls back to the first one.
+func (h *PagesHandler) wizardFromDraft(c *gin.Context, d model.Draft) wizardData {
+	step := d.Step
+	if step < 1 || step > h.form.TotalSteps() {
+		step = 1
+	}
+	data := h.newWizard(c, step)
+	for _, f := range h.form.Fields() {
+		if v, ok := d.Fields[f.Name]; ok {
+			data.SetValue(f.Name, v)
+		}
+	}
+	data.EditID = d.Fields["editId"]
+	data.DraftID = d.ID
+	return data
+}
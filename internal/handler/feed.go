@@ -0,0 +1,309 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kacperfilipiuk/zguba-gov/internal/i18n"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+	"github.com/kacperfilipiuk/zguba-gov/internal/repository"
+)
+
+const feedSize = 50
+
+// FeedHandler serves the newest found items as Atom and RSS feeds.
+type FeedHandler struct {
+	repo       *repository.FoundItemRepo
+	categories *repository.CategoryRepo
+	baseURL    string
+}
+
+func NewFeedHandler(repo *repository.FoundItemRepo, categories *repository.CategoryRepo, baseURL string) *FeedHandler {
+	return &FeedHandler{repo: repo, categories: categories, baseURL: baseURL}
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Lang    string      `xml:"xml:lang,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomPerson  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomPerson struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email,omitempty"`
+}
+
+type atomEntry struct {
+	ID        string         `xml:"id"`
+	Title     string         `xml:"title"`
+	Updated   string         `xml:"updated"`
+	Published string         `xml:"published"`
+	Link      atomLink       `xml:"link"`
+	Author    atomPerson     `xml:"author"`
+	Category  []atomCategory `xml:"category"`
+	Summary   string         `xml:"summary"`
+}
+
+type atomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr,omitempty"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Self          atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Description string   `xml:"description"`
+	Category    []string `xml:"category"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// Atom serves /feeds/found-items.atom.
+func (h *FeedHandler) Atom(c *gin.Context) {
+	lang := locale(c, i18n.Default)
+	items, filters, ok := h.load(c, lang)
+	if !ok {
+		return
+	}
+
+	feed := atomFeed{
+		Lang:    lang,
+		ID:      h.baseURL + c.Request.URL.RequestURI(),
+		Title:   h.title(filters, lang),
+		Updated: feedUpdated(items).Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: h.baseURL + c.Request.URL.RequestURI()},
+			{Rel: "alternate", Type: "text/html", Href: h.baseURL + itemsPageURL(filters, 1)},
+		},
+		Author: atomPerson{Name: i18n.T(lang, "site.title")},
+	}
+	for _, it := range items {
+		link := h.baseURL + "/rzeczy/" + it.ID
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:        link,
+			Title:     feedTitle(it),
+			Updated:   it.UpdatedAt,
+			Published: it.CreatedAt,
+			Link:      atomLink{Rel: "alternate", Type: "text/html", Href: link},
+			Author:    atomPerson{Name: it.Municipality.Name, Email: it.Municipality.ContactEmail},
+			Category:  []atomCategory{{Term: it.Item.Category, Label: h.categories.LabelIn(it.Item.Category, lang)}},
+			Summary:   h.summary(it, lang),
+		})
+	}
+	h.write(c, "application/atom+xml; charset=utf-8", feed)
+}
+
+// RSS serves /feeds/found-items.rss.
+func (h *FeedHandler) RSS(c *gin.Context) {
+	lang := locale(c, i18n.Default)
+	items, filters, ok := h.load(c, lang)
+	if !ok {
+		return
+	}
+
+	feed := rssFeed{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       h.title(filters, lang),
+			Link:        h.baseURL + itemsPageURL(filters, 1),
+			Description: i18n.T(lang, "feed.description"),
+			Language:    lang,
+			Self:        atomLink{Rel: "self", Type: "application/rss+xml", Href: h.baseURL + c.Request.URL.RequestURI()},
+		},
+	}
+	if len(items) > 0 {
+		feed.Channel.LastBuildDate = feedUpdated(items).Format(time.RFC1123Z)
+	}
+	for _, it := range items {
+		link := h.baseURL + "/rzeczy/" + it.ID
+		published, _ := time.Parse(time.RFC3339, it.CreatedAt)
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       feedTitle(it),
+			Link:        link,
+			GUID:        rssGUID{IsPermaLink: true, Value: link},
+			PubDate:     published.Format(time.RFC1123Z),
+			Description: h.summary(it, lang),
+			Category:    []string{h.categories.LabelIn(it.Item.Category, lang)},
+		})
+	}
+	h.write(c, "application/rss+xml; charset=utf-8", feed)
+}
+
+// load fetches the newest items for the request filters and answers
+// conditional requests for the feed in lang. It reports false when the
+// response has been sent.
+func (h *FeedHandler) load(c *gin.Context, lang string) ([]model.FoundItemResponse, model.ListParams, bool) {
+	filters, err := listFilters(c)
+	if err != nil {
+		c.String(http.StatusBadRequest, "%v", err)
+		return nil, filters, false
+	}
+	filters.Limit = feedSize
+
+	items, err := h.repo.List(filters)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "database error: %v", err)
+		return nil, filters, false
+	}
+	resp := make([]model.FoundItemResponse, 0, len(items))
+	var lastModified time.Time
+	tag := sha256.New()
+	fmt.Fprintf(tag, "%s;", lang)
+	for _, it := range items {
+		resp = append(resp, it.ToResponse())
+		if it.UpdatedAt.After(lastModified) {
+			lastModified = it.UpdatedAt
+		}
+		fmt.Fprintf(tag, "%s@%d;", it.ID, it.UpdatedAt.UnixNano())
+	}
+
+	// The ETag covers the language and the set of items, so a deleted
+	// item changes it even though no remaining item is newer.
+	etag := `W/"` + hex.EncodeToString(tag.Sum(nil))[:32] + `"`
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "public, max-age=300")
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if notModified(c, etag, lastModified) {
+		c.Status(http.StatusNotModified)
+		return nil, filters, false
+	}
+	return resp, filters, true
+}
+
+func (h *FeedHandler) write(c *gin.Context, contentType string, feed any) {
+	out, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		c.String(http.StatusInternalServerError, "feed error: %v", err)
+		return
+	}
+	c.Data(http.StatusOK, contentType, append([]byte(xml.Header), out...))
+}
+
+func (h *FeedHandler) title(f model.ListParams, lang string) string {
+	title := i18n.T(lang, "items.title")
+	var qualifiers []string
+	if f.Category != "" {
+		qualifiers = append(qualifiers, h.categories.LabelIn(f.Category, lang))
+	}
+	if f.Municipality != "" {
+		qualifiers = append(qualifiers, f.Municipality)
+	}
+	if len(qualifiers) > 0 {
+		title += " – " + strings.Join(qualifiers, ", ")
+	}
+	return title
+}
+
+func (h *FeedHandler) summary(it model.FoundItemResponse, lang string) string {
+	s := i18n.T(lang, "feed.summary",
+		it.Item.Name, h.categories.LabelIn(it.Item.Category, lang), it.Item.Date, it.Item.Location,
+		it.Municipality.Name, it.Pickup.Location)
+	if it.Item.Description != "" {
+		s += " " + it.Item.Description
+	}
+	return s
+}
+
+func feedTitle(it model.FoundItemResponse) string {
+	return it.Item.Name + " – " + it.Municipality.Name
+}
+
+// feedUpdated is the newest UpdatedAt of the items, or now for an empty
+// feed.
+func feedUpdated(items []model.FoundItemResponse) time.Time {
+	var latest time.Time
+	for _, it := range items {
+		if t, err := time.Parse(time.RFC3339, it.UpdatedAt); err == nil && t.After(latest) {
+			latest = t
+		}
+	}
+	if latest.IsZero() {
+		return time.Now().UTC().Truncate(time.Second)
+	}
+	return latest.UTC()
+}
+
+// notModified implements If-None-Match and, when that header is absent,
+// If-Modified-Since.
+func notModified(c *gin.Context, etag string, lastModified time.Time) bool {
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+		for _, t := range strings.Split(inm, ",") {
+			t = strings.TrimSpace(t)
+			if t == "*" || strings.TrimPrefix(t, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := c.GetHeader("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		if t, err := http.ParseTime(ims); err == nil && !lastModified.Truncate(time.Second).After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// feedQuery returns the feed query string selecting the same items as the
+// public list page filters.
+func feedQuery(f model.ListParams) string {
+	q := url.Values{}
+	if f.Category != "" {
+		q.Set("category", f.Category)
+	}
+	if f.Municipality != "" {
+		q.Set("municipality", f.Municipality)
+	}
+	if f.Status != "" {
+		q.Set("status", f.Status)
+	}
+	if f.Search != "" {
+		q.Set("search", f.Search)
+	}
+	if len(q) == 0 {
+		return ""
+	}
+	return "?" + q.Encode()
+}
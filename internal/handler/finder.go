@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kacperfilipiuk/zguba-gov/internal/finder"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+	"github.com/kacperfilipiuk/zguba-gov/internal/repository"
+)
+
+// FinderHandler gives the owning office access to the finder data of its
+// items. Requests carry the office token (see the office-token command) as
+// a bearer token; it must match the e-mail address the item was registered
+// with, which, unlike the item's contact address, cannot be changed.
+type FinderHandler struct {
+	repo   *repository.FoundItemRepo
+	cipher *finder.Cipher
+}
+
+func NewFinderHandler(repo *repository.FoundItemRepo, cipher *finder.Cipher) *FinderHandler {
+	return &FinderHandler{repo: repo, cipher: cipher}
+}
+
+func (h *FinderHandler) Get(c *gin.Context) {
+	item := h.authorize(c)
+	if item == nil {
+		return
+	}
+	resp, err := h.repo.GetFinder(item.ID)
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *FinderHandler) Put(c *gin.Context) {
+	item := h.authorize(c)
+	if item == nil {
+		return
+	}
+	var f model.Finder
+	if err := c.ShouldBindJSON(&f); err != nil {
+		jsonErr(c, http.StatusBadRequest, err)
+		return
+	}
+	if err := f.Validate(); err != nil {
+		jsonErr(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err := h.repo.SetFinder(item.ID, &f); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			jsonError(c, http.StatusNotFound, "api.item_not_found")
+			return
+		}
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	h.Get(c)
+}
+
+// Delete erases the finder data on request of the data subject.
+func (h *FinderHandler) Delete(c *gin.Context) {
+	item := h.authorize(c)
+	if item == nil {
+		return
+	}
+	if err := h.repo.EraseFinder(item.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			jsonError(c, http.StatusNotFound, "api.item_not_found")
+			return
+		}
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// authorize loads the item and checks the office token, writing the error
+// response and returning nil when access is denied.
+func (h *FinderHandler) authorize(c *gin.Context) *model.FoundItem {
+	if h.cipher == nil {
+		jsonErr(c, http.StatusServiceUnavailable, repository.ErrFinderDisabled)
+		return nil
+	}
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		c.Header("WWW-Authenticate", `Bearer realm="zguba-gov"`)
+		jsonError(c, http.StatusUnauthorized, "api.token_required")
+		return nil
+	}
+
+	item, err := h.repo.GetByID(c.Param("id"))
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return nil
+	}
+	if item == nil {
+		jsonError(c, http.StatusNotFound, "api.item_not_found")
+		return nil
+	}
+	office, err := h.repo.RegisteredBy(item.ID)
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return nil
+	}
+	if !h.cipher.CheckOfficeToken(office, strings.TrimSpace(token)) {
+		jsonError(c, http.StatusForbidden, "api.finder_forbidden")
+		return nil
+	}
+	return item
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kacperfilipiuk/zguba-gov/internal/geo"
+	"github.com/kacperfilipiuk/zguba-gov/internal/i18n"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+)
+
+// geoJSONGeometry is a GeoJSON (RFC 7946) point, the form OData also uses
+// for Edm.GeographyPoint values.
+type geoJSONGeometry struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type geoJSONFeature struct {
+	Type       string                  `json:"type"`
+	ID         string                  `json:"id"`
+	Geometry   geoJSONGeometry         `json:"geometry"`
+	Properties model.FoundItemResponse `json:"properties"`
+}
+
+func geoJSONPoint(p *model.GeoPoint) *geoJSONGeometry {
+	if p == nil {
+		return nil
+	}
+	return &geoJSONGeometry{Type: "Point", Coordinates: [2]float64{p.Lon, p.Lat}}
+}
+
+// GeoJSON serves the items matching the list filters that have a position
+// as a GeoJSON feature collection, placed where they were found.
+func (h *APIHandler) GeoJSON(c *gin.Context) {
+	params, err := listFilters(c)
+	if err != nil {
+		jsonErr(c, http.StatusBadRequest, err)
+		return
+	}
+	params.Located = true
+
+	c.Header("Content-Type", "application/geo+json")
+	c.Status(http.StatusOK)
+	_, _ = c.Writer.WriteString(`{"type":"FeatureCollection","features":[`)
+	enc := json.NewEncoder(c.Writer)
+	first := true
+	err = h.repo.Each(params, func(item model.FoundItem) error {
+		resp := item.ToResponse()
+		if !first {
+			_, _ = c.Writer.WriteString(",")
+		}
+		first = false
+		return enc.Encode(geoJSONFeature{Type: "Feature", ID: resp.ID, Geometry: *geoJSONPoint(resp.Item.Geo), Properties: resp})
+	})
+	if err != nil {
+		// Headers are already sent, so the client only sees a truncated body.
+		_ = c.Error(err)
+		log.Printf("geojson: %v", err)
+		return
+	}
+	_, _ = c.Writer.WriteString("]}\n")
+}
+
+// clusterPixels is the size of the map grid cells items are grouped in.
+const clusterPixels = 60
+
+// maxClusterZoom is the zoom from which the grid stops getting finer.
+const maxClusterZoom = 18
+
+// maxClusters caps the number of features of a clustered map view.
+const maxClusters = 2000
+
+type clusterProperties struct {
+	Cluster bool `json:"cluster"`
+	Count   int  `json:"count"`
+	// Stacked clusters hold items at one position, which zooming in does
+	// not split.
+	Stacked bool `json:"stacked,omitempty"`
+	// The item of a single-item cell.
+	ID            string `json:"id,omitempty"`
+	Name          string `json:"name,omitempty"`
+	Category      string `json:"category,omitempty"`
+	CategoryLabel string `json:"categoryLabel,omitempty"`
+	Date          string `json:"date,omitempty"`
+	URL           string `json:"url,omitempty"`
+}
+
+type clusterFeature struct {
+	Type       string            `json:"type"`
+	Geometry   geoJSONGeometry   `json:"geometry"`
+	Properties clusterProperties `json:"properties"`
+}
+
+// Clusters serves the located items matching the list filters for a map at
+// the given zoom: items close together on screen are grouped into one
+// feature with their count, single items carry their name and page link.
+// Only the largest maxClusters groups are returned.
+func (h *APIHandler) Clusters(c *gin.Context) {
+	params, err := listFilters(c)
+	if err != nil {
+		jsonErr(c, http.StatusBadRequest, err)
+		return
+	}
+	zoom, err := strconv.Atoi(c.DefaultQuery("zoom", "6"))
+	if err != nil || zoom < 0 || zoom > 22 {
+		jsonError(c, http.StatusBadRequest, "api.invalid_zoom", 0, 22)
+		return
+	}
+
+	cellLon, cellLat := geo.GridCell(min(zoom, maxClusterZoom), clusterPixels)
+	cells, err := h.repo.MapCells(params, cellLon, cellLat, maxClusters)
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	lang := locale(c, i18n.Default)
+	features := make([]clusterFeature, 0, len(cells))
+	for _, cell := range cells {
+		f := clusterFeature{Type: "Feature", Geometry: *geoJSONPoint(&cell.Point), Properties: clusterProperties{Count: cell.Count}}
+		if cell.Count > 1 {
+			f.Properties.Cluster, f.Properties.Stacked = true, cell.Stacked
+		} else {
+			f.Properties.ID = cell.ID
+			f.Properties.Name = cell.Name
+			f.Properties.Category = cell.Category
+			f.Properties.CategoryLabel = h.categories.LabelIn(cell.Category, lang)
+			f.Properties.Date = cell.Date
+			f.Properties.URL = "/rzeczy/" + cell.ID
+		}
+		features = append(features, f)
+	}
+	c.Header("Cache-Control", "public, max-age=60")
+	c.Header("Content-Type", "application/geo+json")
+	c.JSON(http.StatusOK, gin.H{"type": "FeatureCollection", "features": features})
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kacperfilipiuk/zguba-gov/internal/i18n"
+	"github.com/kacperfilipiuk/zguba-gov/internal/importer"
+)
+
+// maxUploadSize caps the size of an uploaded import file, in bytes.
+const maxUploadSize = 32 << 20
+
+type ImportHandler struct {
+	importer *importer.Importer
+}
+
+func NewImportHandler(imp *importer.Importer) *ImportHandler {
+	return &ImportHandler{importer: imp}
+}
+
+func (h *ImportHandler) Import(c *gin.Context) {
+	f, format, ok := upload(c)
+	if !ok {
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	mapping := map[string]string{}
+	if raw := c.PostForm("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			jsonError(c, http.StatusBadRequest, "api.invalid_mapping")
+			return
+		}
+	}
+
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dryRun", c.PostForm("dryRun")))
+
+	rows, err := importer.Read(f, format, mapping)
+	if err != nil {
+		jsonErr(c, http.StatusBadRequest, err)
+		return
+	}
+
+	result, err := h.importer.Import(rows, dryRun)
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	importResult(c, result)
+}
+
+// upload opens the uploaded file and determines its format, writing the
+// error response when there is none. Requests larger than maxUploadSize are
+// rejected without being read in full.
+func upload(c *gin.Context) (multipart.File, string, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadSize)
+	fh, err := c.FormFile("file")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		jsonError(c, http.StatusRequestEntityTooLarge, "api.file_too_large", maxUploadSize>>20)
+		return nil, "", false
+	}
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "api.file_required")
+		return nil, "", false
+	}
+	format := c.PostForm("format")
+	if format == "" {
+		format = importer.FormatFromFilename(fh.Filename)
+	}
+	f, err := fh.Open()
+	if err != nil {
+		jsonErr(c, http.StatusBadRequest, err)
+		return nil, "", false
+	}
+	return f, format, true
+}
+
+func importResult(c *gin.Context, result *importer.Result) {
+	result.Localize(locale(c, i18n.English))
+	switch {
+	case len(result.Errors) > 0:
+		c.JSON(http.StatusUnprocessableEntity, result)
+	case result.DryRun:
+		c.JSON(http.StatusOK, result)
+	default:
+		c.JSON(http.StatusCreated, result)
+	}
+}
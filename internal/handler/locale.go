@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kacperfilipiuk/zguba-gov/internal/i18n"
+)
+
+// langCookie remembers the language chosen with the lang query parameter.
+const langCookie = "lang"
+
+// localeKey is the context key of the language of the request.
+const localeKey = "locale"
+
+// Locale picks the language of the request: the lang query parameter,
+// which is remembered in a cookie, then the cookie, then Accept-Language.
+// Requests that ask for no supported language get responses as before
+// translations existed: pages in Polish, API errors in English.
+func Locale(c *gin.Context) {
+	lang := c.Query("lang")
+	if i18n.Supported(lang) {
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(langCookie, lang, 365*24*3600, "/", "", c.Request.TLS != nil, false)
+	} else if v, err := c.Cookie(langCookie); err == nil && i18n.Supported(v) {
+		lang = v
+	} else if v, ok := i18n.Match(c.GetHeader("Accept-Language")); ok {
+		lang = v
+	} else {
+		lang = ""
+	}
+	if lang != "" {
+		c.Set(localeKey, lang)
+	}
+	c.Writer.Header().Add("Vary", "Accept-Language, Cookie")
+	c.Next()
+}
+
+// locale returns the language of the request, or fallback when the client
+// asked for none.
+func locale(c *gin.Context, fallback string) string {
+	if lang := c.GetString(localeKey); lang != "" {
+		return lang
+	}
+	return fallback
+}
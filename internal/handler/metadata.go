@@ -1,93 +1,412 @@
 package handler
 
 import (
+	"bytes"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/kacperfilipiuk/zguba-gov/internal/export"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+	"github.com/kacperfilipiuk/zguba-gov/internal/municipality"
+	"github.com/kacperfilipiuk/zguba-gov/internal/rdf"
+	"github.com/kacperfilipiuk/zguba-gov/internal/repository"
 )
 
-type MetadataHandler struct{}
+// EU Publications Office authority tables used by DCAT-AP PL.
+const (
+	euFileType  = "http://publications.europa.eu/resource/authority/file-type/"
+	euLanguage  = "http://publications.europa.eu/resource/authority/language/"
+	euFrequency = "http://publications.europa.eu/resource/authority/frequency/"
+	euDataTheme = "http://publications.europa.eu/resource/authority/data-theme/"
+	euCountry   = "http://publications.europa.eu/resource/authority/country/"
+	ianaMedia   = "https://www.iana.org/assignments/media-types/"
+	licenseURL  = "http://creativecommons.org/licenses/by/4.0/"
+	odataSpec   = "http://docs.oasis-open.org/odata/odata/v4.0/"
+	datasetID   = "found-items"
+)
+
+var catalogPrefixes = map[string]string{
+	"dcat":  rdf.DCAT,
+	"dct":   rdf.DCT,
+	"foaf":  rdf.FOAF,
+	"vcard": rdf.VCARD,
+	"void":  rdf.VOID,
+}
+
+// MetadataHandler serves the DCAT-AP PL catalog harvested by dane.gov.pl.
+type MetadataHandler struct {
+	repo      *repository.FoundItemRepo
+	munSvc    *municipality.Service
+	baseURL   string
+	publisher string
+	email     string
+	routes    map[string]bool
+}
+
+func NewMetadataHandler(repo *repository.FoundItemRepo, munSvc *municipality.Service, baseURL, publisher, email string) *MetadataHandler {
+	return &MetadataHandler{repo: repo, munSvc: munSvc, baseURL: baseURL, publisher: publisher, email: email}
+}
+
+// UseRoutes records the registered GET routes. Only distributions whose
+// endpoint is among them are published.
+func (h *MetadataHandler) UseRoutes(routes gin.RoutesInfo) {
+	h.routes = map[string]bool{}
+	for _, r := range routes {
+		if r.Method == http.MethodGet {
+			h.routes[r.Path] = true
+		}
+	}
+}
 
-func NewMetadataHandler() *MetadataHandler {
-	return &MetadataHandler{}
+// distribution is one way of getting the dataset.
+type distribution struct {
+	id         string
+	title      string
+	path       string
+	query      string
+	fileType   string
+	mediaType  string
+	conformsTo string
+	// download is set when the URL returns the whole dataset as a file.
+	download bool
 }
 
+var exportFormatNames = map[string]string{
+	export.FormatCSV:     "CSV",
+	export.FormatXLSX:    "XLSX",
+	export.FormatJSONL:   "JSON Lines",
+	export.FormatParquet: "Parquet",
+}
+
+var exportFileTypes = map[string]string{
+	export.FormatCSV:     "CSV",
+	export.FormatXLSX:    "XLSX",
+	export.FormatParquet: "PARQUET",
+}
+
+func (h *MetadataHandler) distributions() []distribution {
+	all := []distribution{
+		{id: "json-api", title: "JSON API", path: "/api/found-items", fileType: "JSON", mediaType: "application/json"},
+		{id: "odata", title: "OData API", path: "/odata/FoundItems", fileType: "JSON", mediaType: "application/json",
+			conformsTo: odataSpec},
+	}
+	for _, f := range export.Formats {
+		all = append(all, distribution{
+			id:        "export-" + f,
+			title:     "Eksport " + exportFormatNames[f],
+			path:      "/api/found-items/export",
+			query:     "?format=" + f,
+			fileType:  exportFileTypes[f],
+			mediaType: export.MediaType(f),
+			download:  true,
+		})
+	}
+	all = append(all,
+		distribution{id: "atom", title: "Kanał Atom", path: "/feeds/found-items.atom", fileType: "ATOM", mediaType: "application/atom+xml"},
+		distribution{id: "rss", title: "Kanał RSS", path: "/feeds/found-items.rss", fileType: "RSS", mediaType: "application/rss+xml"},
+	)
+	return h.registered(all)
+}
+
+// officeDistributions are the distributions of one office's dataset: the
+// JSON API, CSV export and OData filtered to the office's items.
+func (h *MetadataHandler) officeDistributions(id, email string) []distribution {
+	office := url.Values{"office": {email}}.Encode()
+	csv := url.Values{"office": {email}, "format": {export.FormatCSV}}.Encode()
+	odata := url.Values{"$filter": {"municipality_email eq '" + email + "'"}}.Encode()
+	return h.registered([]distribution{
+		{id: id + "-json", title: "JSON API", path: "/api/found-items", query: "?" + office, fileType: "JSON", mediaType: "application/json"},
+		{id: id + "-csv", title: "Eksport CSV", path: "/api/found-items/export", query: "?" + csv, fileType: "CSV",
+			mediaType: export.MediaType(export.FormatCSV), download: true},
+		{id: id + "-odata", title: "OData API", path: "/odata/FoundItems", query: "?" + odata, fileType: "JSON", mediaType: "application/json",
+			conformsTo: odataSpec},
+	})
+}
+
+func (h *MetadataHandler) registered(all []distribution) []distribution {
+	dists := make([]distribution, 0, len(all))
+	for _, d := range all {
+		if h.routes[d.path] {
+			dists = append(dists, d)
+		}
+	}
+	return dists
+}
+
+// dataset is one dcat:Dataset of the catalog.
+type dataset struct {
+	id            string
+	title         string
+	description   string
+	publisher     string
+	email         string
+	landingPage   string
+	summary       model.DatasetSummary
+	distributions []distribution
+}
+
+// datasets returns the register-wide dataset followed by one dataset per
+// office whose municipality is in the territorial dataset. Offices are
+// told apart by e-mail address; the dataset is named by TERYT code.
+func (h *MetadataHandler) datasets() ([]dataset, error) {
+	all, err := h.repo.Summary(model.ListParams{})
+	if err != nil {
+		return nil, err
+	}
+	offices, err := h.repo.OfficeSummaries()
+	if err != nil {
+		return nil, err
+	}
+
+	out := []dataset{{
+		id:            datasetID,
+		title:         "Rzeczy znalezione",
+		description:   "Bieżąca lista przedmiotów znalezionych i przechowywanych przez jednostki administracji publicznej.",
+		publisher:     h.publisher,
+		email:         h.email,
+		landingPage:   h.baseURL + "/rzeczy",
+		summary:       all,
+		distributions: h.distributions(),
+	}}
+	seen := map[string]int{}
+	for _, o := range offices {
+		units := h.munSvc.Match(o.Name, o.Type, o.Email)
+		if len(units) != 1 {
+			continue
+		}
+		unit := units[0]
+		id := string(unit.ID)
+		if seen[id]++; seen[id] > 1 {
+			id += "-" + strconv.Itoa(seen[id])
+		}
+		publisher := unit.OfficeName
+		if publisher == "" {
+			publisher = unit.Name
+		}
+		out = append(out, dataset{
+			id:            id,
+			title:         "Rzeczy znalezione – " + unit.Name,
+			description:   fmt.Sprintf("Przedmioty znalezione zarejestrowane przez: %s (TERYT %s).", publisher, unit.ID),
+			publisher:     publisher,
+			email:         o.Email,
+			landingPage:   h.baseURL + "/rzeczy?" + url.Values{"gmina": {o.Name}}.Encode(),
+			summary:       o.DatasetSummary,
+			distributions: h.officeDistributions(id, o.Email),
+		})
+	}
+	return out, nil
+}
+
+func (h *MetadataHandler) catalogIRI() rdf.Term { return rdf.IRI(h.baseURL + "/metadata") }
+func (h *MetadataHandler) datasetIRI(id string) rdf.Term {
+	return rdf.IRI(h.baseURL + "/metadata/dataset/" + id)
+}
+func (h *MetadataHandler) distributionIRI(id string) rdf.Term {
+	return rdf.IRI(h.baseURL + "/metadata/distribution/" + id)
+}
+
+// graph builds the whole catalog.
+func (h *MetadataHandler) graph(datasets []dataset) *rdf.Graph {
+	all := datasets[0].summary
+	g := rdf.NewGraph(catalogPrefixes)
+
+	catalog := h.catalogIRI()
+	g.Type(catalog, rdf.DCAT+"Catalog")
+	g.Add(catalog, rdf.DCT+"title", rdf.LangLiteral("Katalog rzeczy znalezionych", "pl"))
+	g.Add(catalog, rdf.DCT+"description", rdf.LangLiteral("Rzeczy znalezione zarejestrowane przez jednostki administracji publicznej w Polsce.", "pl"))
+	g.Add(catalog, rdf.DCT+"publisher", agent(g, h.publisher, h.email))
+	g.Add(catalog, rdf.FOAF+"homepage", rdf.IRI(h.baseURL+"/"))
+	g.Add(catalog, rdf.DCT+"language", rdf.IRI(euLanguage+"POL"))
+	g.Add(catalog, rdf.DCT+"license", rdf.IRI(licenseURL))
+	addDate(g, catalog, rdf.DCT+"issued", all.Issued)
+	addDate(g, catalog, rdf.DCT+"modified", all.Modified)
+	for _, d := range datasets {
+		g.Add(catalog, rdf.DCAT+"dataset", h.datasetIRI(d.id))
+	}
+
+	for _, d := range datasets {
+		h.addDataset(g, d)
+	}
+	return g
+}
+
+func (h *MetadataHandler) addDataset(g *rdf.Graph, d dataset) {
+	ds := h.datasetIRI(d.id)
+	g.Type(ds, rdf.DCAT+"Dataset")
+	g.Add(ds, rdf.DCT+"identifier", rdf.Literal(d.id))
+	g.Add(ds, rdf.DCT+"title", rdf.LangLiteral(d.title, "pl"))
+	g.Add(ds, rdf.DCT+"description", rdf.LangLiteral(d.description, "pl"))
+	for _, kw := range []string{"rzeczy znalezione", "biuro rzeczy znalezionych", "administracja publiczna"} {
+		g.Add(ds, rdf.DCAT+"keyword", rdf.LangLiteral(kw, "pl"))
+	}
+	g.Add(ds, rdf.DCAT+"theme", rdf.IRI(euDataTheme+"SOCI"))
+	g.Add(ds, rdf.DCT+"accrualPeriodicity", rdf.IRI(euFrequency+"DAILY"))
+	g.Add(ds, rdf.DCT+"spatial", rdf.IRI(euCountry+"POL"))
+	g.Add(ds, rdf.DCT+"language", rdf.IRI(euLanguage+"POL"))
+	g.Add(ds, rdf.DCT+"publisher", agent(g, d.publisher, d.email))
+	g.Add(ds, rdf.DCAT+"landingPage", rdf.IRI(d.landingPage))
+	addDate(g, ds, rdf.DCT+"issued", d.summary.Issued)
+	addDate(g, ds, rdf.DCT+"modified", d.summary.Modified)
+	// DCAT has no property for the number of records; VoID's is the
+	// usual stand-in.
+	g.Add(ds, rdf.VOID+"entities", rdf.Integer(d.summary.Count))
+	if d.email != "" {
+		contact := g.NewBlank()
+		g.Add(ds, rdf.DCAT+"contactPoint", contact)
+		g.Type(contact, rdf.VCARD+"Organization")
+		g.Add(contact, rdf.VCARD+"fn", rdf.Literal(d.publisher))
+		g.Add(contact, rdf.VCARD+"hasEmail", rdf.IRI("mailto:"+d.email))
+	}
+
+	for _, dist := range d.distributions {
+		iri := h.distributionIRI(dist.id)
+		url := h.baseURL + dist.path + dist.query
+		g.Add(ds, rdf.DCAT+"distribution", iri)
+		g.Type(iri, rdf.DCAT+"Distribution")
+		g.Add(iri, rdf.DCT+"identifier", rdf.Literal(dist.id))
+		g.Add(iri, rdf.DCT+"title", rdf.LangLiteral(dist.title, "pl"))
+		g.Add(iri, rdf.DCAT+"accessURL", rdf.IRI(url))
+		if dist.download {
+			g.Add(iri, rdf.DCAT+"downloadURL", rdf.IRI(url))
+		}
+		if dist.fileType != "" {
+			g.Add(iri, rdf.DCT+"format", rdf.IRI(euFileType+dist.fileType))
+		}
+		mediaType, _, _ := strings.Cut(dist.mediaType, ";")
+		g.Add(iri, rdf.DCAT+"mediaType", rdf.IRI(ianaMedia+strings.TrimSpace(mediaType)))
+		if dist.conformsTo != "" {
+			g.Add(iri, rdf.DCT+"conformsTo", rdf.IRI(dist.conformsTo))
+		}
+		g.Add(iri, rdf.DCT+"license", rdf.IRI(licenseURL))
+		addDate(g, iri, rdf.DCT+"modified", d.summary.Modified)
+	}
+}
+
+func agent(g *rdf.Graph, name, email string) rdf.Term {
+	a := g.NewBlank()
+	g.Type(a, rdf.FOAF+"Agent")
+	g.Add(a, rdf.FOAF+"name", rdf.LangLiteral(name, "pl"))
+	if email != "" {
+		g.Add(a, rdf.FOAF+"mbox", rdf.IRI("mailto:"+email))
+	}
+	return a
+}
+
+func addDate(g *rdf.Graph, s rdf.Term, p string, t time.Time) {
+	if !t.IsZero() {
+		g.Add(s, p, rdf.Typed(t.UTC().Format(time.RFC3339), rdf.XSD+"dateTime"))
+	}
+}
+
+// Catalog serves the full catalog.
 func (h *MetadataHandler) Catalog(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"@context":        "https://www.w3.org/ns/dcat",
-		"@type":           "dcat:Catalog",
-		"dct:title":       "Katalog Rzeczy Znalezionych - Zguba.gov",
-		"dct:description": "System zarządzania znalezionymi przedmiotami w jednostkach administracji publicznej w Polsce",
-		"dct:issued":      "2025-12-01T00:00:00Z",
-		"dct:modified":    time.Now().UTC().Format(time.RFC3339),
-		"dct:language":    "pl",
-		"dcat:homepage":   "https://zguba.gov",
-		"dcat:dataset": []gin.H{
-			{
-				"@type":                  "dcat:Dataset",
-				"dct:identifier":         "found-items-dataset",
-				"dct:title":              "Znalezione Rzeczy",
-				"dct:description":        "Bieżąca lista znalezionych przedmiotów zgłoszonych przez jednostki administracji publicznej",
-				"dct:license":            "http://creativecommons.org/licenses/by/4.0/",
-				"dcat:keyword":           []string{"rzeczy znalezione", "przedmioty", "administracja publiczna", "dane otwarte"},
-				"dcat:theme":             []string{"http://publications.europa.eu/resource/authority/data-theme/SOCI"},
-				"dct:accrualPeriodicity": "http://purl.org/ckan/freq/daily",
-				"dcat:distribution": []gin.H{
-					{
-						"@type":            "dcat:Distribution",
-						"dct:title":        "JSON API",
-						"dcat:accessURL":   "https://api.zguba.gov/api/found-items",
-						"dcat:downloadURL": "https://api.zguba.gov/api/found-items?limit=1000",
-						"dct:format":       "JSON",
-						"dcat:mediaType":   "application/json",
-					},
-					{
-						"@type":          "dcat:Distribution",
-						"dct:title":      "OData API",
-						"dcat:accessURL": "https://api.zguba.gov/odata",
-						"dct:format":     "OData",
-					},
-				},
-				"dcat:contactPoint": gin.H{
-					"@type":          "vcard:Organization",
-					"vcard:fn":       "Zguba.gov Support",
-					"vcard:hasEmail": "mailto:support@zguba.gov",
-				},
-			},
-		},
-		"dcat:organization": gin.H{
-			"@type":     "foaf:Organization",
-			"foaf:name": "Zguba.gov",
-		},
+	h.serve(c, func(g *rdf.Graph, _ []dataset) (*rdf.Graph, bool) { return g, true })
+}
+
+// Dataset serves the description of one dataset with its distributions.
+func (h *MetadataHandler) Dataset(c *gin.Context) {
+	id := c.Param("id")
+	h.serve(c, func(g *rdf.Graph, datasets []dataset) (*rdf.Graph, bool) {
+		for _, d := range datasets {
+			if d.id == id {
+				return g.Subgraph(h.datasetIRI(id)), true
+			}
+		}
+		jsonError(c, http.StatusNotFound, "api.dataset_not_found")
+		return nil, false
 	})
 }
 
+// Distribution serves the description of one distribution.
 func (h *MetadataHandler) Distribution(c *gin.Context) {
 	id := c.Param("id")
+	h.serve(c, func(g *rdf.Graph, datasets []dataset) (*rdf.Graph, bool) {
+		for _, d := range datasets {
+			for _, dist := range d.distributions {
+				if dist.id == id {
+					return g.Subgraph(h.distributionIRI(id)), true
+				}
+			}
+		}
+		jsonError(c, http.StatusNotFound, "api.distribution_not_found")
+		return nil, false
+	})
+}
 
-	distributions := map[string]gin.H{
-		"json-api": {
-			"@type":          "dcat:Distribution",
-			"dct:title":      "JSON API",
-			"dcat:accessURL": "https://api.zguba.gov/api/found-items",
-			"dct:format":     "JSON",
-			"dcat:mediaType": "application/json",
-			"dct:license":    "http://creativecommons.org/licenses/by/4.0/",
-		},
-		"odata": {
-			"@type":          "dcat:Distribution",
-			"dct:title":      "OData API",
-			"dcat:accessURL": "https://api.zguba.gov/odata",
-			"dct:format":     "OData",
-			"dct:license":    "http://creativecommons.org/licenses/by/4.0/",
-		},
-	}
-
-	dist, ok := distributions[id]
+// serve writes the part of the catalog selected by part in the negotiated
+// RDF serialisation, answering conditional requests from the newest item.
+// part reports false when it has already responded.
+func (h *MetadataHandler) serve(c *gin.Context, part func(*rdf.Graph, []dataset) (*rdf.Graph, bool)) {
+	mediaType := rdfMediaType(c)
+	if mediaType == "" {
+		jsonError(c, http.StatusNotAcceptable, "api.not_acceptable", rdf.MediaJSONLD+", "+rdf.MediaTurtle+", "+rdf.MediaRDFXML)
+		return
+	}
+
+	datasets, err := h.datasets()
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	g, ok := part(h.graph(datasets), datasets)
 	if !ok {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Distribution not found"})
 		return
 	}
-	c.JSON(http.StatusOK, dist)
+
+	// Every change to an item moves the newest UpdatedAt and every delete
+	// lowers the count, so the pair identifies the catalog contents.
+	summary := datasets[0].summary
+	etag := fmt.Sprintf(`W/"%d-%d-%s"`, summary.Count, summary.Modified.UnixNano(), strings.TrimPrefix(mediaType, "application/"))
+	c.Header("Vary", "Accept")
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "public, max-age=300")
+	if !summary.Modified.IsZero() {
+		c.Header("Last-Modified", summary.Modified.UTC().Format(http.TimeFormat))
+	}
+	if notModified(c, etag, summary.Modified) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := g.Write(&buf, mediaType); err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.Data(http.StatusOK, mediaType+"; charset=utf-8", buf.Bytes())
+}
+
+var rdfFormats = map[string]string{
+	"jsonld": rdf.MediaJSONLD,
+	"ttl":    rdf.MediaTurtle,
+	"turtle": rdf.MediaTurtle,
+	"rdf":    rdf.MediaRDFXML,
+	"xml":    rdf.MediaRDFXML,
+}
+
+// rdfMediaType picks the serialisation from ?format= or the Accept header.
+// Plain JSON and XML requests get JSON-LD and RDF/XML, and a missing or
+// wildcard Accept gets JSON-LD. It returns "" when nothing acceptable is
+// offered.
+func rdfMediaType(c *gin.Context) string {
+	if f := c.Query("format"); f != "" {
+		return rdfFormats[f]
+	}
+	if c.GetHeader("Accept") == "" {
+		return rdf.MediaJSONLD
+	}
+	switch c.NegotiateFormat(rdf.MediaJSONLD, rdf.MediaTurtle, rdf.MediaRDFXML, "application/json", "application/xml", "text/xml", "text/html") {
+	case rdf.MediaJSONLD, "application/json", "text/html":
+		return rdf.MediaJSONLD
+	case rdf.MediaTurtle:
+		return rdf.MediaTurtle
+	case rdf.MediaRDFXML, "application/xml", "text/xml":
+		return rdf.MediaRDFXML
+	}
+	return ""
 }
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+	"github.com/kacperfilipiuk/zguba-gov/internal/repository"
+)
+
+// NotificationHandler serves the outbox view and the per-office
+// notification settings.
+type NotificationHandler struct {
+	outbox   *repository.OutboxRepo
+	settings *repository.OfficeNotificationsRepo
+}
+
+func NewNotificationHandler(outbox *repository.OutboxRepo, settings *repository.OfficeNotificationsRepo) *NotificationHandler {
+	return &NotificationHandler{outbox: outbox, settings: settings}
+}
+
+func (h *NotificationHandler) Outbox(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", model.OutboxPending, model.OutboxSent, model.OutboxFailed:
+	default:
+		jsonError(c, http.StatusBadRequest, "api.invalid_status", "pending, sent, failed")
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	msgs, err := h.outbox.List(status, limit)
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	if msgs == nil {
+		msgs = []model.OutboxMessage{}
+	}
+	c.JSON(http.StatusOK, msgs)
+}
+
+// Retry puts a failed message back in the queue.
+func (h *NotificationHandler) Retry(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "api.invalid_message_id")
+		return
+	}
+	err = h.outbox.Retry(id)
+	if err == sql.ErrNoRows {
+		jsonError(c, http.StatusNotFound, "api.message_not_found")
+		return
+	}
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *NotificationHandler) GetSettings(c *gin.Context) {
+	s, err := h.settings.Get(c.Param("email"))
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, s)
+}
+
+func (h *NotificationHandler) PutSettings(c *gin.Context) {
+	email := c.Param("email")
+	if !strings.Contains(email, "@") {
+		jsonError(c, http.StatusBadRequest, "api.invalid_office_email")
+		return
+	}
+	var s model.OfficeNotifications
+	if err := c.ShouldBindJSON(&s); err != nil {
+		jsonErr(c, http.StatusBadRequest, err)
+		return
+	}
+	s.Email = email
+	if err := h.settings.Save(&s); err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, s)
+}
@@ -31,13 +31,13 @@ func (h *ODataHandler) Query(c *gin.Context) {
 
 	filterClause, err := odata.ParseFilter(filter)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		jsonErr(c, http.StatusBadRequest, err)
 		return
 	}
 
 	orderClause := odata.ParseOrderBy(orderby)
 
-	query := "SELECT id, municipality_name, municipality_type, municipality_email, item_name, item_category, item_date, item_location, item_status, item_description, pickup_deadline, pickup_location, pickup_hours, pickup_contact, categories, created_at, updated_at FROM found_items"
+	query := "SELECT " + repository.ItemColumns + " FROM found_items"
 	var args []any
 
 	if filterClause.Where != "" {
@@ -50,7 +50,7 @@ func (h *ODataHandler) Query(c *gin.Context) {
 
 	items, err := h.repo.QueryRaw(query, args...)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		jsonErr(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -68,11 +68,14 @@ func (h *ODataHandler) Query(c *gin.Context) {
 			"item_location":      resp.Item.Location,
 			"item_status":        resp.Item.Status,
 			"item_description":   resp.Item.Description,
+			"item_geo":           geoJSONPoint(resp.Item.Geo),
 			"pickup_deadline":    resp.Pickup.Deadline,
 			"pickup_location":    resp.Pickup.Location,
 			"pickup_hours":       resp.Pickup.Hours,
 			"pickup_contact":     resp.Pickup.Contact,
+			"pickup_geo":         geoJSONPoint(resp.Pickup.Geo),
 			"categories":         resp.Categories,
+			"attributes":         resp.Attributes,
 			"created_at":         resp.CreatedAt,
 			"updated_at":         resp.UpdatedAt,
 		})
@@ -118,14 +121,18 @@ const odataMetadataXML = `<?xml version="1.0" encoding="utf-8"?>
         <Property Name="item_location" Type="Edm.String"/>
         <Property Name="item_status" Type="Edm.String"/>
         <Property Name="item_description" Type="Edm.String"/>
+        <Property Name="item_geo" Type="Edm.GeographyPoint" SRID="4326"/>
         <Property Name="pickup_deadline" Type="Edm.Int32"/>
         <Property Name="pickup_location" Type="Edm.String"/>
         <Property Name="pickup_hours" Type="Edm.String"/>
         <Property Name="pickup_contact" Type="Edm.String"/>
+        <Property Name="pickup_geo" Type="Edm.GeographyPoint" SRID="4326"/>
         <Property Name="categories" Type="Collection(Edm.String)"/>
+        <Property Name="attributes" Type="ZgubaGov.Attributes"/>
         <Property Name="created_at" Type="Edm.DateTimeOffset"/>
         <Property Name="updated_at" Type="Edm.DateTimeOffset"/>
       </EntityType>
+      <ComplexType Name="Attributes" OpenType="true"/>
       <EntityContainer Name="Default">
         <EntitySet Name="FoundItems" EntityType="ZgubaGov.FoundItem"/>
       </EntityContainer>
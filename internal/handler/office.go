@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kacperfilipiuk/zguba-gov/internal/directory"
+	"github.com/kacperfilipiuk/zguba-gov/internal/finder"
+	"github.com/kacperfilipiuk/zguba-gov/internal/importer"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+)
+
+// OfficeHandler serves the office directory. An office edits its own entry
+// with its office token (see the office-token command) for the verified
+// address currently in the directory; entries without one are set up by
+// an operator with the import command.
+type OfficeHandler struct {
+	dir      *directory.Directory
+	cipher   *finder.Cipher
+	importer *importer.Importer
+}
+
+func NewOfficeHandler(dir *directory.Directory, cipher *finder.Cipher, imp *importer.Importer) *OfficeHandler {
+	return &OfficeHandler{dir: dir, cipher: cipher, importer: imp}
+}
+
+func (h *OfficeHandler) List(c *gin.Context) {
+	offices, err := h.dir.Entries()
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, offices)
+}
+
+// Get returns the contact of a unit's office, from the directory or, with
+// source "dataset" or "guessed", from the territorial dataset.
+func (h *OfficeHandler) Get(c *gin.Context) {
+	contact, err := h.dir.Lookup(c.Param("teryt"))
+	if errors.Is(err, directory.ErrUnknownUnit) {
+		jsonError(c, http.StatusNotFound, "api.unit_not_found")
+		return
+	}
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, contact)
+}
+
+func (h *OfficeHandler) Put(c *gin.Context) {
+	if !h.authorize(c) {
+		return
+	}
+	var body model.OfficeContactSave
+	if err := c.ShouldBindJSON(&body); err != nil {
+		jsonErr(c, http.StatusBadRequest, err)
+		return
+	}
+	contact, err := h.dir.Save(c.Param("teryt"), body)
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, contact)
+}
+
+func (h *OfficeHandler) Delete(c *gin.Context) {
+	if !h.authorize(c) {
+		return
+	}
+	err := h.dir.Delete(c.Param("teryt"))
+	if err == sql.ErrNoRows {
+		jsonError(c, http.StatusNotFound, "api.office_not_found")
+		return
+	}
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Import loads directory entries from a CSV or XLSX file (multipart field
+// "file"). As with Put, the office token must be that of the current
+// verified address of every unit in the file. Nothing is saved when any row
+// is invalid or not allowed.
+func (h *OfficeHandler) Import(c *gin.Context) {
+	token, ok := h.token(c)
+	if !ok {
+		return
+	}
+	f, format, ok := upload(c)
+	if !ok {
+		return
+	}
+	defer func() { _ = f.Close() }()
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dryRun", c.PostForm("dryRun")))
+
+	records, err := importer.ReadRecords(f, format)
+	if err != nil {
+		jsonErr(c, http.StatusBadRequest, err)
+		return
+	}
+	result, err := h.importer.ImportOffices(records, dryRun, func(current model.OfficeContact) bool {
+		return h.allowed(current, token)
+	})
+	if errors.Is(err, importer.ErrNoTerytColumn) {
+		jsonErr(c, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	importResult(c, result)
+}
+
+// authorize checks the office token against the verified address of the
+// unit's office, writing the error response when access is denied.
+func (h *OfficeHandler) authorize(c *gin.Context) bool {
+	token, ok := h.token(c)
+	if !ok {
+		return false
+	}
+
+	contact, err := h.dir.Lookup(c.Param("teryt"))
+	if errors.Is(err, directory.ErrUnknownUnit) {
+		jsonError(c, http.StatusNotFound, "api.unit_not_found")
+		return false
+	}
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return false
+	}
+	if !h.allowed(contact, token) {
+		jsonError(c, http.StatusForbidden, "api.office_forbidden")
+		return false
+	}
+	return true
+}
+
+// token returns the office token of the request, writing the error
+// response when there is none.
+func (h *OfficeHandler) token(c *gin.Context) (string, bool) {
+	if h.cipher == nil {
+		jsonError(c, http.StatusServiceUnavailable, "api.tokens_disabled")
+		return "", false
+	}
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	token = strings.TrimSpace(token)
+	if !ok || token == "" {
+		c.Header("WWW-Authenticate", `Bearer realm="zguba-gov"`)
+		jsonError(c, http.StatusUnauthorized, "api.token_required")
+		return "", false
+	}
+	return token, true
+}
+
+// allowed reports whether token is the office token of the verified address
+// of contact.
+func (h *OfficeHandler) allowed(contact model.OfficeContact, token string) bool {
+	return contact.EmailVerified && h.cipher.CheckOfficeToken(contact.Email, token)
+}
@@ -3,47 +3,125 @@ package handler
 import (
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io/fs"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/kacperfilipiuk/zguba-gov/internal/directory"
+	"github.com/kacperfilipiuk/zguba-gov/internal/events"
+	"github.com/kacperfilipiuk/zguba-gov/internal/form"
+	"github.com/kacperfilipiuk/zguba-gov/internal/geo"
+	"github.com/kacperfilipiuk/zguba-gov/internal/i18n"
 	"github.com/kacperfilipiuk/zguba-gov/internal/model"
 	"github.com/kacperfilipiuk/zguba-gov/internal/municipality"
+	"github.com/kacperfilipiuk/zguba-gov/internal/notify"
 	"github.com/kacperfilipiuk/zguba-gov/internal/repository"
 )
 
 type PagesHandler struct {
-	tmpl   *template.Template
-	repo   *repository.FoundItemRepo
-	munSvc *municipality.Service
+	// tmpl and forms hold the templates and the form definition per
+	// language.
+	tmpl    map[string]*template.Template
+	forms   map[string]*form.Definition
+	repo    *repository.FoundItemRepo
+	drafts  *repository.DraftRepo
+	cats    *repository.CategoryRepo
+	munSvc  *municipality.Service
+	dir     *directory.Directory
+	geocode geo.Geocoder
+	notify  *notify.Service
+	events  *events.Bus
+	form    *form.Definition
+	baseURL string
+	tiles   MapTiles
 }
 
-func NewPagesHandler(templateFS fs.FS, repo *repository.FoundItemRepo, munSvc *municipality.Service) (*PagesHandler, error) {
+func NewPagesHandler(templateFS fs.FS, repo *repository.FoundItemRepo, drafts *repository.DraftRepo, cats *repository.CategoryRepo, munSvc *municipality.Service, dir *directory.Directory, geocoder geo.Geocoder, notifier *notify.Service, bus *events.Bus, def *form.Definition, baseURL string, tiles MapTiles) (*PagesHandler, error) {
+	h := &PagesHandler{tmpl: map[string]*template.Template{}, forms: map[string]*form.Definition{}, repo: repo, drafts: drafts, cats: cats, munSvc: munSvc, dir: dir, geocode: geocoder, notify: notifier, events: bus, form: def, baseURL: baseURL, tiles: tiles}
+	for _, lang := range i18n.Languages {
+		tmpl, err := parseTemplates(templateFS, lang, cats)
+		if err != nil {
+			return nil, fmt.Errorf("parse templates: %w", err)
+		}
+		h.tmpl[lang] = tmpl
+		h.forms[lang] = def.Localize(lang)
+	}
+
+	tmpl := h.tmpl[i18n.Default]
+	for i := range def.Steps {
+		if name := def.Steps[i].StepTemplate(); tmpl.Lookup(name) == nil {
+			return nil, fmt.Errorf("step %d: no template %s", i+1, name)
+		}
+	}
+	for _, f := range def.Fields() {
+		if tmpl.Lookup(f.FieldTemplate()) == nil {
+			return nil, fmt.Errorf("field %s: no template for type %q", f.Name, f.Type)
+		}
+	}
+
+	return h, nil
+}
+
+// parseTemplates parses the page templates with the template functions
+// for lang.
+func parseTemplates(templateFS fs.FS, lang string, cats *repository.CategoryRepo) (*template.Template, error) {
+	var tmpl *template.Template
 	funcMap := template.FuncMap{
 		"json": func(v any) template.JS {
 			b, _ := json.MarshalIndent(v, "", "  ")
 			return template.JS(b)
 		},
-		"seq": func(n int) []int {
-			s := make([]int, n)
-			for i := range s {
-				s[i] = i + 1
+		"categoryLabel": func(code string) string {
+			return cats.LabelIn(code, lang)
+		},
+		"inc": func(n int) int {
+			return n + 1
+		},
+		// include executes a template chosen at runtime, which the
+		// built-in template action does not allow.
+		"include": func(name string, data any) (template.HTML, error) {
+			var b strings.Builder
+			if err := tmpl.ExecuteTemplate(&b, name, data); err != nil {
+				return "", err
 			}
-			return s
+			return template.HTML(b.String()), nil
+		},
+		"t": func(key string, args ...any) string {
+			return i18n.T(lang, key, args...)
+		},
+		// term translates a stored value such as a status or a unit
+		// type, keeping values the catalogs do not know.
+		"term": func(prefix, value string) string {
+			if msg, ok := i18n.Lookup(lang, prefix+"."+value); ok {
+				return msg
+			}
+			return value
+		},
+		"lang": func() string {
+			return lang
+		},
+		"languages": func() []string {
+			return i18n.Languages
+		},
+		"languageName": func(l string) string {
+			return i18n.T(l, "language.name")
+		},
+		// messages passes the catalog messages under prefix to page
+		// scripts.
+		"messages": func(prefix string) map[string]string {
+			return i18n.Messages(lang, prefix)
 		},
 	}
 
 	tmpl, err := template.New("").Funcs(funcMap).ParseFS(templateFS, "templates/*.html", "templates/partials/*.html")
-	if err != nil {
-		return nil, fmt.Errorf("parse templates: %w", err)
-	}
-
-	return &PagesHandler{tmpl: tmpl, repo: repo, munSvc: munSvc}, nil
+	return tmpl, err
 }
 
 type wizardData struct {
@@ -63,25 +141,29 @@ type wizardData struct {
 	PickupLocation   string
 	PickupHours      string
 	ContactPerson    string
-	Items            []model.FoundItemResponse
-	Errors           []string
-	Success          string
+	// Finder fields are never saved in drafts; see form.Field.IsPersonal.
+	FinderName           string
+	FinderAddress        string
+	FinderPhone          string
+	FinderEmail          string
+	FinderClaimsReward   string
+	FinderWantsOwnership string
+	Attributes           map[string]string
+	Form                 *form.Definition
+	Categories           []model.Category
+	Items                []model.FoundItemResponse
+	Errors               []string
+	Success              string
+	ReceiptURL           string
+	EditID               string
+	DraftID              string
+	DraftCount           int
 }
 
 func (h *PagesHandler) Index(c *gin.Context) {
-	items, _ := h.repo.List(model.ListParams{Limit: 50})
-	var resp []model.FoundItemResponse
-	for _, it := range items {
-		resp = append(resp, it.ToResponse())
-	}
-
-	data := wizardData{
-		CurrentStep:     1,
-		TotalSteps:      4,
-		Progress:        0,
-		StorageDeadline: "30",
-		Items:           resp,
-	}
+	data := h.newWizard(c, 1)
+	data.Items = h.recentItems()
+	data.DraftCount = h.draftCount(c)
 
 	h.render(c, "layout.html", data)
 }
@@ -90,30 +172,26 @@ func (h *PagesHandler) NextStep(c *gin.Context) {
 	data := h.parseForm(c)
 	step := data.CurrentStep
 
-	if errors := h.validateStep(step, data); len(errors) > 0 {
+	if errors := h.validateStep(c, step, data); len(errors) > 0 {
+		h.saveDraft(c, &data)
 		data.Errors = errors
 		c.Header("HX-Retarget", "#modals")
 		c.Header("HX-Reswap", "innerHTML")
 		h.renderPartial(c, "error_modal.html", data)
+		_ = h.templates(c).ExecuteTemplate(c.Writer, "draft_oob.html", data)
 		return
 	}
 
-	data.CurrentStep = step + 1
-	if data.CurrentStep > data.TotalSteps {
-		data.CurrentStep = data.TotalSteps
-	}
-	data.Progress = (data.CurrentStep - 1) * 100 / data.TotalSteps
+	data.setStep(step + 1)
+	h.saveDraft(c, &data)
 
 	h.renderStep(c, data)
 }
 
 func (h *PagesHandler) PrevStep(c *gin.Context) {
 	data := h.parseForm(c)
-	data.CurrentStep--
-	if data.CurrentStep < 1 {
-		data.CurrentStep = 1
-	}
-	data.Progress = (data.CurrentStep - 1) * 100 / data.TotalSteps
+	data.setStep(data.CurrentStep - 1)
+	h.saveDraft(c, &data)
 
 	h.renderStep(c, data)
 }
@@ -123,81 +201,129 @@ func (h *PagesHandler) Autocomplete(c *gin.Context) {
 	unitType := c.Query("type")
 	results := h.munSvc.Search(q, unitType)
 
-	if results == nil {
-		results = []municipality.TerritorialUnit{}
+	// The wizard pre-fills only verified office addresses; a guessed one
+	// is shown as such and left for the clerk to confirm.
+	type acUnit struct {
+		municipality.TerritorialUnit
+		Contact model.OfficeContact
 	}
-
 	type acResult struct {
-		Units []municipality.TerritorialUnit
+		Units []acUnit
+	}
+	data := acResult{Units: []acUnit{}}
+	for _, u := range results {
+		contact, err := h.dir.Contact(u)
+		if err != nil {
+			c.String(http.StatusInternalServerError, "database error: %v", err)
+			return
+		}
+		data.Units = append(data.Units, acUnit{TerritorialUnit: u, Contact: contact})
+	}
+	h.renderPartial(c, "autocomplete.html", data)
+}
+
+// locate fills in the positions missing from a submitted item.
+func (h *PagesHandler) locate(c *gin.Context, create *model.FoundItemCreate) {
+	if err := geo.Locate(c.Request.Context(), h.geocode, create.Municipality, &create.Item, &create.Pickup); err != nil {
+		log.Printf("locate item: %v", err)
 	}
-	h.renderPartial(c, "autocomplete.html", acResult{Units: results})
 }
 
 func (h *PagesHandler) Submit(c *gin.Context) {
+	lang := h.lang(c)
 	data := h.parseForm(c)
 
-	deadline, _ := strconv.Atoi(data.StorageDeadline)
-
-	create := model.FoundItemCreate{
-		Municipality: model.MunicipalityInfo{
-			Name:         data.MunicipalityName,
-			Type:         data.MunicipalityType,
-			ContactEmail: data.ContactEmail,
-		},
-		Item: model.ItemInfo{
-			Name:        data.ItemName,
-			Category:    data.ItemCategory,
-			Date:        data.ItemDate,
-			Location:    data.ItemLocation,
-			Status:      data.ItemStatus,
-			Description: data.ItemDescription,
-		},
-		Pickup: model.PickupInfo{
-			Deadline: deadline,
-			Location: data.PickupLocation,
-			Hours:    data.PickupHours,
-			Contact:  data.ContactPerson,
-		},
+	create := data.Create()
+	// A submission need not come through every step of the wizard; the
+	// rules of the form definition are checked again for all of them.
+	var errs []string
+	for step := range data.Form.Steps {
+		errs = append(errs, data.Form.Validate(step+1, data.Value)...)
 	}
-
-	if create.Item.Status == "" {
-		create.Item.Status = "available"
+	if len(errs) == 0 {
+		errs = h.validateItem(lang, data, create, 0)
+	}
+	if len(errs) > 0 {
+		data.Errors = errs
+		c.Header("HX-Retarget", "#modals")
+		c.Header("HX-Reswap", "innerHTML")
+		h.renderPartial(c, "error_modal.html", data)
+		return
 	}
 
-	_, err := h.repo.Create(create)
+	var saved *model.FoundItem
+	var err error
+	if data.EditID != "" {
+		var before *model.FoundItem
+		before, err = h.repo.GetByID(data.EditID)
+		if err == nil && before == nil {
+			err = i18n.NewError("error.item_missing")
+		}
+		if err == nil {
+			// The wizard has no position fields; positions stay as long
+			// as the location they belong to is unchanged.
+			prev := before.ToResponse()
+			if prev.Item.Location == create.Item.Location {
+				create.Item.Geo = prev.Item.Geo
+			}
+			if prev.Pickup.Location == create.Pickup.Location {
+				create.Pickup.Geo = prev.Pickup.Geo
+			}
+			h.locate(c, &create)
+			saved, err = h.repo.Update(data.EditID, model.FoundItemUpdate{
+				Municipality: &create.Municipality,
+				Item:         &create.Item,
+				Pickup:       &create.Pickup,
+				Attributes:   &create.Attributes,
+			})
+		}
+		if err == nil && saved == nil {
+			err = i18n.NewError("error.item_missing")
+		}
+		if err == nil {
+			h.events.ItemUpdated(saved.ToResponse(), before.ItemStatus)
+		}
+	} else {
+		h.locate(c, &create)
+		saved, err = h.repo.Create(create)
+		if err == nil {
+			resp := saved.ToResponse()
+			if err := h.notify.ItemRegistered(resp); err != nil {
+				log.Printf("notify item %s: %v", saved.ID, err)
+			}
+			h.events.ItemCreated(resp)
+		}
+	}
 	if err != nil {
-		data.Errors = []string{"Błąd zapisu: " + err.Error()}
+		data.Errors = []string{i18n.T(lang, "error.save", errorMessage(lang, err))}
+		if errors.Is(err, repository.ErrFinderDisabled) {
+			data.Errors = []string{i18n.T(lang, "error.finder_disabled")}
+		}
 		c.Header("HX-Retarget", "#modals")
 		c.Header("HX-Reswap", "innerHTML")
 		h.renderPartial(c, "error_modal.html", data)
 		return
 	}
 
-	items, _ := h.repo.List(model.ListParams{Limit: 50})
-	var resp []model.FoundItemResponse
-	for _, it := range items {
-		resp = append(resp, it.ToResponse())
+	if data.DraftID != "" {
+		if err := h.drafts.Delete(data.DraftID, sessionID(c)); err != nil {
+			_ = c.Error(err)
+		}
 	}
 
-	data.Items = resp
-	data.Success = "Rzecz została pomyślnie zarejestrowana w systemie."
-	data.CurrentStep = 1
-	data.Progress = 0
-	data.MunicipalityName = ""
-	data.MunicipalityType = ""
-	data.ContactEmail = ""
-	data.ItemName = ""
-	data.ItemCategory = ""
-	data.ItemDate = ""
-	data.ItemLocation = ""
-	data.ItemStatus = ""
-	data.ItemDescription = ""
-	data.StorageDeadline = "30"
-	data.PickupLocation = ""
-	data.PickupHours = ""
-	data.ContactPerson = ""
-
-	h.renderPartial(c, "submit_result.html", data)
+	data.Items = h.recentItems()
+	data.Success = i18n.T(lang, "wizard.registered")
+	if data.EditID != "" {
+		data.Success = i18n.T(lang, "wizard.saved")
+	}
+	c.Header("HX-Push-Url", "/")
+	result := h.newWizard(c, 1)
+	result.Items = h.recentItems()
+	result.Success = data.Success
+	result.ReceiptURL = "/api/found-items/" + saved.ID + "/receipt.pdf"
+	result.DraftCount = h.draftCount(c)
+
+	h.renderPartial(c, "submit_result.html", result)
 }
 
 func (h *PagesHandler) ExportJSON(c *gin.Context) {
@@ -224,6 +350,7 @@ func (h *PagesHandler) ExportJSON(c *gin.Context) {
 			"hours":    data.PickupHours,
 			"contact":  data.ContactPerson,
 		},
+		"attributes": data.CustomAttributes(),
 	}
 
 	b, _ := json.MarshalIndent(export, "", "  ")
@@ -240,6 +367,8 @@ func (h *PagesHandler) ExportCSV(c *gin.Context) {
 	c.Header("Content-Type", "text/csv; charset=utf-8")
 	_, _ = c.Writer.Write([]byte{0xEF, 0xBB, 0xBF}) // UTF-8 BOM
 
+	// The labels are those the importer reads, whatever the language of
+	// the page.
 	w := csv.NewWriter(c.Writer)
 	_ = w.Write([]string{"Pole", "Wartość"})
 	_ = w.Write([]string{"Samorząd", data.MunicipalityName})
@@ -255,120 +384,130 @@ func (h *PagesHandler) ExportCSV(c *gin.Context) {
 	_ = w.Write([]string{"Miejsce odbioru", data.PickupLocation})
 	_ = w.Write([]string{"Godziny odbioru", data.PickupHours})
 	_ = w.Write([]string{"Osoba kontaktowa", data.ContactPerson})
+	for _, f := range h.form.CustomFields() {
+		_ = w.Write([]string{f.Label, data.Attributes[f.Name]})
+	}
 	w.Flush()
 }
 
 func (h *PagesHandler) parseForm(c *gin.Context) wizardData {
 	step, _ := strconv.Atoi(c.PostForm("currentStep"))
-	if step == 0 {
-		step = 1
+	data := h.newWizard(c, step)
+	for _, f := range h.form.Fields() {
+		if v, ok := c.GetPostForm(f.Name); ok {
+			data.SetValue(f.Name, v)
+		}
+	}
+	data.EditID = c.PostForm("editId")
+	data.DraftID = c.PostForm("draftId")
+	data.Items = h.recentItems()
+	return data
+}
+
+func (h *PagesHandler) parseQuery(c *gin.Context) wizardData {
+	data := h.newWizard(c, 1)
+	for _, f := range h.form.Fields() {
+		if v, ok := c.GetQuery(f.Name); ok {
+			data.SetValue(f.Name, v)
+		}
+	}
+	return data
+}
+
+// validateStep checks the fields of a step against the form definition
+// and then against the rules of the register.
+func (h *PagesHandler) validateStep(c *gin.Context, step int, data wizardData) []string {
+	if errors := data.Form.Validate(step, data.Value); len(errors) > 0 {
+		return errors
+	}
+	return h.validateItem(h.lang(c), data, data.Create(), step)
+}
+
+// validateItem checks the item entered in the wizard against the rules of
+// the register, the same the API applies, and returns the errors about the
+// fields of the given step, or of all steps when step is 0.
+func (h *PagesHandler) validateItem(lang string, data wizardData, create model.FoundItemCreate, step int) []string {
+	err := create.Validate(h.cats)
+	var invalid model.ValidationError
+	if err != nil && !errors.As(err, &invalid) {
+		return []string{i18n.T(lang, "error.read", err.Error())}
+	}
+
+	var errs []string
+	for _, fe := range invalid {
+		f, ok := data.Form.Field(wizardFields[fe.Field])
+		switch {
+		case ok && (step == 0 || data.Form.Step(step).Has(f.Name)):
+			errs = append(errs, f.Explain(fe.Message(lang)))
+		case !ok && step == 0:
+			// A form definition without the field cannot fill it in;
+			// name it as the API does.
+			errs = append(errs, i18n.T(lang, "form.rule", fe.Field, fe.Message(lang)))
+		}
 	}
+	return errs
+}
 
+// Records renders the clerk's records list, refreshed live from the item
+// stream.
+func (h *PagesHandler) Records(c *gin.Context) {
+	h.renderPartial(c, "records.html", wizardData{Items: h.recentItems()})
+}
+
+func (h *PagesHandler) recentItems() []model.FoundItemResponse {
 	items, _ := h.repo.List(model.ListParams{Limit: 50})
 	var resp []model.FoundItemResponse
 	for _, it := range items {
 		resp = append(resp, it.ToResponse())
 	}
+	return resp
+}
 
-	return wizardData{
-		CurrentStep:      step,
-		TotalSteps:       4,
-		Progress:         (step - 1) * 100 / 4,
-		MunicipalityName: c.PostForm("municipalityName"),
-		MunicipalityType: c.PostForm("municipalityType"),
-		ContactEmail:     c.PostForm("contactEmail"),
-		ItemName:         c.PostForm("itemName"),
-		ItemCategory:     c.PostForm("itemCategory"),
-		ItemDate:         c.PostForm("itemDate"),
-		ItemLocation:     c.PostForm("itemLocation"),
-		ItemStatus:       c.PostForm("itemStatus"),
-		ItemDescription:  c.PostForm("itemDescription"),
-		StorageDeadline:  c.DefaultPostForm("storageDeadline", "30"),
-		PickupLocation:   c.PostForm("pickupLocation"),
-		PickupHours:      c.PostForm("pickupHours"),
-		ContactPerson:    c.PostForm("contactPerson"),
-		Items:            resp,
-	}
+// lang returns the language of the pages for the request.
+func (h *PagesHandler) lang(c *gin.Context) string {
+	return locale(c, i18n.Default)
 }
 
-func (h *PagesHandler) parseQuery(c *gin.Context) wizardData {
-	deadline := c.DefaultQuery("storageDeadline", "30")
-	return wizardData{
-		MunicipalityName: c.Query("municipalityName"),
-		MunicipalityType: c.Query("municipalityType"),
-		ContactEmail:     c.Query("contactEmail"),
-		ItemName:         c.Query("itemName"),
-		ItemCategory:     c.Query("itemCategory"),
-		ItemDate:         c.Query("itemDate"),
-		ItemLocation:     c.Query("itemLocation"),
-		ItemStatus:       c.Query("itemStatus"),
-		ItemDescription:  c.Query("itemDescription"),
-		StorageDeadline:  deadline,
-		PickupLocation:   c.Query("pickupLocation"),
-		PickupHours:      c.Query("pickupHours"),
-		ContactPerson:    c.Query("contactPerson"),
-	}
+// templates returns the templates in the language of the request.
+func (h *PagesHandler) templates(c *gin.Context) *template.Template {
+	return h.tmpl[h.lang(c)]
 }
 
-func (h *PagesHandler) validateStep(step int, data wizardData) []string {
-	var errors []string
-	switch step {
-	case 1:
-		if data.MunicipalityType == "" {
-			errors = append(errors, "Wybierz typ samorządu")
-		}
-		if data.MunicipalityName == "" {
-			errors = append(errors, "Podaj nazwę samorządu")
-		}
-		if data.ContactEmail == "" || !strings.Contains(data.ContactEmail, "@") {
-			errors = append(errors, "Podaj prawidłowy adres email")
-		}
-	case 2:
-		if data.ItemName == "" {
-			errors = append(errors, "Podaj nazwę przedmiotu")
-		}
-		if data.ItemCategory == "" {
-			errors = append(errors, "Wybierz kategorię")
-		}
-		if data.ItemDate == "" {
-			errors = append(errors, "Podaj datę znalezienia")
-		}
-		if data.ItemLocation == "" {
-			errors = append(errors, "Podaj miejsce znalezienia")
-		}
-	case 3:
-		deadline, err := strconv.Atoi(data.StorageDeadline)
-		if err != nil || deadline < 1 || deadline > 365 {
-			errors = append(errors, "Termin przechowania musi być od 1 do 365 dni")
-		}
-		if data.PickupLocation == "" {
-			errors = append(errors, "Podaj miejsce odbioru")
-		}
+// errorMessage returns the text of err, translated when it comes from the
+// catalogs.
+func errorMessage(lang string, err error) string {
+	if msg, ok := i18n.Translate(lang, err); ok {
+		return msg
 	}
-	return errors
+	return err.Error()
 }
 
 func (h *PagesHandler) render(c *gin.Context, name string, data any) {
 	c.Header("Content-Type", "text/html; charset=utf-8")
-	if err := h.tmpl.ExecuteTemplate(c.Writer, name, data); err != nil {
+	c.Header("Content-Language", h.lang(c))
+	if err := h.templates(c).ExecuteTemplate(c.Writer, name, data); err != nil {
 		c.String(http.StatusInternalServerError, "template error: %v", err)
 	}
 }
 
 func (h *PagesHandler) renderPartial(c *gin.Context, name string, data any) {
 	c.Header("Content-Type", "text/html; charset=utf-8")
-	if err := h.tmpl.ExecuteTemplate(c.Writer, name, data); err != nil {
+	c.Header("Content-Language", h.lang(c))
+	if err := h.templates(c).ExecuteTemplate(c.Writer, name, data); err != nil {
 		c.String(http.StatusInternalServerError, "template error: %v", err)
 	}
 }
 
 func (h *PagesHandler) renderStep(c *gin.Context, data wizardData) {
-	stepName := fmt.Sprintf("step%d.html", data.CurrentStep)
+	stepName := data.Step().StepTemplate()
+	tmpl := h.templates(c)
 	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Content-Language", h.lang(c))
 
-	if err := h.tmpl.ExecuteTemplate(c.Writer, stepName, data); err != nil {
+	if err := tmpl.ExecuteTemplate(c.Writer, stepName, data); err != nil {
 		c.String(http.StatusInternalServerError, "template error: %v", err)
 		return
 	}
-	_ = h.tmpl.ExecuteTemplate(c.Writer, "progress_oob.html", data)
+	_ = tmpl.ExecuteTemplate(c.Writer, "progress_oob.html", data)
+	_ = tmpl.ExecuteTemplate(c.Writer, "draft_oob.html", data)
 }
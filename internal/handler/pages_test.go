@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kacperfilipiuk/zguba-gov/internal/database"
+	"github.com/kacperfilipiuk/zguba-gov/internal/form"
+	"github.com/kacperfilipiuk/zguba-gov/internal/i18n"
+	"github.com/kacperfilipiuk/zguba-gov/internal/importer"
+	"github.com/kacperfilipiuk/zguba-gov/internal/repository"
+)
+
+func TestExportCSVRoundTrip(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := database.Open(filepath.Join(t.TempDir(), "pages.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	def, err := form.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &PagesHandler{form: def, forms: map[string]*form.Definition{}, cats: repository.NewCategoryRepo(db)}
+	for _, lang := range i18n.Languages {
+		h.forms[lang] = def.Localize(lang)
+	}
+	r := gin.New()
+	r.Use(Locale)
+	r.GET("/export/csv", h.ExportCSV)
+
+	want := map[string]string{
+		"municipalityName": "Kraków",
+		"municipalityType": "miasto",
+		"contactEmail":     "biuro@krakow.example.gov.pl",
+		"itemName":         "Parasol, czarny",
+		"itemDate":         "2026-10-01",
+		"itemLocation":     "Rynek Główny",
+		"storageDeadline":  "90",
+		"pickupLocation":   "ul. Wielicka 28a",
+	}
+	query := url.Values{}
+	for field, v := range want {
+		query.Set(field, v)
+	}
+	for _, lang := range i18n.Languages {
+		t.Run(lang, func(t *testing.T) {
+			query.Set("lang", lang)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/export/csv?"+query.Encode(), nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("export: HTTP %d", w.Code)
+			}
+
+			rows, err := importer.Read(w.Body, importer.FormatCSV, nil)
+			if err != nil {
+				t.Fatalf("import the export: %v", err)
+			}
+			if len(rows) != 1 {
+				t.Fatalf("import the export: %d rows, want 1", len(rows))
+			}
+			for field, v := range want {
+				if got := rows[0].Fields[field]; got != v {
+					t.Errorf("%s = %q, want %q", field, got, v)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/kacperfilipiuk/zguba-gov/internal/i18n"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+)
+
+// mediaProblem is the content type of API error responses.
+const mediaProblem = "application/problem+json"
+
+// requestIDHeader carries the ID of a request in both directions.
+const requestIDHeader = "X-Request-ID"
+
+// requestIDKey is the context key of the request ID.
+const requestIDKey = "requestID"
+
+func init() {
+	// Validation errors name fields as they appear in JSON bodies.
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+	}
+}
+
+// problem is an API error response in the problem details format of
+// RFC 9457. Problems have no type of their own: Code is the stable,
+// machine-readable identifier of the error, while Detail is the message
+// in the language of the request.
+type problem struct {
+	Type      string         `json:"type"`
+	Title     string         `json:"title"`
+	Status    int            `json:"status"`
+	Detail    string         `json:"detail"`
+	Instance  string         `json:"instance"`
+	Code      string         `json:"code"`
+	RequestID string         `json:"requestId,omitempty"`
+	Errors    []fieldProblem `json:"errors,omitempty"`
+}
+
+// fieldProblem is an invalid field of the request body, located by a JSON
+// Pointer such as "/item/date". Code is the failed rule, e.g. "required".
+type fieldProblem struct {
+	Pointer string `json:"pointer"`
+	Code    string `json:"code"`
+	Detail  string `json:"detail"`
+}
+
+// RequestID tags the request with the ID the client sent in X-Request-ID,
+// or a new one, and returns it in the same header. Error responses and
+// logged server errors carry the ID so that the two can be matched.
+func RequestID(c *gin.Context) {
+	id := c.GetHeader(requestIDHeader)
+	if !validRequestID(id) {
+		id = uuid.New().String()
+	}
+	c.Set(requestIDKey, id)
+	c.Header(requestIDHeader, id)
+	c.Next()
+}
+
+// validRequestID accepts IDs that are safe to log and echo.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func requestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// jsonError writes a problem with the catalog message key, which also
+// gives the error code, in the language of the request.
+func jsonError(c *gin.Context, status int, key string, args ...any) {
+	writeProblem(c, problem{
+		Status: status,
+		Code:   problemCode(key),
+		Detail: i18n.T(locale(c, i18n.English), key, args...),
+	})
+}
+
+// jsonErr writes err as a problem. Catalog errors keep their code and are
+// translated, and request bodies that fail binding or the rules of the
+// register list the invalid fields. The text of any other error is shown
+// for client errors only: server errors are logged with the request ID and
+// the response gives just the ID.
+func jsonErr(c *gin.Context, status int, err error) {
+	lang := locale(c, i18n.English)
+	p := problem{Status: status}
+
+	var (
+		catalog *i18n.Error
+		invalid validator.ValidationErrors
+		rules   model.ValidationError
+		typeErr *json.UnmarshalTypeError
+		syntax  *json.SyntaxError
+	)
+	switch {
+	case errors.As(err, &catalog):
+		p.Code, p.Detail = problemCode(catalog.Key), catalog.In(lang)
+	case status >= http.StatusInternalServerError:
+		log.Printf("%s %s: request %s: %v", c.Request.Method, c.Request.URL.Path, requestID(c), err)
+		p.Code, p.Detail = "internal_error", i18n.T(lang, "api.internal", requestID(c))
+	case errors.As(err, &invalid):
+		p.Code, p.Detail = "validation_failed", i18n.T(lang, "api.validation")
+		for _, fe := range invalid {
+			p.Errors = append(p.Errors, bindingProblem(lang, fe))
+		}
+	case errors.As(err, &rules):
+		p.Code, p.Detail = "validation_failed", i18n.T(lang, "api.validation")
+		for _, fe := range rules {
+			p.Errors = append(p.Errors, fieldProblem{Pointer: jsonPointer(fe.Field), Code: fe.Rule, Detail: fe.Message(lang)})
+		}
+	case errors.As(err, &typeErr):
+		p.Code, p.Detail = "validation_failed", i18n.T(lang, "api.validation")
+		p.Errors = []fieldProblem{{
+			Pointer: jsonPointer(typeErr.Field),
+			Code:    "type",
+			Detail:  i18n.T(lang, "validation.type", jsonType(typeErr.Type)),
+		}}
+	case errors.As(err, &syntax), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		p.Code, p.Detail = "malformed_json", i18n.T(lang, "api.malformed_json")
+	default:
+		p.Code, p.Detail = "invalid_request", i18n.T(lang, "api.invalid_request", err.Error())
+	}
+	writeProblem(c, p)
+}
+
+func writeProblem(c *gin.Context, p problem) {
+	p.Type = "about:blank"
+	p.Title = http.StatusText(p.Status)
+	p.Instance = c.Request.URL.Path
+	p.RequestID = requestID(c)
+	c.Header("Content-Type", mediaProblem)
+	c.JSON(p.Status, p)
+}
+
+// problemCode derives the error code from a catalog key:
+// "api.item_not_found" becomes "item_not_found" and "category.exists"
+// becomes "category_exists".
+func problemCode(key string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(key, "api."), ".", "_")
+}
+
+// bindingProblem describes a failed binding rule of a request body field.
+func bindingProblem(lang string, fe validator.FieldError) fieldProblem {
+	// The namespace starts with the name of the bound struct.
+	_, path, _ := strings.Cut(fe.Namespace(), ".")
+	p := fieldProblem{Pointer: jsonPointer(path), Code: fe.Tag()}
+	switch fe.Tag() {
+	case "required":
+		p.Detail = i18n.T(lang, "validation.required")
+	case "email":
+		p.Detail = i18n.T(lang, "validation.email")
+	case "max":
+		if fe.Kind() == reflect.String {
+			p.Detail = i18n.T(lang, "validation.max_length", fe.Param())
+		} else {
+			p.Detail = i18n.T(lang, "validation.max", fe.Param())
+		}
+	case "lte":
+		p.Detail = i18n.T(lang, "validation.max", fe.Param())
+	case "min", "gte":
+		p.Detail = i18n.T(lang, "validation.min", fe.Param())
+	default:
+		p.Code, p.Detail = "invalid", i18n.T(lang, "validation.invalid")
+	}
+	return p
+}
+
+// jsonPointer turns a field path such as "item.date" or "categories[0]"
+// into a JSON Pointer, "/item/date" or "/categories/0".
+func jsonPointer(path string) string {
+	if path == "" {
+		return ""
+	}
+	path = strings.ReplaceAll(strings.ReplaceAll(path, "[", "."), "]", "")
+	var b strings.Builder
+	for _, name := range strings.Split(path, ".") {
+		b.WriteString("/")
+		b.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(name))
+	}
+	return b.String()
+}
+
+// jsonType names the JSON type a Go type is decoded from.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Pointer:
+		return jsonType(t.Elem())
+	}
+	return "object"
+}
@@ -0,0 +1,505 @@
+package handler
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kacperfilipiuk/zguba-gov/internal/i18n"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+	"github.com/kacperfilipiuk/zguba-gov/internal/repository"
+)
+
+const publicPageSize = 20
+
+type pageMeta struct {
+	Title       string
+	Description string
+	URL         string
+	Image       string
+	NoIndex     bool
+	JSONLD      template.JS
+	// Feed is the query string of the Atom and RSS feeds matching the
+	// page, advertised for autodiscovery.
+	Feed string
+}
+
+type itemPageData struct {
+	Meta pageMeta
+	Item *model.FoundItemResponse
+	// ClaimsOpen is set when the owning office accepts claims by e-mail.
+	ClaimsOpen  bool
+	Claim       model.Claim
+	ClaimErrors []string
+	ClaimSent   bool
+}
+
+type itemsPageData struct {
+	Meta       pageMeta
+	Items      []model.FoundItemResponse
+	Categories []map[string]string
+	Filters    model.ListParams
+	Total      int
+	Page       int
+	Pages      int
+	PrevURL    string
+	NextURL    string
+}
+
+func (h *PagesHandler) ItemPage(c *gin.Context) {
+	lang := h.lang(c)
+	item, err := h.repo.GetByID(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusInternalServerError, "database error: %v", err)
+		return
+	}
+
+	if item == nil {
+		c.Status(http.StatusNotFound)
+		h.render(c, "item.html", itemPageData{Meta: pageMeta{
+			Title:       i18n.T(lang, "error.item_not_found"),
+			Description: i18n.T(lang, "item.not_found_description"),
+			URL:         h.baseURL + c.Request.URL.Path,
+			Image:       h.baseURL + "/static/img/polish_eagle.svg",
+			NoIndex:     true,
+		}})
+		return
+	}
+
+	data := h.itemPageData(lang, item)
+	data.ClaimSent = c.Query("zgloszenie") == "wyslane"
+	h.render(c, "item.html", data)
+}
+
+func (h *PagesHandler) itemPageData(lang string, item *model.FoundItem) itemPageData {
+	resp := item.ToResponse()
+	pageURL := h.baseURL + "/rzeczy/" + resp.ID
+
+	description := resp.Item.Description
+	if description == "" {
+		description = i18n.T(lang, "item.summary", resp.Item.Name, resp.Item.Date, resp.Item.Location)
+	}
+	description += " " + i18n.T(lang, "item.summary_pickup", resp.Municipality.Name, resp.Pickup.Location)
+
+	return itemPageData{
+		Meta: pageMeta{
+			Title:       resp.Item.Name + " – " + resp.Municipality.Name,
+			Description: description,
+			URL:         pageURL,
+			Image:       h.baseURL + "/static/img/polish_eagle.svg",
+			JSONLD: jsonLD(map[string]any{
+				"@context":     "https://schema.org",
+				"@type":        "ItemPage",
+				"url":          pageURL,
+				"name":         resp.Item.Name,
+				"dateCreated":  resp.CreatedAt,
+				"dateModified": resp.UpdatedAt,
+				"inLanguage":   lang,
+				"mainEntity": map[string]any{
+					"@type":       "Thing",
+					"identifier":  resp.ID,
+					"name":        resp.Item.Name,
+					"description": description,
+				},
+				"contentLocation": map[string]any{
+					"@type": "Place",
+					"name":  resp.Item.Location,
+				},
+				"publisher": map[string]any{
+					"@type": "GovernmentOrganization",
+					"name":  resp.Municipality.Name,
+					"email": resp.Municipality.ContactEmail,
+				},
+			}),
+		},
+		Item:       &resp,
+		ClaimsOpen: resp.Item.Status == "available" && h.notify.AcceptsClaims(resp.Municipality.ContactEmail),
+	}
+}
+
+// SubmitClaim forwards an owner's claim from the public item page to the
+// office that holds the item.
+func (h *PagesHandler) SubmitClaim(c *gin.Context) {
+	item, err := h.repo.GetByID(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusInternalServerError, "database error: %v", err)
+		return
+	}
+	if item == nil {
+		c.Redirect(http.StatusSeeOther, "/rzeczy")
+		return
+	}
+	// Bots fill in the hidden field; pretend success.
+	if c.PostForm("website") != "" {
+		c.Redirect(http.StatusSeeOther, "/rzeczy/"+item.ID+"?zgloszenie=wyslane")
+		return
+	}
+
+	lang := h.lang(c)
+	data := h.itemPageData(lang, item)
+	data.Claim = model.Claim{
+		Name:    strings.TrimSpace(c.PostForm("name")),
+		Email:   strings.TrimSpace(c.PostForm("email")),
+		Phone:   strings.TrimSpace(c.PostForm("phone")),
+		Message: strings.TrimSpace(c.PostForm("message")),
+	}
+	if !data.ClaimsOpen {
+		data.ClaimErrors = []string{i18n.T(lang, "claim.closed")}
+	} else {
+		data.ClaimErrors = validateClaim(lang, data.Claim)
+	}
+	if len(data.ClaimErrors) == 0 {
+		if err := h.notify.ClaimSubmitted(*data.Item, data.Claim); err != nil {
+			log.Printf("claim for %s: %v", item.ID, err)
+			data.ClaimErrors = []string{i18n.T(lang, "claim.failed")}
+		}
+	}
+	if len(data.ClaimErrors) > 0 {
+		c.Status(http.StatusUnprocessableEntity)
+		h.render(c, "item.html", data)
+		return
+	}
+	c.Redirect(http.StatusSeeOther, "/rzeczy/"+item.ID+"?zgloszenie=wyslane")
+}
+
+func validateClaim(lang string, cl model.Claim) []string {
+	var errors []string
+	if cl.Name == "" {
+		errors = append(errors, i18n.T(lang, "claim.name_required"))
+	}
+	if cl.Email == "" && cl.Phone == "" {
+		errors = append(errors, i18n.T(lang, "claim.contact_required"))
+	}
+	if a, err := mail.ParseAddress(cl.Email); cl.Email != "" && (err != nil || a.Address != cl.Email) {
+		errors = append(errors, i18n.T(lang, "claim.email_invalid"))
+	}
+	if cl.Message == "" {
+		errors = append(errors, i18n.T(lang, "claim.message_required"))
+	}
+	if len([]rune(cl.Name)) > 200 || len([]rune(cl.Phone)) > 40 || len([]rune(cl.Email)) > 200 || len([]rune(cl.Message)) > 2000 {
+		errors = append(errors, i18n.T(lang, "claim.too_long"))
+	}
+	return errors
+}
+
+func (h *PagesHandler) ItemsPage(c *gin.Context) {
+	lang := h.lang(c)
+	filters := model.ListParams{
+		Category:     c.Query("kategoria"),
+		Municipality: c.Query("gmina"),
+		Status:       c.Query("status"),
+		Search:       c.Query("szukaj"),
+	}
+
+	total, err := h.repo.CountList(filters)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "database error: %v", err)
+		return
+	}
+
+	pages := (total + publicPageSize - 1) / publicPageSize
+	if pages < 1 {
+		pages = 1
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("strona", "1"))
+	if page < 1 {
+		page = 1
+	}
+	if page > pages {
+		page = pages
+	}
+
+	params := filters
+	params.Skip = (page - 1) * publicPageSize
+	params.Limit = publicPageSize
+	items, err := h.repo.List(params)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "database error: %v", err)
+		return
+	}
+
+	resp := make([]model.FoundItemResponse, 0, len(items))
+	listElements := make([]map[string]any, 0, len(items))
+	for i, it := range items {
+		r := it.ToResponse()
+		resp = append(resp, r)
+		listElements = append(listElements, map[string]any{
+			"@type":    "ListItem",
+			"position": params.Skip + i + 1,
+			"url":      h.baseURL + "/rzeczy/" + r.ID,
+			"name":     r.Item.Name,
+		})
+	}
+
+	cats, _ := h.repo.Categories()
+	localizeCategories(h.cats, cats, lang)
+
+	title := i18n.T(lang, "items.title")
+	var qualifiers []string
+	if filters.Category != "" {
+		qualifiers = append(qualifiers, h.cats.LabelIn(filters.Category, lang))
+	}
+	if filters.Municipality != "" {
+		qualifiers = append(qualifiers, filters.Municipality)
+	}
+	if len(qualifiers) > 0 {
+		title += " – " + strings.Join(qualifiers, ", ")
+	}
+	if page > 1 {
+		title = i18n.T(lang, "items.title_page", title, page)
+	}
+
+	pageURL := h.baseURL + itemsPageURL(filters, page)
+	data := itemsPageData{
+		Meta: pageMeta{
+			Title:       title,
+			Description: i18n.T(lang, "items.description", total),
+			URL:         pageURL,
+			Image:       h.baseURL + "/static/img/polish_eagle.svg",
+			NoIndex:     filters.Search != "",
+			Feed:        feedQuery(filters),
+			JSONLD: jsonLD(map[string]any{
+				"@context": "https://schema.org",
+				"@type":    "CollectionPage",
+				"url":      pageURL,
+				"name":     title,
+				"mainEntity": map[string]any{
+					"@type":           "ItemList",
+					"numberOfItems":   total,
+					"itemListElement": listElements,
+				},
+			}),
+		},
+		Items:      resp,
+		Categories: cats,
+		Filters:    filters,
+		Total:      total,
+		Page:       page,
+		Pages:      pages,
+	}
+	if page > 1 {
+		data.PrevURL = itemsPageURL(filters, page-1)
+	}
+	if page < pages {
+		data.NextURL = itemsPageURL(filters, page+1)
+	}
+
+	h.render(c, "items.html", data)
+}
+
+// MapTiles configures the base layer of the public map: the {z}/{x}/{y}
+// URL template of the tiles, their attribution and the maximum zoom.
+type MapTiles struct {
+	URL         string
+	Attribution string
+	MaxZoom     int
+}
+
+type mapPageData struct {
+	Meta       pageMeta
+	Categories []map[string]string
+	Filters    model.ListParams
+	Tiles      MapTiles
+}
+
+// MapPage shows the found items on a map. The page script loads the items
+// of the visible area, grouped per zoom level, from the clusters endpoint.
+func (h *PagesHandler) MapPage(c *gin.Context) {
+	filters := model.ListParams{Category: c.Query("kategoria")}
+	for _, d := range []struct {
+		param string
+		value *string
+	}{{"od", &filters.DateFrom}, {"do", &filters.DateTo}} {
+		if _, err := time.Parse("2006-01-02", c.Query(d.param)); err == nil {
+			*d.value = c.Query(d.param)
+		}
+	}
+	lang := h.lang(c)
+	cats, _ := h.repo.Categories()
+	localizeCategories(h.cats, cats, lang)
+
+	h.render(c, "map.html", mapPageData{
+		Meta: pageMeta{
+			Title:       i18n.T(lang, "map.title"),
+			Description: i18n.T(lang, "map.description"),
+			URL:         h.baseURL + "/rzeczy/mapa",
+			Image:       h.baseURL + "/static/img/polish_eagle.svg",
+			Feed:        feedQuery(model.ListParams{Category: filters.Category}),
+		},
+		Categories: cats,
+		Filters:    filters,
+		Tiles:      h.tiles,
+	})
+}
+
+// Limits of a single sitemap file set by the sitemaps.org protocol. Larger
+// sitemaps are split into numbered files listed by a sitemap index.
+const (
+	sitemapMaxURLs  = 50000
+	sitemapMaxBytes = 50 << 20
+)
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// errSitemapFull stops the iteration over items once a sitemap file is
+// complete.
+var errSitemapFull = errors.New("sitemap full")
+
+// Sitemap serves the sitemap of the listing and item pages or, when they do
+// not fit in one file, a sitemap index of the files served by SitemapPage.
+func (h *PagesHandler) Sitemap(c *gin.Context) {
+	pages, size, err := h.sitemapPages()
+	if err != nil {
+		c.String(http.StatusInternalServerError, "database error: %v", err)
+		return
+	}
+	if pages == 1 {
+		h.writeSitemap(c, 0, size)
+		return
+	}
+
+	c.Header("Content-Type", "application/xml; charset=utf-8")
+	c.Status(http.StatusOK)
+	_, _ = c.Writer.WriteString(xml.Header + `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` + "\n")
+	enc := xml.NewEncoder(c.Writer)
+	for n := 1; n <= pages; n++ {
+		_ = enc.EncodeElement(sitemapURL{Loc: h.baseURL + "/sitemap/" + strconv.Itoa(n) + ".xml"}, xml.StartElement{Name: xml.Name{Local: "sitemap"}})
+	}
+	_ = enc.Flush()
+	_, _ = c.Writer.WriteString("\n</sitemapindex>\n")
+}
+
+// SitemapPage serves one file of a sitemap split by Sitemap, numbered from
+// 1 (/sitemap/1.xml).
+func (h *PagesHandler) SitemapPage(c *gin.Context) {
+	n, err := strconv.Atoi(strings.TrimSuffix(c.Param("page"), ".xml"))
+	if err != nil || !strings.HasSuffix(c.Param("page"), ".xml") {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	pages, size, err := h.sitemapPages()
+	if err != nil {
+		c.String(http.StatusInternalServerError, "database error: %v", err)
+		return
+	}
+	if n < 1 || n > pages {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	h.writeSitemap(c, (n-1)*size, size)
+}
+
+// sitemapPages returns the number of sitemap files and the number of URLs
+// in each: the listing page and one URL per item.
+func (h *PagesHandler) sitemapPages() (pages, size int, err error) {
+	count, err := h.repo.CountList(model.ListParams{})
+	if err != nil {
+		return 0, 0, err
+	}
+	size = h.sitemapSize()
+	return (count + size) / size, size, nil
+}
+
+// sitemapSize is the number of URLs that fit in a sitemap file. Item URLs
+// differ only in the ID, a UUID, so all entries have the length of a sample
+// one.
+func (h *PagesHandler) sitemapSize() int {
+	var sample strings.Builder
+	enc := xml.NewEncoder(&sample)
+	_ = enc.EncodeElement(sitemapURL{
+		Loc:     h.baseURL + "/rzeczy/00000000-0000-0000-0000-000000000000",
+		LastMod: "2006-01-02",
+	}, xml.StartElement{Name: xml.Name{Local: "url"}})
+	_ = enc.Flush()
+	frame := len(xml.Header) + len(`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`+"\n"+"\n</urlset>\n")
+	return max(1, min(sitemapMaxURLs, (sitemapMaxBytes-frame)/sample.Len()))
+}
+
+// writeSitemap writes a sitemap of at most limit URLs, skipping the first
+// skip. The listing page comes first, then the items in insertion order.
+func (h *PagesHandler) writeSitemap(c *gin.Context, skip, limit int) {
+	c.Header("Content-Type", "application/xml; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	_, _ = c.Writer.WriteString(xml.Header + `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` + "\n")
+	enc := xml.NewEncoder(c.Writer)
+	pos := 0
+	write := func(u sitemapURL) error {
+		if pos >= skip+limit {
+			return errSitemapFull
+		}
+		pos++
+		if pos <= skip {
+			return nil
+		}
+		return enc.EncodeElement(u, xml.StartElement{Name: xml.Name{Local: "url"}})
+	}
+
+	_ = write(sitemapURL{Loc: h.baseURL + "/rzeczy"})
+	err := h.repo.Each(model.ListParams{}, func(item model.FoundItem) error {
+		return write(sitemapURL{
+			Loc:     h.baseURL + "/rzeczy/" + item.ID,
+			LastMod: item.UpdatedAt.UTC().Format("2006-01-02"),
+		})
+	})
+	if err != nil && !errors.Is(err, errSitemapFull) {
+		_ = c.Error(err)
+	}
+	_ = enc.Flush()
+	_, _ = c.Writer.WriteString("\n</urlset>\n")
+}
+
+func itemsPageURL(f model.ListParams, page int) string {
+	q := url.Values{}
+	if f.Category != "" {
+		q.Set("kategoria", f.Category)
+	}
+	if f.Municipality != "" {
+		q.Set("gmina", f.Municipality)
+	}
+	if f.Status != "" {
+		q.Set("status", f.Status)
+	}
+	if f.Search != "" {
+		q.Set("szukaj", f.Search)
+	}
+	if page > 1 {
+		q.Set("strona", strconv.Itoa(page))
+	}
+	if len(q) == 0 {
+		return "/rzeczy"
+	}
+	return "/rzeczy?" + q.Encode()
+}
+
+// localizeCategories replaces the labels of category options, as returned
+// by FoundItemRepo.Categories, with the ones in lang and sorts the options
+// by them.
+func localizeCategories(cats *repository.CategoryRepo, options []map[string]string, lang string) {
+	for _, o := range options {
+		if c, err := cats.Get(o["value"]); err == nil && c != nil {
+			o["label"] = c.Label(lang)
+		}
+	}
+	sort.SliceStable(options, func(i, j int) bool {
+		return options[i]["label"] < options[j]["label"]
+	})
+}
+
+func jsonLD(v any) template.JS {
+	b, _ := json.Marshal(v)
+	return template.JS(b)
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+	"github.com/kacperfilipiuk/zguba-gov/internal/repository"
+)
+
+// PublicationHandler manages which dane.gov.pl dataset each office
+// publishes to and shows the sync state.
+type PublicationHandler struct {
+	repo *repository.PublicationRepo
+}
+
+func NewPublicationHandler(repo *repository.PublicationRepo) *PublicationHandler {
+	return &PublicationHandler{repo: repo}
+}
+
+func (h *PublicationHandler) List(c *gin.Context) {
+	pubs, err := h.repo.List()
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	if pubs == nil {
+		pubs = []model.Publication{}
+	}
+	c.JSON(http.StatusOK, pubs)
+}
+
+func (h *PublicationHandler) Get(c *gin.Context) {
+	pub, err := h.repo.Get(c.Param("email"))
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	if pub == nil {
+		jsonError(c, http.StatusNotFound, "api.publication_not_found")
+		return
+	}
+	c.JSON(http.StatusOK, pub)
+}
+
+// Put links the office to a dane.gov.pl dataset. Its resources are pushed
+// on the next publisher run.
+func (h *PublicationHandler) Put(c *gin.Context) {
+	email := c.Param("email")
+	if !strings.Contains(email, "@") {
+		jsonError(c, http.StatusBadRequest, "api.invalid_office_email")
+		return
+	}
+	var body model.PublicationSave
+	if err := c.ShouldBindJSON(&body); err != nil {
+		jsonErr(c, http.StatusBadRequest, err)
+		return
+	}
+	pub, err := h.repo.Save(email, strings.TrimSpace(body.DatasetID))
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, pub)
+}
+
+func (h *PublicationHandler) Delete(c *gin.Context) {
+	err := h.repo.Delete(c.Param("email"))
+	if err == sql.ErrNoRows {
+		jsonError(c, http.StatusNotFound, "api.publication_not_found")
+		return
+	}
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kacperfilipiuk/zguba-gov/internal/municipality"
+	"github.com/kacperfilipiuk/zguba-gov/internal/receipt"
+	"github.com/kacperfilipiuk/zguba-gov/internal/repository"
+)
+
+type ReceiptHandler struct {
+	repo       *repository.FoundItemRepo
+	categories *repository.CategoryRepo
+	munSvc     *municipality.Service
+	baseURL    string
+}
+
+func NewReceiptHandler(repo *repository.FoundItemRepo, categories *repository.CategoryRepo, munSvc *municipality.Service, baseURL string) *ReceiptHandler {
+	return &ReceiptHandler{repo: repo, categories: categories, munSvc: munSvc, baseURL: baseURL}
+}
+
+func (h *ReceiptHandler) Receipt(c *gin.Context) {
+	item, err := h.repo.GetByID(c.Param("id"))
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	if item == nil {
+		jsonError(c, http.StatusNotFound, "api.item_not_found")
+		return
+	}
+
+	resp := item.ToResponse()
+	r := receipt.Receipt{
+		Item:     resp,
+		Category: h.categories.Path(resp.Item.Category),
+		ItemURL:  h.baseURL + "/rzeczy/" + resp.ID,
+		IssuedAt: item.CreatedAt,
+	}
+	if units := h.munSvc.Match(resp.Municipality.Name, resp.Municipality.Type, resp.Municipality.ContactEmail); len(units) == 1 {
+		r.Office = &units[0]
+	}
+
+	var buf bytes.Buffer
+	if err := receipt.Render(&buf, r); err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	filename := fmt.Sprintf("protokol-%s.pdf", resp.ID)
+	c.Header("Content-Disposition", "inline; filename="+filename)
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kacperfilipiuk/zguba-gov/internal/events"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+	"github.com/kacperfilipiuk/zguba-gov/internal/repository"
+)
+
+const (
+	streamBuffer    = 64
+	streamHeartbeat = 25 * time.Second
+	replayBatch     = 500
+)
+
+// StreamHandler serves item changes as Server-Sent Events.
+type StreamHandler struct {
+	bus        *events.Bus
+	log        *repository.EventRepo
+	categories *repository.CategoryRepo
+}
+
+func NewStreamHandler(bus *events.Bus, log *repository.EventRepo, categories *repository.CategoryRepo) *StreamHandler {
+	return &StreamHandler{bus: bus, log: log, categories: categories}
+}
+
+// Stream sends every item event as it happens. The SSE id is the event log
+// ID, so a reconnecting client that sends Last-Event-ID (or ?lastEventId=)
+// first receives the events it missed. municipality and category filter
+// like they do for /api/found-items.
+func (h *StreamHandler) Stream(c *gin.Context) {
+	municipality := strings.ToLower(c.Query("municipality"))
+	category := c.Query("category")
+	if category != "" && !h.checkCategory(c, category) {
+		return
+	}
+
+	lastID, _ := strconv.ParseInt(c.GetHeader("Last-Event-ID"), 10, 64)
+	if lastID == 0 {
+		lastID, _ = strconv.ParseInt(c.Query("lastEventId"), 10, 64)
+	}
+
+	// Listen before replaying so nothing published in between is lost;
+	// events already replayed are skipped by ID below.
+	live, stop := h.bus.Listen(streamBuffer)
+	defer stop()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	matches := func(e model.Event) bool {
+		if e.Data == nil {
+			return false
+		}
+		if municipality != "" && !strings.Contains(strings.ToLower(e.Data.Municipality.Name), municipality) {
+			return false
+		}
+		return category == "" || h.categories.IsWithin(e.Data.Item.Category, category)
+	}
+	send := func(e model.Event) error {
+		lastID = e.ID
+		if !matches(e) {
+			return nil
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+		return err
+	}
+
+	if _, err := fmt.Fprint(c.Writer, "retry: 5000\n\n"); err != nil {
+		return
+	}
+	for lastID > 0 {
+		missed, err := h.log.Since(lastID, replayBatch)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+		for _, e := range missed {
+			if err := send(e); err != nil {
+				return
+			}
+		}
+		if len(missed) < replayBatch {
+			break
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+		case e, ok := <-live:
+			if !ok {
+				// Dropped for falling behind; the client reconnects
+				// with Last-Event-ID and catches up from the log.
+				return
+			}
+			if e.ID <= lastID {
+				continue
+			}
+			if err := send(e); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+func (h *StreamHandler) checkCategory(c *gin.Context, code string) bool {
+	cat, err := h.categories.Get(code)
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return false
+	}
+	if cat == nil {
+		jsonError(c, http.StatusUnprocessableEntity, "api.unknown_category", strconv.Quote(code))
+		return false
+	}
+	return true
+}
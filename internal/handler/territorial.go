@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kacperfilipiuk/zguba-gov/internal/municipality"
+	"github.com/kacperfilipiuk/zguba-gov/internal/repository"
+	"github.com/kacperfilipiuk/zguba-gov/internal/teryt"
+)
+
+// territorialMaxAge is how long clients may cache territorial units. The
+// register changes a few times a year.
+const territorialMaxAge = "public, max-age=86400"
+
+var unitTypes = map[string]bool{"gmina": true, "miasto": true, "powiat": true, "wojewodztwo": true}
+
+// TerritorialHandler serves the TERYT register of territorial units and
+// its hierarchy: voivodeship, county, gmina.
+type TerritorialHandler struct {
+	munSvc *municipality.Service
+	loader *teryt.Loader
+	stale  *repository.StaleUnitRepo
+}
+
+func NewTerritorialHandler(munSvc *municipality.Service, loader *teryt.Loader, stale *repository.StaleUnitRepo) *TerritorialHandler {
+	return &TerritorialHandler{munSvc: munSvc, loader: loader, stale: stale}
+}
+
+// unitDetail is a unit with the path to it from its voivodeship.
+type unitDetail struct {
+	municipality.TerritorialUnit
+	Ancestors  []municipality.TerritorialUnit `json:"ancestors"`
+	ChildCount int                            `json:"childCount"`
+}
+
+// List searches units by name (q), type and voivodeship (name or two-digit
+// code). Without q all matching units are listed in TERYT order.
+func (h *TerritorialHandler) List(c *gin.Context) {
+	q := municipality.Query{
+		Text:        c.Query("q"),
+		Type:        c.Query("type"),
+		Voivodeship: c.Query("voivodeship"),
+	}
+	if q.Type != "" && !unitTypes[q.Type] {
+		jsonError(c, http.StatusBadRequest, "api.invalid_type", "gmina, miasto, powiat, wojewodztwo")
+		return
+	}
+	if s := c.Query("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			jsonError(c, http.StatusBadRequest, "api.invalid_limit")
+			return
+		}
+		q.Limit = n
+	}
+	if h.cached(c) {
+		return
+	}
+	c.JSON(http.StatusOK, h.munSvc.Find(q))
+}
+
+func (h *TerritorialHandler) Get(c *gin.Context) {
+	unit, ok := h.munSvc.Get(c.Param("teryt"))
+	if !ok {
+		jsonError(c, http.StatusNotFound, "api.unit_not_found")
+		return
+	}
+	if h.cached(c) {
+		return
+	}
+	d := unitDetail{
+		TerritorialUnit: unit,
+		Ancestors:       []municipality.TerritorialUnit{},
+		ChildCount:      len(h.munSvc.Children(string(unit.ID))),
+	}
+	for id := unit.ParentID; id != ""; {
+		parent, ok := h.munSvc.Get(string(id))
+		if !ok {
+			break
+		}
+		d.Ancestors = append([]municipality.TerritorialUnit{parent}, d.Ancestors...)
+		id = parent.ParentID
+	}
+	c.JSON(http.StatusOK, d)
+}
+
+// Children lists the units directly below a unit: the counties and cities
+// with county rights of a voivodeship, or the gminas of a county.
+func (h *TerritorialHandler) Children(c *gin.Context) {
+	id := c.Param("teryt")
+	if _, ok := h.munSvc.Get(id); !ok {
+		jsonError(c, http.StatusNotFound, "api.unit_not_found")
+		return
+	}
+	if h.cached(c) {
+		return
+	}
+	c.JSON(http.StatusOK, h.munSvc.Children(id))
+}
+
+// Dataset describes the loaded dataset, what its last reload changed and
+// the municipalities of found items that no longer exist.
+func (h *TerritorialHandler) Dataset(c *gin.Context) {
+	stale, err := h.stale.List()
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"dataset": h.loader.Status(), "staleMunicipalities": stale})
+}
+
+// cached sets the caching headers, keyed on the loaded register version,
+// and answers 304 when the client's copy is current.
+func (h *TerritorialHandler) cached(c *gin.Context) bool {
+	etag := `W/"teryt-` + h.munSvc.Version() + `"`
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", territorialMaxAge)
+	if notModified(c, etag, time.Time{}) {
+		c.Status(http.StatusNotModified)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+	"github.com/kacperfilipiuk/zguba-gov/internal/repository"
+	"github.com/kacperfilipiuk/zguba-gov/internal/webhook"
+)
+
+// WebhookHandler manages webhook subscriptions and their deliveries.
+type WebhookHandler struct {
+	repo    *repository.WebhookRepo
+	service *webhook.Service
+}
+
+func NewWebhookHandler(repo *repository.WebhookRepo, service *webhook.Service) *WebhookHandler {
+	return &WebhookHandler{repo: repo, service: service}
+}
+
+func (h *WebhookHandler) List(c *gin.Context) {
+	subs, err := h.repo.List()
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+	if subs == nil {
+		subs = []model.WebhookSubscription{}
+	}
+	c.JSON(http.StatusOK, subs)
+}
+
+func (h *WebhookHandler) Get(c *gin.Context) {
+	sub, err := h.repo.Get(c.Param("id"))
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	if sub == nil {
+		jsonError(c, http.StatusNotFound, "api.subscription_not_found")
+		return
+	}
+	sub.Secret = ""
+	c.JSON(http.StatusOK, sub)
+}
+
+// Create returns the new subscription with its secret. This is the only
+// response that includes the secret.
+func (h *WebhookHandler) Create(c *gin.Context) {
+	var create model.WebhookSubscriptionCreate
+	if err := c.ShouldBindJSON(&create); err != nil {
+		jsonErr(c, http.StatusBadRequest, err)
+		return
+	}
+
+	sub, err := h.repo.Create(create)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, sub)
+}
+
+func (h *WebhookHandler) Update(c *gin.Context) {
+	var update model.WebhookSubscriptionUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		jsonErr(c, http.StatusBadRequest, err)
+		return
+	}
+
+	sub, err := h.repo.Update(c.Param("id"), update)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+	if sub == nil {
+		jsonError(c, http.StatusNotFound, "api.subscription_not_found")
+		return
+	}
+	sub.Secret = ""
+	c.JSON(http.StatusOK, sub)
+}
+
+func (h *WebhookHandler) Delete(c *gin.Context) {
+	err := h.repo.Delete(c.Param("id"))
+	if err == sql.ErrNoRows {
+		jsonError(c, http.StatusNotFound, "api.subscription_not_found")
+		return
+	}
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Test sends a signed webhook.test event to the subscription and reports
+// the status the receiver answered with.
+func (h *WebhookHandler) Test(c *gin.Context) {
+	sub, err := h.repo.Get(c.Param("id"))
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	if sub == nil {
+		jsonError(c, http.StatusNotFound, "api.subscription_not_found")
+		return
+	}
+	c.JSON(http.StatusOK, h.service.Test(c.Request.Context(), *sub))
+}
+
+// Deliveries lists deliveries; ?status=dead is the dead-letter view.
+func (h *WebhookHandler) Deliveries(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", model.DeliveryPending, model.DeliveryDelivered, model.DeliveryDead:
+	default:
+		jsonError(c, http.StatusBadRequest, "api.invalid_status", "pending, delivered, dead")
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	deliveries, err := h.repo.ListDeliveries(status, c.Query("subscription"), limit)
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []model.WebhookDelivery{}
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// Retry puts a dead delivery back in the queue.
+func (h *WebhookHandler) Retry(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		jsonError(c, http.StatusBadRequest, "api.invalid_delivery_id")
+		return
+	}
+	err = h.repo.Retry(id)
+	if err == sql.ErrNoRows {
+		jsonError(c, http.StatusNotFound, "api.delivery_not_found")
+		return
+	}
+	if err != nil {
+		jsonErr(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func webhookError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrWebhookURL), errors.Is(err, repository.ErrWebhookEvents),
+		errors.Is(err, repository.ErrWebhookSecret):
+		jsonErr(c, http.StatusUnprocessableEntity, err)
+	default:
+		jsonErr(c, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kacperfilipiuk/zguba-gov/internal/form"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+)
+
+// fieldInput is the data passed to a field template.
+type fieldInput struct {
+	Field      form.Field
+	Value      string
+	Categories []model.Category
+}
+
+// newWizard returns an empty wizard at the given step with field defaults
+// from the form definition applied, in the language of the request.
+func (h *PagesHandler) newWizard(c *gin.Context, step int) wizardData {
+	lang := h.lang(c)
+	cats, err := h.cats.ListIn(lang)
+	if err != nil {
+		log.Printf("list categories: %v", err)
+	}
+	d := wizardData{
+		Form:       h.forms[lang],
+		Categories: cats,
+		TotalSteps: h.form.TotalSteps(),
+		Attributes: map[string]string{},
+	}
+	for _, f := range h.form.Fields() {
+		if f.Default != "" {
+			d.SetValue(f.Name, f.Default)
+		}
+	}
+	d.setStep(step)
+	return d
+}
+
+func (d *wizardData) setStep(n int) {
+	if n < 1 {
+		n = 1
+	}
+	if n > d.TotalSteps {
+		n = d.TotalSteps
+	}
+	d.CurrentStep = n
+	d.Progress = (n - 1) * 100 / d.TotalSteps
+}
+
+// Step returns the definition of the current step.
+func (d wizardData) Step() *form.Step {
+	return d.Form.Step(d.CurrentStep)
+}
+
+// Input pairs a field with its current value for the field templates.
+func (d wizardData) Input(f form.Field) fieldInput {
+	in := fieldInput{Field: f, Value: d.Value(f.Name)}
+	if f.Type == form.TypeCategory {
+		in.Categories = d.Categories
+	}
+	return in
+}
+
+// Display returns the value of a field as shown in the summary.
+func (d wizardData) Display(f form.Field) string {
+	v := d.Value(f.Name)
+	if f.Type == form.TypeCategory {
+		for _, c := range d.Categories {
+			if c.Code == v {
+				return c.Path
+			}
+		}
+	}
+	return f.DisplayValue(v)
+}
+
+// Value returns the value of a core or custom field by form name.
+func (d wizardData) Value(name string) string {
+	if p := d.core(name); p != nil {
+		return *p
+	}
+	return d.Attributes[name]
+}
+
+func (d *wizardData) SetValue(name, v string) {
+	if p := d.core(name); p != nil {
+		*p = v
+		return
+	}
+	if d.Attributes == nil {
+		d.Attributes = map[string]string{}
+	}
+	d.Attributes[name] = v
+}
+
+// CustomAttributes returns the non-empty custom field values to store.
+func (d wizardData) CustomAttributes() map[string]string {
+	attrs := map[string]string{}
+	for _, f := range d.Form.CustomFields() {
+		if v := d.Attributes[f.Name]; v != "" {
+			attrs[f.Name] = v
+		}
+	}
+	return attrs
+}
+
+// Create returns the item entered in the wizard.
+func (d wizardData) Create() model.FoundItemCreate {
+	deadline, _ := strconv.Atoi(d.StorageDeadline)
+	create := model.FoundItemCreate{
+		Municipality: model.MunicipalityInfo{
+			Name:         d.MunicipalityName,
+			Type:         d.MunicipalityType,
+			ContactEmail: d.ContactEmail,
+		},
+		Item: model.ItemInfo{
+			Name:        d.ItemName,
+			Category:    d.ItemCategory,
+			Date:        d.ItemDate,
+			Location:    d.ItemLocation,
+			Status:      d.ItemStatus,
+			Description: d.ItemDescription,
+		},
+		Pickup: model.PickupInfo{
+			Deadline: deadline,
+			Location: d.PickupLocation,
+			Hours:    d.PickupHours,
+			Contact:  d.ContactPerson,
+		},
+		Attributes: d.CustomAttributes(),
+		Finder:     d.Finder(),
+	}
+	if create.Item.Status == "" {
+		create.Item.Status = "available"
+	}
+	return create
+}
+
+// Finder returns the entered finder data, or nil when nothing was entered.
+// It is recorded only with a new item; editing keeps the stored data.
+func (d wizardData) Finder() *model.Finder {
+	f := &model.Finder{
+		Name:           d.FinderName,
+		Address:        d.FinderAddress,
+		Phone:          d.FinderPhone,
+		Email:          d.FinderEmail,
+		ClaimsReward:   d.FinderClaimsReward == "tak",
+		WantsOwnership: d.FinderWantsOwnership == "tak",
+	}
+	if f.IsEmpty() {
+		return nil
+	}
+	return f
+}
+
+// wizardFields maps the fields of API bodies, as named in validation
+// errors, to the wizard fields.
+var wizardFields = map[string]string{
+	"municipality.name":         "municipalityName",
+	"municipality.type":         "municipalityType",
+	"municipality.contactEmail": "contactEmail",
+	"item.name":                 "itemName",
+	"item.category":             "itemCategory",
+	"item.date":                 "itemDate",
+	"item.location":             "itemLocation",
+	"item.status":               "itemStatus",
+	"item.description":          "itemDescription",
+	"pickup.deadline":           "storageDeadline",
+	"pickup.location":           "pickupLocation",
+	"pickup.hours":              "pickupHours",
+	"pickup.contact":            "contactPerson",
+	"finder.name":               "finderName",
+	"finder.address":            "finderAddress",
+	"finder.phone":              "finderPhone",
+	"finder.email":              "finderEmail",
+}
+
+func (d *wizardData) core(name string) *string {
+	switch name {
+	case "municipalityName":
+		return &d.MunicipalityName
+	case "municipalityType":
+		return &d.MunicipalityType
+	case "contactEmail":
+		return &d.ContactEmail
+	case "itemName":
+		return &d.ItemName
+	case "itemCategory":
+		return &d.ItemCategory
+	case "itemDate":
+		return &d.ItemDate
+	case "itemLocation":
+		return &d.ItemLocation
+	case "itemStatus":
+		return &d.ItemStatus
+	case "itemDescription":
+		return &d.ItemDescription
+	case "storageDeadline":
+		return &d.StorageDeadline
+	case "pickupLocation":
+		return &d.PickupLocation
+	case "pickupHours":
+		return &d.PickupHours
+	case "contactPerson":
+		return &d.ContactPerson
+	case "finderName":
+		return &d.FinderName
+	case "finderAddress":
+		return &d.FinderAddress
+	case "finderPhone":
+		return &d.FinderPhone
+	case "finderEmail":
+		return &d.FinderEmail
+	case "finderClaimsReward":
+		return &d.FinderClaimsReward
+	case "finderWantsOwnership":
+		return &d.FinderWantsOwnership
+	}
+	return nil
+}
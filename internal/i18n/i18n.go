@@ -0,0 +1,139 @@
+// Package i18n holds the message catalogs of the web interface and the API
+// in Polish, English and Ukrainian, and picks the language of a request.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// Supported languages.
+const (
+	Polish    = "pl"
+	English   = "en"
+	Ukrainian = "uk"
+)
+
+// Default is the language of the interface for clients that ask for none
+// of the supported ones.
+const Default = Polish
+
+// Languages lists the supported languages, the default first.
+var Languages = []string{Polish, English, Ukrainian}
+
+//go:embed locales/*.json
+var locales embed.FS
+
+// catalogs maps a language to its messages by key.
+var catalogs = load()
+
+var matcher = language.NewMatcher([]language.Tag{language.Polish, language.English, language.Ukrainian})
+
+func load() map[string]map[string]string {
+	catalogs := map[string]map[string]string{}
+	for _, lang := range Languages {
+		data, err := locales.ReadFile(path.Join("locales", lang+".json"))
+		if err != nil {
+			panic(fmt.Sprintf("i18n: %v", err))
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("i18n: %s catalog: %v", lang, err))
+		}
+		catalogs[lang] = messages
+	}
+	return catalogs
+}
+
+// Supported reports whether lang is one of Languages.
+func Supported(lang string) bool {
+	_, ok := catalogs[lang]
+	return ok
+}
+
+// Lookup returns the message key in lang, or in Polish when lang has no
+// translation of it.
+func Lookup(lang, key string) (string, bool) {
+	if msg, ok := catalogs[lang][key]; ok {
+		return msg, true
+	}
+	msg, ok := catalogs[Default][key]
+	return msg, ok
+}
+
+// T returns the message key in lang formatted with args. A key missing
+// from every catalog is returned as is, so that it shows up in the page.
+func T(lang, key string, args ...any) string {
+	msg, ok := Lookup(lang, key)
+	if !ok {
+		return key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// Messages returns the messages of lang whose keys start with prefix, keyed
+// by the rest of the key, for use by page scripts.
+func Messages(lang, prefix string) map[string]string {
+	out := map[string]string{}
+	for key := range catalogs[Default] {
+		if name, ok := strings.CutPrefix(key, prefix); ok {
+			out[name], _ = Lookup(lang, key)
+		}
+	}
+	return out
+}
+
+// Match returns the supported language the Accept-Language header prefers,
+// and false when it names none of them.
+func Match(acceptLanguage string) (string, bool) {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return Default, false
+	}
+	_, i, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return Default, false
+	}
+	return Languages[i], true
+}
+
+// Error is an error with a message from the catalogs. Its Error method
+// returns the English text, so logs read the same whatever the language of
+// the request.
+type Error struct {
+	Key  string
+	Args []any
+}
+
+// NewError returns an Error with the message key formatted with args.
+func NewError(key string, args ...any) error {
+	return &Error{Key: key, Args: args}
+}
+
+func (e *Error) Error() string {
+	return T(English, e.Key, e.Args...)
+}
+
+// In returns the message in lang.
+func (e *Error) In(lang string) string {
+	return T(lang, e.Key, e.Args...)
+}
+
+// Translate returns the text of err in lang if err is or wraps an Error,
+// and reports whether it did.
+func Translate(lang string, err error) (string, bool) {
+	var e *Error
+	if !errors.As(err, &e) {
+		return "", false
+	}
+	return e.In(lang), true
+}
@@ -0,0 +1,288 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/directory"
+	"github.com/kacperfilipiuk/zguba-gov/internal/events"
+	"github.com/kacperfilipiuk/zguba-gov/internal/geo"
+	"github.com/kacperfilipiuk/zguba-gov/internal/i18n"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+	"github.com/kacperfilipiuk/zguba-gov/internal/municipality"
+	"github.com/kacperfilipiuk/zguba-gov/internal/repository"
+	"github.com/xuri/excelize/v2"
+)
+
+// RowError is a problem with one row. Message is in English until the
+// result is localized.
+type RowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+	err     *i18n.Error
+}
+
+func rowError(row int, field, key string, args ...any) RowError {
+	err := &i18n.Error{Key: key, Args: args}
+	return RowError{Row: row, Field: field, Message: err.Error(), err: err}
+}
+
+type Result struct {
+	DryRun   bool       `json:"dryRun"`
+	Rows     int        `json:"rows"`
+	Imported int        `json:"imported"`
+	Errors   []RowError `json:"errors"`
+}
+
+// Localize translates the row error messages into lang.
+func (r *Result) Localize(lang string) {
+	for i, e := range r.Errors {
+		if e.err != nil {
+			r.Errors[i].Message = e.err.In(lang)
+		}
+	}
+}
+
+type Importer struct {
+	repo       *repository.FoundItemRepo
+	categories *repository.CategoryRepo
+	munSvc     *municipality.Service
+	directory  *directory.Directory
+	geocoder   geo.Geocoder
+	events     *events.Bus
+}
+
+func New(repo *repository.FoundItemRepo, categories *repository.CategoryRepo, munSvc *municipality.Service, dir *directory.Directory, geocoder geo.Geocoder, bus *events.Bus) *Importer {
+	return &Importer{repo: repo, categories: categories, munSvc: munSvc, directory: dir, geocoder: geocoder, events: bus}
+}
+
+// Import validates all rows and, unless dryRun is set, stores them in one
+// transaction. Nothing is stored when any row fails validation.
+func (im *Importer) Import(rows []Row, dryRun bool) (*Result, error) {
+	res := &Result{DryRun: dryRun, Rows: len(rows), Errors: []RowError{}}
+
+	creates := make([]model.FoundItemCreate, 0, len(rows))
+	for _, row := range rows {
+		create, errs, err := im.convert(row)
+		if err != nil {
+			return nil, err
+		}
+		if len(errs) > 0 {
+			res.Errors = append(res.Errors, errs...)
+			continue
+		}
+		creates = append(creates, create)
+	}
+
+	if dryRun || len(res.Errors) > 0 {
+		return res, nil
+	}
+
+	ids, err := im.repo.CreateBatch(creates)
+	if err != nil {
+		return nil, err
+	}
+	res.Imported = len(ids)
+
+	for _, id := range ids {
+		item, err := im.repo.GetByID(id)
+		if err != nil {
+			return nil, err
+		}
+		if item != nil {
+			im.events.ItemCreated(item.ToResponse())
+		}
+	}
+	return res, nil
+}
+
+// convert turns a row into a new item. Values are resolved and parsed as
+// an import allows, e.g. municipality and category names or Polish dates,
+// and the result is checked with the same rules as items created through
+// the API.
+func (im *Importer) convert(row Row) (model.FoundItemCreate, []RowError, error) {
+	var errs []RowError
+	fail := func(field, key string, args ...any) {
+		errs = append(errs, rowError(row.Line, field, key, args...))
+	}
+
+	c := model.FoundItemCreate{
+		Municipality: model.MunicipalityInfo{
+			Name:         row.get("municipalityName"),
+			Type:         row.get("municipalityType"),
+			ContactEmail: row.get("contactEmail"),
+		},
+		Item: model.ItemInfo{
+			Name:        row.get("itemName"),
+			Location:    row.get("itemLocation"),
+			Description: row.get("itemDescription"),
+		},
+		Pickup: model.PickupInfo{
+			Location: row.get("pickupLocation"),
+			Hours:    row.get("pickupHours"),
+			Contact:  row.get("contactPerson"),
+		},
+	}
+
+	if c.Municipality.Name != "" {
+		if unit, key, args := im.resolveUnit(c.Municipality); unit == nil {
+			fail("municipalityName", key, args...)
+		} else {
+			c.Municipality.Name = unit.Name
+			c.Municipality.Type = unit.Type
+			if c.Municipality.ContactEmail == "" {
+				// Only a verified office address; a guessed one is left out.
+				email, err := im.directory.VerifiedEmail(*unit)
+				if err != nil {
+					fail("contactEmail", "import.directory_failed", err)
+				}
+				c.Municipality.ContactEmail = email
+			}
+		}
+	}
+
+	if raw := row.get("itemCategory"); raw != "" {
+		if code, ok := im.categories.Resolve(raw); !ok {
+			fail("itemCategory", "import.category_unknown", raw)
+		} else {
+			c.Item.Category = code
+		}
+	}
+	if raw := row.get("itemDate"); raw != "" {
+		if d, ok := parseDate(raw); !ok {
+			fail("itemDate", "import.date_invalid", raw)
+		} else {
+			c.Item.Date = d
+		}
+	}
+	if status, ok := parseStatus(row.get("itemStatus")); !ok {
+		fail("itemStatus", "import.status_unknown", row.get("itemStatus"))
+	} else {
+		c.Item.Status = status
+	}
+
+	deadline := 30
+	if raw := row.get("storageDeadline"); raw != "" {
+		d, err := strconv.Atoi(raw)
+		if err != nil {
+			d = -1
+		}
+		deadline = d
+	}
+	c.Pickup.Deadline = deadline
+
+	var invalid model.ValidationError
+	if err := c.Validate(im.categories); errors.As(err, &invalid) {
+		errs = append(errs, rowErrors(row.Line, invalid, errs)...)
+	} else if err != nil {
+		return c, nil, err
+	}
+
+	if len(errs) == 0 {
+		if err := geo.Locate(context.Background(), im.geocoder, c.Municipality, &c.Item, &c.Pickup); err != nil {
+			log.Printf("import row %d: %v", row.Line, err)
+		}
+	}
+	return c, errs, nil
+}
+
+// validatedColumns maps the fields of model.Validate to import columns.
+// The municipality type comes from the resolved unit, so its errors belong
+// to the name.
+var validatedColumns = map[string]string{
+	"municipality.name":         "municipalityName",
+	"municipality.type":         "municipalityName",
+	"municipality.contactEmail": "contactEmail",
+	"item.name":                 "itemName",
+	"item.category":             "itemCategory",
+	"item.date":                 "itemDate",
+	"item.location":             "itemLocation",
+	"item.status":               "itemStatus",
+	"item.description":          "itemDescription",
+	"pickup.deadline":           "storageDeadline",
+	"pickup.location":           "pickupLocation",
+	"pickup.hours":              "pickupHours",
+	"pickup.contact":            "contactPerson",
+}
+
+// rowErrors turns the rules a row breaks into errors of its columns, at
+// most one per column and none for columns already in errs. A missing
+// contact e-mail is allowed: it is left empty rather than guessed when the
+// office has no verified address.
+func rowErrors(line int, invalid model.ValidationError, errs []RowError) []RowError {
+	failed := map[string]bool{}
+	for _, e := range errs {
+		failed[e.Field] = true
+	}
+	var out []RowError
+	for _, fe := range invalid {
+		if fe.Field == "municipality.contactEmail" && fe.Rule == "required" {
+			continue
+		}
+		column, ok := validatedColumns[fe.Field]
+		if !ok {
+			column = fe.Field
+		}
+		if failed[column] {
+			continue
+		}
+		failed[column] = true
+		out = append(out, rowError(line, column, "validation."+fe.Rule, fe.Args...))
+	}
+	return out
+}
+
+func (im *Importer) resolveUnit(m model.MunicipalityInfo) (*municipality.TerritorialUnit, string, []any) {
+	exact := im.munSvc.Match(m.Name, m.Type, m.ContactEmail)
+	if len(exact) == 0 {
+		if candidates := im.munSvc.Search(m.Name, m.Type); len(candidates) == 1 {
+			exact = candidates
+		}
+	}
+
+	switch len(exact) {
+	case 0:
+		return nil, "import.municipality_unknown", []any{m.Name}
+	case 1:
+		return &exact[0], "", nil
+	default:
+		return nil, "import.municipality_ambiguous", []any{m.Name, len(exact)}
+	}
+}
+
+var dateLayouts = []string{"2006-01-02", "02.01.2006", "2.1.2006", "2006/01/02"}
+
+func parseDate(s string) (string, bool) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format("2006-01-02"), true
+		}
+	}
+	// XLSX cells hold dates as serial day numbers.
+	if serial, err := strconv.ParseFloat(s, 64); err == nil && serial > 0 {
+		if t, err := excelize.ExcelDateToTime(serial, false); err == nil {
+			return t.Format("2006-01-02"), true
+		}
+	}
+	return "", false
+}
+
+var statusAliases = map[string]string{
+	"":           "available",
+	"available":  "available",
+	"claimed":    "claimed",
+	"expired":    "expired",
+	"oczekuje":   "available",
+	"odebrana":   "claimed",
+	"przekazana": "expired",
+}
+
+func parseStatus(s string) (string, bool) {
+	status, ok := statusAliases[strings.ToLower(s)]
+	return status, ok
+}
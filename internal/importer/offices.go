@@ -0,0 +1,150 @@
+package importer
+
+import (
+	"net/mail"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/directory"
+	"github.com/kacperfilipiuk/zguba-gov/internal/i18n"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+)
+
+// ErrNoTerytColumn is returned for files without a TERYT code column.
+var ErrNoTerytColumn = i18n.NewError("import.no_teryt_column")
+
+// officeHeaders maps the accepted office directory column headers, Polish
+// or English, to directory fields.
+var officeHeaders = map[string]string{
+	"teryt": "teryt", "kod teryt": "teryt",
+	"name": "name", "urząd": "name", "nazwa urzędu": "name",
+	"email": "email", "e-mail": "email",
+	"epuap": "epuap", "adres epuap": "epuap", "skrytka epuap": "epuap",
+	"phone": "phone", "telefon": "phone",
+	"address": "address", "adres": "address",
+	"hours": "hours", "godziny": "hours", "godziny pracy": "hours",
+	"verified": "verified", "zweryfikowany": "verified",
+}
+
+// officeLimits are the maximum lengths of the text fields, as in
+// model.OfficeContactSave.
+var officeLimits = map[string]int{"name": 200, "email": 254, "epuap": 200, "phone": 50, "address": 300, "hours": 300}
+
+// ImportOffices validates office directory records of a CSV or XLSX file
+// (header row first, see ReadRecords) and, unless dryRun is set, saves them
+// in one transaction. Nothing is saved when any row is invalid. allow, when
+// not nil, is asked with the current contact of each unit whether its entry
+// may be replaced. E-mail addresses are unverified unless the verified
+// column marks them verified.
+func (im *Importer) ImportOffices(records [][]string, dryRun bool, allow func(current model.OfficeContact) bool) (*Result, error) {
+	res := &Result{DryRun: dryRun, Errors: []RowError{}}
+	if len(records) == 0 {
+		return res, nil
+	}
+	columns := make([]string, len(records[0]))
+	hasTeryt := false
+	for i, h := range records[0] {
+		columns[i] = officeHeaders[strings.ToLower(strings.TrimSpace(h))]
+		hasTeryt = hasTeryt || columns[i] == "teryt"
+	}
+	if !hasTeryt {
+		return nil, ErrNoTerytColumn
+	}
+
+	var offices []model.OfficeContact
+	seen := map[string]int{}
+	for i, rec := range records[1:] {
+		line := i + 2
+		fields := map[string]string{}
+		for j, v := range rec {
+			if j < len(columns) && columns[j] != "" {
+				fields[columns[j]] = strings.TrimSpace(v)
+			}
+		}
+		if strings.Join(rec, "") == "" {
+			continue
+		}
+		res.Rows++
+
+		fail := func(field, key string, args ...any) {
+			res.Errors = append(res.Errors, rowError(line, field, key, args...))
+		}
+		before := len(res.Errors)
+
+		teryt := fields["teryt"]
+		if len(teryt) == 6 {
+			teryt = "0" + teryt
+		}
+		switch _, known := im.munSvc.Get(teryt); {
+		case teryt == "":
+			fail("teryt", "import.teryt_required")
+		case !known:
+			fail("teryt", "import.teryt_unknown", teryt)
+		case seen[teryt] > 0:
+			fail("teryt", "import.teryt_duplicate", teryt, seen[teryt])
+		default:
+			seen[teryt] = line
+			if allow != nil {
+				current, err := im.directory.Lookup(teryt)
+				if err != nil {
+					return nil, err
+				}
+				if !allow(current) {
+					fail("teryt", "import.office_forbidden", teryt)
+				}
+			}
+		}
+		if email := fields["email"]; email != "" {
+			if a, err := mail.ParseAddress(email); err != nil || a.Address != email {
+				fail("email", "import.email_invalid", email)
+			}
+		}
+		for field, limit := range officeLimits {
+			if utf8.RuneCountInString(fields[field]) > limit {
+				fail(field, "import.max_length", limit)
+			}
+		}
+		verified := false
+		if v := fields["verified"]; v != "" {
+			b, ok := parseYesNo(v)
+			if !ok {
+				fail("verified", "import.yes_no_invalid", v)
+			}
+			verified = b
+		}
+		if len(res.Errors) > before {
+			continue
+		}
+
+		offices = append(offices, directory.Entry(teryt, model.OfficeContactSave{
+			Name:          fields["name"],
+			Email:         fields["email"],
+			EmailVerified: verified,
+			EPUAP:         fields["epuap"],
+			Phone:         fields["phone"],
+			Address:       fields["address"],
+			Hours:         fields["hours"],
+		}))
+	}
+
+	if dryRun || len(res.Errors) > 0 {
+		return res, nil
+	}
+	if err := im.directory.SaveAll(offices...); err != nil {
+		return nil, err
+	}
+	res.Imported = len(offices)
+	return res, nil
+}
+
+func parseYesNo(s string) (bool, bool) {
+	switch strings.ToLower(s) {
+	case "tak", "t":
+		return true, true
+	case "nie", "n":
+		return false, true
+	}
+	b, err := strconv.ParseBool(s)
+	return b, err == nil
+}
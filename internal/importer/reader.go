@@ -0,0 +1,244 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// Fields lists the import field keys. They match the wizard form field names.
+var Fields = []string{
+	"municipalityName",
+	"municipalityType",
+	"contactEmail",
+	"itemName",
+	"itemCategory",
+	"itemDate",
+	"itemLocation",
+	"itemStatus",
+	"itemDescription",
+	"storageDeadline",
+	"pickupLocation",
+	"pickupHours",
+	"contactPerson",
+}
+
+// DefaultHeaders maps the column headers written by the wizard CSV export
+// to import field keys.
+var DefaultHeaders = map[string]string{
+	"Samorząd":                  "municipalityName",
+	"Typ samorządu":             "municipalityType",
+	"Email kontaktowy":          "contactEmail",
+	"Nazwa przedmiotu":          "itemName",
+	"Kategoria":                 "itemCategory",
+	"Data znalezienia":          "itemDate",
+	"Miejsce znalezienia":       "itemLocation",
+	"Status":                    "itemStatus",
+	"Opis":                      "itemDescription",
+	"Termin przechowania (dni)": "storageDeadline",
+	"Miejsce odbioru":           "pickupLocation",
+	"Godziny odbioru":           "pickupHours",
+	"Osoba kontaktowa":          "contactPerson",
+}
+
+// Row is a single source record with values keyed by import field.
+type Row struct {
+	Line   int
+	Fields map[string]string
+}
+
+func (r Row) get(field string) string {
+	return strings.TrimSpace(r.Fields[field])
+}
+
+// FormatFromFilename guesses the file format from its extension.
+func FormatFromFilename(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".xlsx":
+		return FormatXLSX
+	case ".csv", ".txt":
+		return FormatCSV
+	}
+	return ""
+}
+
+// ParseMapping parses a "Header=field,Other header=field" mapping string.
+func ParseMapping(s string) (map[string]string, error) {
+	mapping := map[string]string{}
+	if strings.TrimSpace(s) == "" {
+		return mapping, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		header, field, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid mapping %q, expected Header=field", pair)
+		}
+		mapping[strings.TrimSpace(header)] = strings.TrimSpace(field)
+	}
+	return mapping, nil
+}
+
+// Read parses CSV or XLSX input into rows. The mapping overrides or extends
+// DefaultHeaders; columns that map to no field are ignored.
+func Read(r io.Reader, format string, mapping map[string]string) ([]Row, error) {
+	headers, err := buildHeaderMap(mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := ReadRecords(r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	// A single-record wizard export is laid out vertically as "Pole,Wartość".
+	if len(records[0]) >= 2 && strings.TrimSpace(records[0][0]) == "Pole" && strings.TrimSpace(records[0][1]) == "Wartość" {
+		records = transpose(records[1:])
+	}
+
+	columns := make([]string, len(records[0]))
+	mapped := 0
+	for i, h := range records[0] {
+		if field, ok := headers[normalizeHeader(h)]; ok {
+			columns[i] = field
+			mapped++
+		}
+	}
+	if mapped == 0 {
+		return nil, fmt.Errorf("no recognised columns in header row")
+	}
+
+	var rows []Row
+	for i, rec := range records[1:] {
+		row := Row{Line: i + 2, Fields: map[string]string{}}
+		empty := true
+		for j, v := range rec {
+			if j >= len(columns) || columns[j] == "" {
+				continue
+			}
+			row.Fields[columns[j]] = v
+			if strings.TrimSpace(v) != "" {
+				empty = false
+			}
+		}
+		if empty {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// ReadRecords returns the records of a CSV or XLSX file, header row
+// included.
+func ReadRecords(r io.Reader, format string) ([][]string, error) {
+	var (
+		records [][]string
+		err     error
+	)
+	switch format {
+	case FormatCSV:
+		records, err = readCSV(r)
+	case FormatXLSX:
+		records, err = readXLSX(r)
+	default:
+		return nil, fmt.Errorf("unsupported format: %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("file is empty")
+	}
+	return records, nil
+}
+
+func buildHeaderMap(mapping map[string]string) (map[string]string, error) {
+	known := make(map[string]bool, len(Fields))
+	headers := make(map[string]string, len(DefaultHeaders)+len(Fields)+len(mapping))
+	for _, f := range Fields {
+		known[f] = true
+		headers[normalizeHeader(f)] = f
+	}
+	for h, f := range DefaultHeaders {
+		headers[normalizeHeader(h)] = f
+	}
+	for h, f := range mapping {
+		if !known[f] {
+			return nil, fmt.Errorf("mapping for %q: unknown field %q", h, f)
+		}
+		headers[normalizeHeader(h)] = f
+	}
+	return headers, nil
+}
+
+func normalizeHeader(h string) string {
+	return strings.ToLower(strings.TrimSpace(h))
+}
+
+func readCSV(r io.Reader) ([][]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF})
+
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.FieldsPerRecord = -1
+	// Spreadsheets saved with a Polish locale use semicolons.
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		cr.Comma = ';'
+	}
+
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parse csv: %w", err)
+	}
+	return records, nil
+}
+
+func readXLSX(r io.Reader) ([][]string, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("open xlsx: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("workbook has no sheets")
+	}
+	records, err := f.GetRows(sheets[0], excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, fmt.Errorf("read sheet %q: %w", sheets[0], err)
+	}
+	return records, nil
+}
+
+func transpose(pairs [][]string) [][]string {
+	header := make([]string, 0, len(pairs))
+	values := make([]string, 0, len(pairs))
+	for _, p := range pairs {
+		if len(p) == 0 {
+			continue
+		}
+		header = append(header, p[0])
+		if len(p) > 1 {
+			values = append(values, p[1])
+		} else {
+			values = append(values, "")
+		}
+	}
+	return [][]string{header, values}
+}
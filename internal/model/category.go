@@ -0,0 +1,91 @@
+package model
+
+import (
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/i18n"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Category is an entry of the item taxonomy. Items reference categories by
+// Code, which never changes once created; labels can be edited freely.
+type Category struct {
+	Code       string    `json:"code"`
+	ParentCode string    `json:"parentCode,omitempty"`
+	LabelPL    string    `json:"labelPl"`
+	LabelEN    string    `json:"labelEn"`
+	LabelUK    string    `json:"labelUk"`
+	SortOrder  int       `json:"sortOrder"`
+	Path       string    `json:"path"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+type CategoryCreate struct {
+	Code       string `json:"code"`
+	ParentCode string `json:"parentCode,omitempty"`
+	LabelPL    string `json:"labelPl" binding:"required"`
+	LabelEN    string `json:"labelEn"`
+	LabelUK    string `json:"labelUk"`
+	SortOrder  int    `json:"sortOrder"`
+}
+
+type CategoryUpdate struct {
+	ParentCode *string `json:"parentCode,omitempty"`
+	LabelPL    *string `json:"labelPl,omitempty"`
+	LabelEN    *string `json:"labelEn,omitempty"`
+	LabelUK    *string `json:"labelUk,omitempty"`
+	SortOrder  *int    `json:"sortOrder,omitempty"`
+}
+
+// Label returns the label in lang, or the Polish one when the category has
+// none in that language.
+func (c Category) Label(lang string) string {
+	switch {
+	case lang == i18n.English && c.LabelEN != "":
+		return c.LabelEN
+	case lang == i18n.Ukrainian && c.LabelUK != "":
+		return c.LabelUK
+	}
+	return c.LabelPL
+}
+
+var polishLetters = map[rune]rune{
+	'ą': 'a', 'ć': 'c', 'ę': 'e', 'ł': 'l', 'ń': 'n',
+	'ó': 'o', 'ś': 's', 'ź': 'z', 'ż': 'z',
+}
+
+// CategoryCode derives a stable ASCII code from a label or a legacy
+// free-text category, e.g. "Odzież damska" → "odziez-damska".
+func CategoryCode(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range norm.NFC.String(strings.ToLower(strings.TrimSpace(s))) {
+		if repl, ok := polishLetters[r]; ok {
+			r = repl
+		}
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		default:
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// Capitalize upper-cases the first letter, which may be multi-byte.
+func Capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
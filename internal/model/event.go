@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// Item lifecycle event types.
+const (
+	EventItemCreated       = "found_item.created"
+	EventItemUpdated       = "found_item.updated"
+	EventItemDeleted       = "found_item.deleted"
+	EventItemStatusChanged = "found_item.status_changed"
+)
+
+// EventTypes lists every event type subscribers can ask for.
+var EventTypes = []string{EventItemCreated, EventItemUpdated, EventItemDeleted, EventItemStatusChanged}
+
+func IsEventType(t string) bool {
+	for _, et := range EventTypes {
+		if et == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is a change to a found item as recorded in the event log. Data is
+// the item after the change, or the last known state for deletions.
+type Event struct {
+	ID             int64              `json:"id"`
+	Type           string             `json:"type"`
+	ItemID         string             `json:"itemId"`
+	OccurredAt     time.Time          `json:"occurredAt"`
+	PreviousStatus string             `json:"previousStatus,omitempty"`
+	Data           *FoundItemResponse `json:"data"`
+}
@@ -21,7 +21,12 @@ type FoundItem struct {
 	PickupLocation    string
 	PickupHours       sql.NullString
 	PickupContact     sql.NullString
+	ItemLat           sql.NullFloat64
+	ItemLon           sql.NullFloat64
+	PickupLat         sql.NullFloat64
+	PickupLon         sql.NullFloat64
 	Categories        sql.NullString
+	Attributes        sql.NullString
 	CreatedAt         time.Time
 	UpdatedAt         time.Time
 }
@@ -39,6 +44,9 @@ type ItemInfo struct {
 	Location    string `json:"location"`
 	Status      string `json:"status"`
 	Description string `json:"description,omitempty"`
+	// Geo is where the item was found. Items registered without it are
+	// placed by geocoding Location.
+	Geo *GeoPoint `json:"geo,omitempty"`
 }
 
 type PickupInfo struct {
@@ -46,30 +54,64 @@ type PickupInfo struct {
 	Location string `json:"location"`
 	Hours    string `json:"hours,omitempty"`
 	Contact  string `json:"contact,omitempty"`
+	// Geo is the pickup point, geocoded from Location when not given.
+	Geo *GeoPoint `json:"geo,omitempty"`
+}
+
+// GeoPoint is a WGS 84 position in degrees.
+type GeoPoint struct {
+	Lat float64 `json:"lat" binding:"gte=-90,lte=90"`
+	Lon float64 `json:"lon" binding:"gte=-180,lte=180"`
+}
+
+func geoPoint(lat, lon sql.NullFloat64) *GeoPoint {
+	if !lat.Valid || !lon.Valid {
+		return nil
+	}
+	return &GeoPoint{Lat: lat.Float64, Lon: lon.Float64}
+}
+
+// BBox is a bounding box in degrees, in GeoJSON order: west, south, east,
+// north.
+type BBox struct {
+	MinLon, MinLat, MaxLon, MaxLat float64
+}
+
+// Near selects positions within Radius metres of Point.
+type Near struct {
+	Point  GeoPoint
+	Radius float64
 }
 
 type FoundItemCreate struct {
-	Municipality MunicipalityInfo `json:"municipality"`
-	Item         ItemInfo         `json:"item"`
-	Pickup       PickupInfo       `json:"pickup"`
-	Categories   []string         `json:"categories,omitempty"`
+	Municipality MunicipalityInfo  `json:"municipality"`
+	Item         ItemInfo          `json:"item"`
+	Pickup       PickupInfo        `json:"pickup"`
+	Categories   []string          `json:"categories,omitempty"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+	// Finder is recorded by the wizard at registration. API bodies cannot
+	// carry it: the finder endpoints, which require the office token,
+	// write it.
+	Finder *Finder `json:"-"`
 }
 
 type FoundItemUpdate struct {
-	Municipality *MunicipalityInfo `json:"municipality,omitempty"`
-	Item         *ItemInfo         `json:"item,omitempty"`
-	Pickup       *PickupInfo       `json:"pickup,omitempty"`
-	Categories   *[]string         `json:"categories,omitempty"`
+	Municipality *MunicipalityInfo  `json:"municipality,omitempty"`
+	Item         *ItemInfo          `json:"item,omitempty"`
+	Pickup       *PickupInfo        `json:"pickup,omitempty"`
+	Categories   *[]string          `json:"categories,omitempty"`
+	Attributes   *map[string]string `json:"attributes,omitempty"`
 }
 
 type FoundItemResponse struct {
-	ID           string           `json:"id"`
-	Municipality MunicipalityInfo `json:"municipality"`
-	Item         ItemInfo         `json:"item"`
-	Pickup       PickupInfo       `json:"pickup"`
-	Categories   []string         `json:"categories"`
-	CreatedAt    string           `json:"createdAt,omitempty"`
-	UpdatedAt    string           `json:"updatedAt,omitempty"`
+	ID           string            `json:"id"`
+	Municipality MunicipalityInfo  `json:"municipality"`
+	Item         ItemInfo          `json:"item"`
+	Pickup       PickupInfo        `json:"pickup"`
+	Categories   []string          `json:"categories"`
+	Attributes   map[string]string `json:"attributes"`
+	CreatedAt    string            `json:"createdAt,omitempty"`
+	UpdatedAt    string            `json:"updatedAt,omitempty"`
 }
 
 func (fi *FoundItem) ToResponse() FoundItemResponse {
@@ -81,6 +123,11 @@ func (fi *FoundItem) ToResponse() FoundItemResponse {
 		cats = []string{}
 	}
 
+	attrs := map[string]string{}
+	if fi.Attributes.Valid && fi.Attributes.String != "" {
+		_ = json.Unmarshal([]byte(fi.Attributes.String), &attrs)
+	}
+
 	return FoundItemResponse{
 		ID: fi.ID,
 		Municipality: MunicipalityInfo{
@@ -95,19 +142,47 @@ func (fi *FoundItem) ToResponse() FoundItemResponse {
 			Location:    fi.ItemLocation,
 			Status:      fi.ItemStatus,
 			Description: fi.ItemDescription.String,
+			Geo:         geoPoint(fi.ItemLat, fi.ItemLon),
 		},
 		Pickup: PickupInfo{
 			Deadline: fi.PickupDeadline,
 			Location: fi.PickupLocation,
 			Hours:    fi.PickupHours.String,
 			Contact:  fi.PickupContact.String,
+			Geo:      geoPoint(fi.PickupLat, fi.PickupLon),
 		},
 		Categories: cats,
+		Attributes: attrs,
 		CreatedAt:  fi.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:  fi.UpdatedAt.Format(time.RFC3339),
 	}
 }
 
+// Finder is the person who handed the item in (znalazca). It is personal
+// data: stored encrypted, never part of FoundItemResponse, and readable
+// only by the office that registered the item.
+type Finder struct {
+	Name    string `json:"name"`
+	Address string `json:"address,omitempty"`
+	Phone   string `json:"phone,omitempty"`
+	Email   string `json:"email,omitempty"`
+	// ClaimsReward records that the finder demands the reward (znaleźne).
+	ClaimsReward bool `json:"claimsReward"`
+	// WantsOwnership records that the finder wants to take the item if the
+	// owner does not claim it.
+	WantsOwnership bool `json:"wantsOwnership"`
+}
+
+func (f *Finder) IsEmpty() bool {
+	return f == nil || *f == Finder{}
+}
+
+type FinderResponse struct {
+	ItemID       string  `json:"itemId"`
+	Finder       *Finder `json:"finder"`
+	AnonymisedAt string  `json:"anonymisedAt,omitempty"`
+}
+
 type StatsResponse struct {
 	FoundItems        FoundItemStats      `json:"foundItems"`
 	TopCategories     []CategoryCount     `json:"topCategories"`
@@ -130,6 +205,34 @@ type MunicipalityCount struct {
 	Count int    `json:"count"`
 }
 
+// DatasetSummary describes a set of items for catalog metadata: how many
+// there are, when the first was registered and when any last changed.
+type DatasetSummary struct {
+	Count    int
+	Issued   time.Time
+	Modified time.Time
+}
+
+// OfficeSummary is the DatasetSummary of the items registered by one
+// office, with the municipality details they were registered under.
+type OfficeSummary struct {
+	Name  string
+	Type  string
+	Email string
+	DatasetSummary
+}
+
+// Draft is a half-filled wizard form kept server-side. Fields are keyed by
+// wizard form field name.
+type Draft struct {
+	ID        string
+	SessionID string
+	Step      int
+	Fields    map[string]string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
 type ListParams struct {
 	Skip         int
 	Limit        int
@@ -137,4 +240,32 @@ type ListParams struct {
 	Municipality string
 	Status       string
 	Search       string
+	// Office selects the items of one office by its e-mail address.
+	Office string
+	// Stale selects the items whose municipality no longer exists in the
+	// territorial dataset.
+	Stale bool
+	// BBox and Near select the items found within an area.
+	BBox *BBox
+	Near *Near
+	// Located selects the items with a known position.
+	Located bool
+	// DateFrom and DateTo select the items found in a date range
+	// (YYYY-MM-DD, inclusive).
+	DateFrom string
+	DateTo   string
+}
+
+// MapCell is one cell of the map grid: the number of items found in it and
+// their mean position. ID, Name, Category and Date describe the item of a
+// cell holding one.
+type MapCell struct {
+	Point GeoPoint
+	Count int
+	// Stacked is set when all items of the cell are at one position.
+	Stacked  bool
+	ID       string
+	Name     string
+	Category string
+	Date     string
 }
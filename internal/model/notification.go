@@ -0,0 +1,67 @@
+package model
+
+import "time"
+
+// Notification kinds, also the names of the e-mail templates.
+const (
+	NotifyItemRegistered      = "item_registered"
+	NotifyClaimSubmitted      = "claim_submitted"
+	NotifyDeadlineApproaching = "deadline_approaching"
+)
+
+// Outbox message states.
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	OutboxFailed  = "failed"
+)
+
+// OutboxMessage is an e-mail waiting to be delivered or kept as a record
+// of delivery.
+type OutboxMessage struct {
+	ID            int64     `json:"id"`
+	Kind          string    `json:"kind"`
+	DedupeKey     string    `json:"dedupeKey,omitempty"`
+	Recipient     string    `json:"recipient"`
+	ReplyTo       string    `json:"replyTo,omitempty"`
+	Subject       string    `json:"subject"`
+	Body          string    `json:"body"`
+	Status        string    `json:"status"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"nextAttemptAt"`
+	LastError     string    `json:"lastError,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+	SentAt        time.Time `json:"sentAt,omitzero"`
+}
+
+// OfficeNotifications are the e-mails an office has opted in to. Offices
+// without saved settings receive nothing.
+type OfficeNotifications struct {
+	Email               string    `json:"email"`
+	ItemRegistered      bool      `json:"itemRegistered"`
+	ClaimSubmitted      bool      `json:"claimSubmitted"`
+	DeadlineApproaching bool      `json:"deadlineApproaching"`
+	UpdatedAt           time.Time `json:"updatedAt,omitzero"`
+}
+
+// Wants reports whether the office opted in to notifications of kind.
+func (o OfficeNotifications) Wants(kind string) bool {
+	switch kind {
+	case NotifyItemRegistered:
+		return o.ItemRegistered
+	case NotifyClaimSubmitted:
+		return o.ClaimSubmitted
+	case NotifyDeadlineApproaching:
+		return o.DeadlineApproaching
+	}
+	return false
+}
+
+// Claim is an owner's request to collect an item, submitted from the
+// public item page and forwarded to the office.
+type Claim struct {
+	Name    string
+	Email   string
+	Phone   string
+	Message string
+}
@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// Where an office contact comes from.
+const (
+	// ContactDirectory is an entry of the office directory.
+	ContactDirectory = "directory"
+	// ContactDataset is the address listed in the territorial dataset.
+	ContactDataset = "dataset"
+	// ContactGuessed is an address made up from the unit name. It is
+	// never verified and never used for sending.
+	ContactGuessed = "guessed"
+)
+
+// OfficeContact is how to reach the office of a territorial unit.
+type OfficeContact struct {
+	Teryt         string `json:"teryt"`
+	Name          string `json:"name"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"emailVerified"`
+	// EPUAP is the office's ePUAP inbox, e.g. /umwarszawa/SkrytkaESP.
+	EPUAP     string    `json:"epuap,omitempty"`
+	Phone     string    `json:"phone,omitempty"`
+	Address   string    `json:"address,omitempty"`
+	Hours     string    `json:"hours,omitempty"`
+	Source    string    `json:"source"`
+	UpdatedAt time.Time `json:"updatedAt,omitzero"`
+}
+
+type OfficeContactSave struct {
+	Name          string `json:"name" binding:"max=200"`
+	Email         string `json:"email" binding:"omitempty,email,max=254"`
+	EmailVerified bool   `json:"emailVerified"`
+	EPUAP         string `json:"epuap" binding:"max=200"`
+	Phone         string `json:"phone" binding:"max=50"`
+	Address       string `json:"address" binding:"max=300"`
+	Hours         string `json:"hours" binding:"max=300"`
+}
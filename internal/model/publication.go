@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// Resource kinds pushed to dane.gov.pl for every office dataset.
+const (
+	ResourceCSV  = "csv"
+	ResourceJSON = "json"
+)
+
+var ResourceKinds = []string{ResourceCSV, ResourceJSON}
+
+// Publication links an office to its dataset on dane.gov.pl. The office is
+// identified by its e-mail address, like in the DCAT catalog.
+type Publication struct {
+	Office    string              `json:"office"`
+	DatasetID string              `json:"datasetId"`
+	Resources []PublishedResource `json:"resources"`
+	CreatedAt time.Time           `json:"createdAt"`
+	UpdatedAt time.Time           `json:"updatedAt"`
+}
+
+type PublicationSave struct {
+	DatasetID string `json:"datasetId" binding:"required"`
+}
+
+// PublishedResource is the sync state of one resource of a publication.
+// ResourceID is empty until the resource has been created remotely.
+type PublishedResource struct {
+	Kind       string `json:"kind"`
+	ResourceID string `json:"resourceId,omitempty"`
+	// SyncedModified is the newest item change included in the last
+	// successful push.
+	SyncedModified time.Time `json:"syncedModified,omitzero"`
+	SyncedAt       time.Time `json:"syncedAt,omitzero"`
+	Attempts       int       `json:"attempts"`
+	NextAttemptAt  time.Time `json:"nextAttemptAt"`
+	LastError      string    `json:"lastError,omitempty"`
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// MunicipalityRef is the office a found item refers to, as stored on the
+// item. Email is lower-cased.
+type MunicipalityRef struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Email string `json:"email"`
+}
+
+// StaleUnit is a municipality referenced by found items that no longer
+// exists in the territorial dataset.
+type StaleUnit struct {
+	MunicipalityRef
+	Items int `json:"items"`
+	// DatasetVersion is the dataset version the unit was missing from
+	// when it was flagged.
+	DatasetVersion string    `json:"datasetVersion"`
+	FlaggedAt      time.Time `json:"flaggedAt"`
+}
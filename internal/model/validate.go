@@ -0,0 +1,242 @@
+package model
+
+import (
+	"fmt"
+	"net/mail"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
+	"unicode/utf8"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/i18n"
+)
+
+// Limits of found item fields. The wizard and the API enforce the same
+// ones.
+const (
+	MaxNameLength        = 200
+	MaxLocationLength    = 300
+	MaxDescriptionLength = 2000
+	MaxEmailLength       = 254
+	MaxPhoneLength       = 40
+	MinDeadline          = 1
+	MaxDeadline          = 365
+)
+
+// MunicipalityTypes are the kinds of office that register items.
+var MunicipalityTypes = []string{"gmina", "miasto", "powiat", "wojewodztwo"}
+
+// ItemStatuses are the states of an item; new items are "available".
+var ItemStatuses = []string{"available", "claimed", "expired"}
+
+// dateZone is the time zone in which item dates are checked against the
+// current date: item dates are Polish local dates. The zone data is
+// embedded by the time/tzdata import, so loading cannot fail.
+var dateZone = func() *time.Location {
+	loc, err := time.LoadLocation("Europe/Warsaw")
+	if err != nil {
+		panic(fmt.Sprintf("model: %v", err))
+	}
+	return loc
+}()
+
+// Taxonomy reports the category codes that are not in the taxonomy.
+// CategoryRepo implements it.
+type Taxonomy interface {
+	Unknown(codes ...string) ([]string, error)
+}
+
+// FieldError is a rule broken by a field, which is named by its path in
+// API bodies, e.g. "item.date" or "categories[1]".
+type FieldError struct {
+	Field string
+	// Rule is the broken rule: required, max_length, email, date, future,
+	// range, enum or category.
+	Rule string
+	Args []any
+}
+
+// Message describes the broken rule in lang without naming the field,
+// e.g. "is required".
+func (e FieldError) Message(lang string) string {
+	return i18n.T(lang, "validation."+e.Rule, e.Args...)
+}
+
+// ValidationError lists the rules an item breaks.
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + " " + fe.Message(i18n.English)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validate checks a new item against the rules of the register. It returns
+// a ValidationError listing every broken rule, or the error of looking up
+// the categories in tax.
+func (c FoundItemCreate) Validate(tax Taxonomy) error {
+	var v validation
+	v.municipality(c.Municipality)
+	v.item(c.Item)
+	v.pickup(c.Pickup)
+	if c.Finder != nil {
+		v.finder("finder.", *c.Finder)
+	}
+	if err := v.categories(tax, &c.Item, c.Categories); err != nil {
+		return err
+	}
+	return v.err()
+}
+
+// Validate is like FoundItemCreate.Validate for the parts of an item an
+// update replaces.
+func (u FoundItemUpdate) Validate(tax Taxonomy) error {
+	var v validation
+	if u.Municipality != nil {
+		v.municipality(*u.Municipality)
+	}
+	if u.Item != nil {
+		v.item(*u.Item)
+	}
+	if u.Pickup != nil {
+		v.pickup(*u.Pickup)
+	}
+	var categories []string
+	if u.Categories != nil {
+		categories = *u.Categories
+	}
+	if err := v.categories(tax, u.Item, categories); err != nil {
+		return err
+	}
+	return v.err()
+}
+
+// Validate checks finder data sent on its own, as to the finder endpoints.
+func (f Finder) Validate() error {
+	var v validation
+	v.finder("", f)
+	return v.err()
+}
+
+type validation struct {
+	errs ValidationError
+}
+
+func (v *validation) fail(field, rule string, args ...any) {
+	v.errs = append(v.errs, FieldError{Field: field, Rule: rule, Args: args})
+}
+
+func (v *validation) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// text checks the length of a text field and, when required is set, that
+// it is not blank. It reports whether the field has a value to check
+// further.
+func (v *validation) text(field, value string, required bool, max int) bool {
+	if strings.TrimSpace(value) == "" {
+		if required {
+			v.fail(field, "required")
+		}
+		return false
+	}
+	if utf8.RuneCountInString(value) > max {
+		v.fail(field, "max_length", strconv.Itoa(max))
+		return false
+	}
+	return true
+}
+
+func (v *validation) email(field, value string, required bool) {
+	if !v.text(field, value, required, MaxEmailLength) {
+		return
+	}
+	if addr, err := mail.ParseAddress(value); err != nil || addr.Address != value {
+		v.fail(field, "email")
+	}
+}
+
+func (v *validation) oneOf(field, value string, allowed []string) {
+	if !slices.Contains(allowed, value) {
+		v.fail(field, "enum", strings.Join(allowed, ", "))
+	}
+}
+
+func (v *validation) municipality(m MunicipalityInfo) {
+	v.text("municipality.name", m.Name, true, MaxNameLength)
+	if v.text("municipality.type", m.Type, true, MaxNameLength) {
+		v.oneOf("municipality.type", m.Type, MunicipalityTypes)
+	}
+	v.email("municipality.contactEmail", m.ContactEmail, true)
+}
+
+func (v *validation) item(it ItemInfo) {
+	v.text("item.name", it.Name, true, MaxNameLength)
+	v.text("item.category", it.Category, true, MaxNameLength)
+	if v.text("item.date", it.Date, true, MaxNameLength) {
+		if d, err := time.Parse("2006-01-02", it.Date); err != nil {
+			v.fail("item.date", "date")
+		} else if d.Format("2006-01-02") > time.Now().In(dateZone).Format("2006-01-02") {
+			v.fail("item.date", "future")
+		}
+	}
+	v.text("item.location", it.Location, true, MaxLocationLength)
+	if it.Status != "" {
+		v.oneOf("item.status", it.Status, ItemStatuses)
+	}
+	v.text("item.description", it.Description, false, MaxDescriptionLength)
+}
+
+func (v *validation) pickup(p PickupInfo) {
+	if p.Deadline < MinDeadline || p.Deadline > MaxDeadline {
+		v.fail("pickup.deadline", "range", MinDeadline, MaxDeadline)
+	}
+	v.text("pickup.location", p.Location, true, MaxLocationLength)
+	v.text("pickup.hours", p.Hours, false, MaxNameLength)
+	v.text("pickup.contact", p.Contact, false, MaxNameLength)
+}
+
+// finder checks finder data, naming the fields with prefix.
+func (v *validation) finder(prefix string, f Finder) {
+	v.text(prefix+"name", f.Name, false, MaxNameLength)
+	v.text(prefix+"address", f.Address, false, MaxLocationLength)
+	v.text(prefix+"phone", f.Phone, false, MaxPhoneLength)
+	v.email(prefix+"email", f.Email, false)
+}
+
+// categories checks the category of item, when given, and the additional
+// categories against the taxonomy.
+func (v *validation) categories(tax Taxonomy, item *ItemInfo, categories []string) error {
+	var codes []string
+	if item != nil && item.Category != "" {
+		codes = append(codes, item.Category)
+	}
+	for _, code := range categories {
+		if code != "" {
+			codes = append(codes, code)
+		}
+	}
+	var unknown []string
+	if len(codes) > 0 {
+		var err error
+		if unknown, err = tax.Unknown(codes...); err != nil {
+			return fmt.Errorf("check categories: %w", err)
+		}
+	}
+	if item != nil && slices.Contains(unknown, item.Category) {
+		v.fail("item.category", "category")
+	}
+	for i, code := range categories {
+		if code == "" || slices.Contains(unknown, code) {
+			v.fail(fmt.Sprintf("categories[%d]", i), "category")
+		}
+	}
+	return nil
+}
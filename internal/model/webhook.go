@@ -0,0 +1,63 @@
+package model
+
+import "time"
+
+// Webhook delivery states. Dead deliveries ran out of attempts and wait in
+// the dead-letter list until retried by hand.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+type WebhookSubscription struct {
+	ID          string   `json:"id"`
+	URL         string   `json:"url"`
+	Events      []string `json:"events"`
+	Description string   `json:"description,omitempty"`
+	Active      bool     `json:"active"`
+	// Secret signs the deliveries. It is only returned when the
+	// subscription is created.
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type WebhookSubscriptionCreate struct {
+	URL         string   `json:"url" binding:"required"`
+	Events      []string `json:"events" binding:"required"`
+	Description string   `json:"description"`
+	// Secret is generated when empty.
+	Secret string `json:"secret"`
+	Active *bool  `json:"active"`
+}
+
+type WebhookSubscriptionUpdate struct {
+	URL         *string   `json:"url"`
+	Events      *[]string `json:"events"`
+	Description *string   `json:"description"`
+	Secret      *string   `json:"secret"`
+	Active      *bool     `json:"active"`
+}
+
+// WebhookDelivery is one event sent to one subscription.
+type WebhookDelivery struct {
+	ID             int64     `json:"id"`
+	SubscriptionID string    `json:"subscriptionId"`
+	EventID        int64     `json:"eventId"`
+	EventType      string    `json:"eventType"`
+	Status         string    `json:"status"`
+	Attempts       int       `json:"attempts"`
+	NextAttemptAt  time.Time `json:"nextAttemptAt"`
+	LastError      string    `json:"lastError,omitempty"`
+	ResponseStatus int       `json:"responseStatus,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+	DeliveredAt    time.Time `json:"deliveredAt,omitzero"`
+}
+
+// WebhookTestResult reports a synchronous test delivery. ResponseStatus is
+// 0 when no response was received.
+type WebhookTestResult struct {
+	Delivered      bool `json:"delivered"`
+	ResponseStatus int  `json:"responseStatus,omitempty"`
+}
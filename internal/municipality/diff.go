@@ -0,0 +1,71 @@
+package municipality
+
+import "strings"
+
+// Change is a unit that kept its TERYT code but was renamed, changed type
+// or moved to another parent.
+type Change struct {
+	Unit     TerritorialUnit `json:"unit"`
+	Previous TerritorialUnit `json:"previous"`
+}
+
+// Diff lists the differences between two versions of the dataset.
+type Diff struct {
+	From    string            `json:"from"`
+	To      string            `json:"to"`
+	Added   []TerritorialUnit `json:"added"`
+	Removed []TerritorialUnit `json:"removed"`
+	Changed []Change          `json:"changed"`
+}
+
+// Empty reports whether the two versions hold the same units.
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Replace swaps in a new version of the dataset and returns what changed.
+// Office names and e-mail addresses, which TERYT does not carry, are kept
+// from the previous version for units that have none.
+func (s *Service) Replace(units []TerritorialUnit, version string) Diff {
+	old := s.data.Load()
+	for i := range units {
+		units[i].ParentID = ""
+		if j, ok := old.byID[units[i].ID]; ok {
+			prev := old.units[j]
+			if units[i].Email == "" {
+				units[i].Email = prev.Email
+			}
+			if units[i].OfficeName == "" {
+				units[i].OfficeName = prev.OfficeName
+			}
+		}
+	}
+	next := newDataset(units, version)
+	s.data.Store(next)
+	return diff(old, next)
+}
+
+func diff(old, next *dataset) Diff {
+	d := Diff{From: old.version, To: next.version, Added: []TerritorialUnit{}, Removed: []TerritorialUnit{}, Changed: []Change{}}
+	for _, u := range old.units {
+		j, ok := next.byID[u.ID]
+		if !ok {
+			d.Removed = append(d.Removed, u)
+			continue
+		}
+		n := next.units[j]
+		if !sameName(n.Name, u.Name) || n.Type != u.Type || n.ParentID != u.ParentID {
+			d.Changed = append(d.Changed, Change{Unit: n, Previous: u})
+		}
+	}
+	for _, u := range next.units {
+		if _, ok := old.byID[u.ID]; !ok {
+			d.Added = append(d.Added, u)
+		}
+	}
+	return d
+}
+
+func sameName(a, b string) bool {
+	return normalize(strings.TrimSpace(a)) == normalize(strings.TrimSpace(b))
+}
@@ -0,0 +1,73 @@
+package municipality
+
+import (
+	"sort"
+	"strings"
+)
+
+// A TERYT code is WWPPGGR: voivodeship, county, gmina and gmina kind.
+// Voivodeships are WW00000 and counties WWPP000. Cities with county rights
+// have a county code from 61 up but no county unit of their own.
+
+// Get returns the unit with the given TERYT code.
+func (s *Service) Get(id string) (TerritorialUnit, bool) {
+	d := s.data.Load()
+	i, ok := d.byID[FlexString(id)]
+	if !ok {
+		return TerritorialUnit{}, false
+	}
+	return d.units[i], true
+}
+
+// Children returns the units directly below id in TERYT order: the
+// counties and cities with county rights of a voivodeship, the gminas of a
+// county.
+func (s *Service) Children(id string) []TerritorialUnit {
+	d := s.data.Load()
+	out := []TerritorialUnit{}
+	for _, i := range d.children[FlexString(id)] {
+		out = append(out, d.units[i])
+	}
+	return out
+}
+
+// link fills in ParentID and indexes the units by code and parent.
+func link(units []TerritorialUnit) (map[FlexString]int, map[FlexString][]int) {
+	byID := make(map[FlexString]int, len(units))
+	for i, u := range units {
+		byID[u.ID] = i
+	}
+
+	children := map[FlexString][]int{}
+	for i := range units {
+		id := string(units[i].ID)
+		if len(id) != 7 || units[i].Type == "wojewodztwo" {
+			continue
+		}
+		candidates := []string{id[:2] + "00000"}
+		if units[i].Type != "powiat" {
+			candidates = append([]string{id[:4] + "000"}, candidates...)
+		}
+		for _, p := range candidates {
+			if _, ok := byID[FlexString(p)]; ok {
+				units[i].ParentID = FlexString(p)
+				children[FlexString(p)] = append(children[FlexString(p)], i)
+				break
+			}
+		}
+	}
+	for _, c := range children {
+		sort.Slice(c, func(a, b int) bool { return units[c[a]].ID < units[c[b]].ID })
+	}
+	return byID, children
+}
+
+// inVoivodeship matches a unit against a voivodeship name or two-digit
+// TERYT code.
+func inVoivodeship(u *TerritorialUnit, v string) bool {
+	v = strings.TrimSpace(v)
+	if len(v) == 2 && v[0] >= '0' && v[0] <= '9' {
+		return strings.HasPrefix(string(u.ID), v)
+	}
+	return normalize(u.Voivodeship) == normalize(strings.TrimPrefix(strings.ToLower(v), "województwo "))
+}
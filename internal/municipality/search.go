@@ -0,0 +1,284 @@
+package municipality
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Match quality, best first. A query matches when every query word matches
+// a word of the unit name; the score is decided by the weakest word.
+const (
+	scoreExact      = iota // the whole name
+	scoreNamePrefix        // the name starts with the query
+	scoreWords             // every query word is a word of the name
+	scoreWordPrefix        // ... or the start of one
+	scoreInfix             // ... or occurs inside one
+	scoreFuzzy             // ... or is within the edit budget; edits are added
+)
+
+// typeRank breaks ties between equally good matches: a city is more often
+// meant than the rural gmina of the same name, and both more often than a
+// county or voivodeship.
+var typeRank = map[string]int{"miasto": 0, "gmina": 1, "powiat": 2, "wojewodztwo": 3}
+
+type entry struct {
+	unit  TerritorialUnit
+	name  string
+	words []word
+}
+
+// word is a word of a name or query, kept in both forms to avoid
+// converting during matching.
+type word struct {
+	s     string
+	runes []rune
+}
+
+// index finds units by name. Candidates are the units sharing enough
+// trigrams with the longest query word to be within its edit budget; they
+// are then scored exactly.
+type index struct {
+	entries []entry
+	grams   map[string][]int32
+}
+
+func newIndex(units []TerritorialUnit) *index {
+	idx := &index{entries: make([]entry, len(units)), grams: map[string][]int32{}}
+	for i, u := range units {
+		name := normalize(u.Name)
+		idx.entries[i] = entry{unit: u, name: name, words: words(name)}
+		seen := map[string]bool{}
+		for _, w := range idx.entries[i].words {
+			for _, g := range trigrams(w.runes) {
+				if !seen[g] {
+					seen[g] = true
+					idx.grams[g] = append(idx.grams[g], int32(i))
+				}
+			}
+		}
+	}
+	return idx
+}
+
+type scored struct {
+	entry *entry
+	score int
+}
+
+// search ranks the units matching query for which keep (when not nil)
+// reports true.
+func (idx *index) search(query string, keep func(*TerritorialUnit) bool, limit int) []TerritorialUnit {
+	q := normalize(strings.TrimSpace(query))
+	qwords := words(q)
+	if len([]rune(q)) < 2 || len(qwords) == 0 {
+		return nil
+	}
+
+	var results []scored
+	m := newMatcher(q, qwords)
+	consider := func(e *entry) {
+		if keep != nil && !keep(&e.unit) {
+			return
+		}
+		if score, ok := m.score(e); ok {
+			results = append(results, scored{entry: e, score: score})
+		}
+	}
+
+	longest := qwords[0]
+	for _, w := range qwords[1:] {
+		if len(w.runes) > len(longest.runes) {
+			longest = w
+		}
+	}
+	grams := trigrams(longest.runes)
+	if len(longest.runes) < 3 {
+		// Too short for trigrams to say anything; there are no fuzzy
+		// matches at this length, so a scan is cheap.
+		for i := range idx.entries {
+			consider(&idx.entries[i])
+		}
+	} else {
+		// Each edit spoils up to three trigrams, and an infix match
+		// misses the word-start one.
+		need := max(1, len(grams)-1-3*maxEdits(longest.runes))
+		counts := make([]uint8, len(idx.entries))
+		for _, g := range grams {
+			for _, i := range idx.grams[g] {
+				counts[i]++
+				if int(counts[i]) == need {
+					consider(&idx.entries[i])
+				}
+			}
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.score != b.score {
+			return a.score < b.score
+		}
+		if ra, rb := typeRank[a.entry.unit.Type], typeRank[b.entry.unit.Type]; ra != rb {
+			return ra < rb
+		}
+		if la, lb := len(a.entry.name), len(b.entry.name); la != lb {
+			return la < lb
+		}
+		if a.entry.name != b.entry.name {
+			return a.entry.name < b.entry.name
+		}
+		return a.entry.unit.ID < b.entry.unit.ID
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	out := make([]TerritorialUnit, len(results))
+	for i, r := range results {
+		out[i] = r.entry.unit
+	}
+	return out
+}
+
+// matcher scores names against one query, reusing its distance buffers.
+type matcher struct {
+	q      string
+	words  []word
+	budget []int
+	rows   [3][]int
+}
+
+func newMatcher(q string, qwords []word) *matcher {
+	m := &matcher{q: q, words: qwords, budget: make([]int, len(qwords))}
+	for i, w := range qwords {
+		m.budget[i] = maxEdits(w.runes)
+	}
+	return m
+}
+
+// score rates the unit name against the query.
+func (m *matcher) score(e *entry) (int, bool) {
+	if e.name == m.q {
+		return scoreExact, true
+	}
+	if strings.HasPrefix(e.name, m.q) {
+		return scoreNamePrefix, true
+	}
+	worst, edits := scoreWords, 0
+	for i, qw := range m.words {
+		best, bestEdits := -1, 0
+		for _, w := range e.words {
+			s, d, ok := m.matchWord(w, qw, m.budget[i])
+			if ok && (best < 0 || s < best || s == best && d < bestEdits) {
+				best, bestEdits = s, d
+			}
+		}
+		if best < 0 {
+			return 0, false
+		}
+		worst = max(worst, best)
+		edits += bestEdits
+	}
+	if worst == scoreFuzzy {
+		return scoreFuzzy + edits, true
+	}
+	return worst, true
+}
+
+func (m *matcher) matchWord(w, qw word, k int) (score, edits int, ok bool) {
+	switch {
+	case w.s == qw.s:
+		return scoreWords, 0, true
+	case strings.HasPrefix(w.s, qw.s):
+		return scoreWordPrefix, 0, true
+	case len(qw.runes) >= 3 && strings.Contains(w.s, qw.s):
+		return scoreInfix, 0, true
+	case k == 0:
+		return 0, 0, false
+	}
+	if d := m.prefixDistance(qw.runes, w.runes, k); d <= k {
+		return scoreFuzzy, d, true
+	}
+	return 0, 0, false
+}
+
+// maxEdits is the typo budget of a query word: none for very short words,
+// one from four letters and two from eight.
+func maxEdits(w []rune) int {
+	switch n := len(w); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// prefixDistance is the smallest optimal-string-alignment distance (edits
+// and adjacent transpositions) between q and any prefix of w, so a query
+// still being typed is not penalised for the missing rest. It returns k+1
+// once the distance is known to exceed k.
+func (m *matcher) prefixDistance(a, b []rune, k int) int {
+	for i := range m.rows {
+		if cap(m.rows[i]) < len(b)+1 {
+			m.rows[i] = make([]int, len(b)+1)
+		}
+		m.rows[i] = m.rows[i][:len(b)+1]
+	}
+	prev2, prev, cur := m.rows[0], m.rows[1], m.rows[2]
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > k {
+			return k + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	best := prev[0]
+	for _, d := range prev {
+		best = min(best, d)
+	}
+	return best
+}
+
+// words splits a normalized name at spaces, hyphens and punctuation.
+func words(s string) []word {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	out := make([]word, len(fields))
+	for i, f := range fields {
+		out[i] = word{s: f, runes: []rune(f)}
+	}
+	return out
+}
+
+// trigrams returns the trigrams of w with a start marker, so "krak" gives
+// "$kr", "kra" and "rak".
+func trigrams(w []rune) []string {
+	r := append([]rune{'$'}, w...)
+	if len(r) < 3 {
+		return nil
+	}
+	out := make([]string, 0, len(r)-2)
+	for i := 0; i+3 <= len(r); i++ {
+		out = append(out, string(r[i:i+3]))
+	}
+	return out
+}
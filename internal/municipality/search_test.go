@@ -0,0 +1,117 @@
+package municipality
+
+import "testing"
+
+func newTestService(tb testing.TB) *Service {
+	tb.Helper()
+	s, err := NewService(0)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return s
+}
+
+func TestSearchRanking(t *testing.T) {
+	s := newTestService(t)
+	tests := []struct {
+		query, unitType string
+		want            string
+	}{
+		{"krakow", "", "Kraków"},
+		{"KRAKÓW", "", "Kraków"},
+		{"krakw", "", "Kraków"},
+		{"zielona gora", "", "Zielona Góra"},
+		{"zielona gora", "miasto", "Zielona Góra"},
+		{"bielsko", "", "Bielsko-Biała"},
+		{"biala", "", "Biała"},
+		{"krakowski", "powiat", "Powiat Krakowski"},
+	}
+	for _, tt := range tests {
+		got := s.Search(tt.query, tt.unitType)
+		if len(got) == 0 || got[0].Name != tt.want {
+			t.Errorf("Search(%q, %q) = %v, want %s first", tt.query, tt.unitType, names(got), tt.want)
+		}
+	}
+}
+
+func TestSearchPrefersCities(t *testing.T) {
+	s := newTestService(t)
+	// Rural gminas share their name with the city they surround; the city
+	// comes first.
+	for _, name := range []string{"Tarnów", "Chełm", "Przemyśl"} {
+		got := s.Search(name, "")
+		if len(got) < 2 || got[0].Type != "miasto" || got[1].Name != name {
+			t.Errorf("Search(%s) = %v, want the city before the gmina", name, names(got))
+		}
+	}
+}
+
+func TestSearchLimit(t *testing.T) {
+	s := newTestService(t)
+	if got := s.Search("wa", ""); len(got) != DefaultLimit {
+		t.Errorf("Search(wa) returned %d units, want %d", len(got), DefaultLimit)
+	}
+	if got := s.Find(Query{Text: "wa", Limit: 3}); len(got) != 3 {
+		t.Errorf("Find(wa, limit 3) returned %d units", len(got))
+	}
+	if got := s.Search("xqzv", ""); len(got) != 0 {
+		t.Errorf("Search(xqzv) = %v, want nothing", names(got))
+	}
+}
+
+func names(units []TerritorialUnit) []string {
+	out := make([]string, len(units))
+	for i, u := range units {
+		out[i] = u.Name + " (" + u.Type + ")"
+	}
+	return out
+}
+
+// The search benchmarks run over the full embedded TERYT dataset; a query
+// should stay well under a millisecond.
+var benchQueries = []struct {
+	name, query, unitType string
+}{
+	{"Exact", "Kraków", ""},
+	{"Prefix", "krak", ""},
+	{"ShortPrefix", "wa", ""},
+	{"Words", "zielona gora", ""},
+	{"Infix", "ielsk", ""},
+	{"Typo", "krakwo", ""},
+	{"LongTypo", "szczebrzeszin", ""},
+	{"NoMatch", "xqzvxqzv", ""},
+	{"Type", "krak", "powiat"},
+}
+
+func BenchmarkSearch(b *testing.B) {
+	s := newTestService(b)
+	for _, q := range benchQueries {
+		b.Run(q.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				s.Search(q.query, q.unitType)
+			}
+		})
+	}
+}
+
+func BenchmarkSearchParallel(b *testing.B) {
+	s := newTestService(b)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			q := benchQueries[i%len(benchQueries)]
+			s.Search(q.query, q.unitType)
+			i++
+		}
+	})
+}
+
+func BenchmarkNewIndex(b *testing.B) {
+	units := newTestService(b).data.Load().units
+	b.ReportAllocs()
+	for b.Loop() {
+		newIndex(units)
+	}
+}
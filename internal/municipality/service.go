@@ -1,11 +1,14 @@
 package municipality
 
 import (
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"unicode"
 
 	"golang.org/x/text/unicode/norm"
@@ -22,7 +25,7 @@ func (f *FlexString) UnmarshalJSON(data []byte) error {
 		if err := json.Unmarshal(data, &s); err != nil {
 			return err
 		}
-		*f = FlexString(s)
+		*f = FlexString(strings.TrimSpace(s))
 		return nil
 	}
 	var n json.Number
@@ -41,19 +44,41 @@ type TerritorialUnit struct {
 	OfficeName  string     `json:"officeName,omitempty"`
 	Voivodeship string     `json:"voivodeship,omitempty"`
 	County      string     `json:"county,omitempty"`
+	// ParentID is the unit one level up: the county of a gmina, the
+	// voivodeship of a county or of a city with county rights.
+	ParentID FlexString `json:"parentId,omitempty"`
 }
 
-type searchEntry struct {
-	unit       TerritorialUnit
-	normalized string
-}
+// DefaultLimit is the number of search results when no limit is
+// configured.
+const DefaultLimit = 20
 
+// Service answers queries about the territorial dataset. The dataset can
+// be replaced while the service is in use; every call sees one version.
 type Service struct {
-	units   []TerritorialUnit
-	indexed []searchEntry
+	data  atomic.Pointer[dataset]
+	limit int
+}
+
+type dataset struct {
+	units    []TerritorialUnit
+	byID     map[FlexString]int
+	children map[FlexString][]int
+	index    *index
+	version  string
+}
+
+func newDataset(units []TerritorialUnit, version string) *dataset {
+	sort.Slice(units, func(i, j int) bool { return units[i].ID < units[j].ID })
+	d := &dataset{units: units, version: version}
+	d.byID, d.children = link(units)
+	d.index = newIndex(units)
+	return d
 }
 
-func NewService() (*Service, error) {
+// NewService loads the embedded territorial dataset. limit caps the
+// number of Search results; DefaultLimit is used when it is not positive.
+func NewService(limit int) (*Service, error) {
 	data, err := dataFS.ReadFile("territorial-units.json")
 	if err != nil {
 		return nil, err
@@ -64,58 +89,105 @@ func NewService() (*Service, error) {
 		return nil, err
 	}
 
-	indexed := make([]searchEntry, len(units))
-	for i, u := range units {
-		indexed[i] = searchEntry{
-			unit:       u,
-			normalized: normalize(u.Name),
-		}
+	if limit <= 0 {
+		limit = DefaultLimit
 	}
+	s := &Service{limit: limit}
+	s.data.Store(newDataset(units, Version(data)))
+	return s, nil
+}
 
-	return &Service{units: units, indexed: indexed}, nil
+// Version returns the version identifier of a dataset read from data.
+func Version(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
 }
 
+// Version identifies the loaded dataset; it changes whenever the data
+// does.
+func (s *Service) Version() string {
+	return s.data.Load().version
+}
+
+// Len is the number of loaded units.
+func (s *Service) Len() int {
+	return len(s.data.Load().units)
+}
+
+// Search returns the units whose name matches query, best match first.
+// Whole-name and word-start matches rank above matches inside a word, and
+// those above matches that need typo corrections; equally good matches
+// list cities before gminas. unitType restricts the result to one type.
 func (s *Service) Search(query, unitType string) []TerritorialUnit {
-	if len(query) < 2 {
-		return nil
-	}
+	return s.Find(Query{Text: query, Type: unitType})
+}
 
-	q := normalize(query)
+// Query selects territorial units. All fields are optional.
+type Query struct {
+	// Text is matched against unit names like in Search.
+	Text string
+	Type string
+	// Voivodeship is a voivodeship name ("małopolskie") or its two-digit
+	// TERYT code ("12").
+	Voivodeship string
+	// Limit caps the result; the configured search limit is used when it
+	// is zero and Text is set. Without Text everything is returned.
+	Limit int
+}
 
-	type scored struct {
-		unit  TerritorialUnit
-		score int
+// Find returns the units selected by q: ranked like Search when q.Text is
+// set, in TERYT order otherwise.
+func (s *Service) Find(q Query) []TerritorialUnit {
+	d := s.data.Load()
+	keep := func(u *TerritorialUnit) bool {
+		return (q.Type == "" || u.Type == q.Type) && (q.Voivodeship == "" || inVoivodeship(u, q.Voivodeship))
 	}
-
-	var results []scored
-	for _, e := range s.indexed {
-		if unitType != "" && e.unit.Type != unitType {
-			continue
+	if strings.TrimSpace(q.Text) != "" {
+		limit := q.Limit
+		if limit <= 0 {
+			limit = s.limit
 		}
-		idx := strings.Index(e.normalized, q)
-		if idx < 0 {
-			continue
+		return d.index.search(q.Text, keep, limit)
+	}
+
+	out := []TerritorialUnit{}
+	for i := range d.units {
+		if keep(&d.units[i]) {
+			out = append(out, d.units[i])
+			if q.Limit > 0 && len(out) == q.Limit {
+				break
+			}
 		}
-		// Score: exact prefix match = 0, contains = position
-		results = append(results, scored{unit: e.unit, score: idx})
 	}
+	return out
+}
 
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].score < results[j].score
-	})
+// Match returns the units named exactly name (ignoring case and Polish
+// diacritics). When several offices share the name and email is given, only
+// the unit with that office email is returned.
+func (s *Service) Match(name, unitType, email string) []TerritorialUnit {
+	q := normalize(strings.TrimSpace(name))
 
-	limit := 20
-	if len(results) < limit {
-		limit = len(results)
+	var exact []TerritorialUnit
+	for _, u := range s.Search(name, unitType) {
+		if normalize(u.Name) == q {
+			exact = append(exact, u)
+		}
 	}
 
-	out := make([]TerritorialUnit, limit)
-	for i := 0; i < limit; i++ {
-		out[i] = results[i].unit
+	if len(exact) > 1 && email != "" {
+		for _, u := range exact {
+			if strings.EqualFold(u.Email, email) {
+				return []TerritorialUnit{u}
+			}
+		}
 	}
-	return out
+	return exact
 }
 
+// GenerateEmail returns the unit's address, or for units without one an
+// address made up from the name. Made-up addresses often do not exist and
+// must not be used for sending; see IsGuessedEmail.
 func (s *Service) GenerateEmail(unit TerritorialUnit) string {
 	if unit.Email != "" {
 		return unit.Email
@@ -140,6 +212,18 @@ func (s *Service) GenerateEmail(unit TerritorialUnit) string {
 	}
 }
 
+// IsGuessedEmail reports whether email is what GenerateEmail makes up for
+// a unit without an address.
+func (s *Service) IsGuessedEmail(email string) bool {
+	email = strings.ToLower(strings.TrimSpace(email))
+	for _, u := range s.data.Load().units {
+		if strings.TrimSpace(u.Email) == "" && s.GenerateEmail(u) == email {
+			return true
+		}
+	}
+	return false
+}
+
 var polishReplacements = map[rune]rune{
 	'ą': 'a', 'ć': 'c', 'ę': 'e', 'ł': 'l', 'ń': 'n',
 	'ó': 'o', 'ś': 's', 'ź': 'z', 'ż': 'z',
@@ -147,6 +231,12 @@ var polishReplacements = map[rune]rune{
 	'Ó': 'o', 'Ś': 's', 'Ź': 'z', 'Ż': 'z',
 }
 
+// Normalize folds case and Polish diacritics, the form in which unit
+// names are compared.
+func Normalize(s string) string {
+	return normalize(strings.TrimSpace(s))
+}
+
 func normalize(s string) string {
 	s = norm.NFC.String(s)
 	var b strings.Builder
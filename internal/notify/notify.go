@@ -0,0 +1,259 @@
+// Package notify sends e-mail notifications to offices. Messages are
+// rendered when the event happens, stored in the outbox table and delivered
+// by a background worker that retries failures with exponential backoff.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"log"
+	"text/template"
+	"time"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/directory"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+	"github.com/kacperfilipiuk/zguba-gov/internal/repository"
+)
+
+//go:embed templates/*.txt
+var templateFS embed.FS
+
+const (
+	// MaxAttempts is how often delivery is tried before a message is
+	// marked failed.
+	MaxAttempts = 8
+	batchSize   = 20
+	baseBackoff = time.Minute
+	maxBackoff  = 6 * time.Hour
+)
+
+type Service struct {
+	transport  Transport
+	from       string
+	baseURL    string
+	outbox     *repository.OutboxRepo
+	settings   *repository.OfficeNotificationsRepo
+	directory  *directory.Directory
+	items      *repository.FoundItemRepo
+	categories *repository.CategoryRepo
+	tmpl       *template.Template
+}
+
+// New returns the notification service. With a nil transport the service
+// is disabled and every notification is silently dropped. Mail goes only to
+// addresses the directory considers sendable.
+func New(transport Transport, from, baseURL string, outbox *repository.OutboxRepo, settings *repository.OfficeNotificationsRepo,
+	dir *directory.Directory, items *repository.FoundItemRepo, categories *repository.CategoryRepo) (*Service, error) {
+	tmpl, err := template.ParseFS(templateFS, "templates/*.txt")
+	if err != nil {
+		return nil, fmt.Errorf("parse mail templates: %w", err)
+	}
+	return &Service{
+		transport:  transport,
+		from:       from,
+		baseURL:    baseURL,
+		outbox:     outbox,
+		settings:   settings,
+		directory:  dir,
+		items:      items,
+		categories: categories,
+		tmpl:       tmpl,
+	}, nil
+}
+
+func (s *Service) Enabled() bool {
+	return s.transport != nil
+}
+
+type mailData struct {
+	Item       model.FoundItemResponse
+	Category   string
+	ItemURL    string
+	ReceiptURL string
+	Deadline   string
+	Claim      model.Claim
+}
+
+// ItemRegistered notifies the office that an item was added to its register.
+func (s *Service) ItemRegistered(item model.FoundItemResponse) error {
+	_, err := s.enqueue(model.NotifyItemRegistered, "item_registered:"+item.ID, "", s.data(item))
+	return err
+}
+
+// AcceptsClaims reports whether claims for items of the office can be
+// forwarded by e-mail.
+func (s *Service) AcceptsClaims(officeEmail string) bool {
+	if !s.Enabled() || officeEmail == "" {
+		return false
+	}
+	if ok, err := s.directory.Sendable(officeEmail); err != nil || !ok {
+		return false
+	}
+	settings, err := s.settings.Get(officeEmail)
+	return err == nil && settings.ClaimSubmitted
+}
+
+// ClaimSubmitted forwards an owner's claim to the office. Replies go to
+// the claimant.
+func (s *Service) ClaimSubmitted(item model.FoundItemResponse, claim model.Claim) error {
+	data := s.data(item)
+	data.Claim = claim
+	ok, err := s.enqueue(model.NotifyClaimSubmitted, "", claim.Email, data)
+	if err == nil && !ok {
+		err = fmt.Errorf("office %s does not accept claims by e-mail", item.Municipality.ContactEmail)
+	}
+	return err
+}
+
+// QueueDeadlines notifies offices about available items whose storage
+// deadline falls within notice from now. Each item is announced once per
+// deadline.
+func (s *Service) QueueDeadlines(now time.Time, notice time.Duration) (int, error) {
+	if !s.Enabled() {
+		return 0, nil
+	}
+	n := 0
+	err := s.items.Each(model.ListParams{Status: "available"}, func(it model.FoundItem) error {
+		deadline := storageDeadline(it.CreatedAt, it.PickupDeadline)
+		if deadline.Before(now) || deadline.After(now.Add(notice)) {
+			return nil
+		}
+		resp := it.ToResponse()
+		key := fmt.Sprintf("deadline_approaching:%s:%s", it.ID, deadline.Format("2006-01-02"))
+		queued, err := s.enqueue(model.NotifyDeadlineApproaching, key, "", s.data(resp))
+		if queued {
+			n++
+		}
+		return err
+	})
+	return n, err
+}
+
+// Deliver sends the messages that are due and returns how many were sent.
+func (s *Service) Deliver(ctx context.Context, now time.Time) (int, error) {
+	if !s.Enabled() {
+		return 0, nil
+	}
+	msgs, err := s.outbox.Due(now, batchSize)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, m := range msgs {
+		err := s.transport.Send(ctx, Message{From: s.from, To: m.Recipient, ReplyTo: m.ReplyTo, Subject: m.Subject, Body: m.Body})
+		if err == nil {
+			if err := s.outbox.MarkSent(m.ID); err != nil {
+				return sent, err
+			}
+			sent++
+			continue
+		}
+		attempts := m.Attempts + 1
+		final := attempts >= MaxAttempts || errors.Is(err, ErrHeaderInjection)
+		log.Printf("notify: message %d to %s failed (attempt %d/%d): %v", m.ID, m.Recipient, attempts, MaxAttempts, err)
+		if err := s.outbox.MarkFailed(m.ID, err, now.Add(Backoff(attempts)), final); err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+// Run delivers the outbox every interval and looks for approaching
+// deadlines every hour until ctx is cancelled.
+func (s *Service) Run(ctx context.Context, interval, notice time.Duration) {
+	if !s.Enabled() {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var lastScan time.Time
+	for {
+		now := time.Now()
+		if now.Sub(lastScan) >= time.Hour {
+			if n, err := s.QueueDeadlines(now, notice); err != nil {
+				log.Printf("notify: deadline scan: %v", err)
+			} else if n > 0 {
+				log.Printf("notify: queued %d deadline reminders", n)
+			}
+			if _, err := s.outbox.PurgeSent(now.AddDate(0, 0, -90)); err != nil {
+				log.Printf("notify: purge outbox: %v", err)
+			}
+			lastScan = now
+		}
+		if _, err := s.Deliver(ctx, now); err != nil {
+			log.Printf("notify: deliver: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Backoff is the delay before the next attempt after the given number of
+// failed attempts: one minute, doubling up to six hours.
+func Backoff(attempts int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
+
+// enqueue renders and stores a message for the office of the item if the
+// office opted in to kind. It reports whether a message was queued.
+func (s *Service) enqueue(kind, dedupeKey, replyTo string, data mailData) (bool, error) {
+	if !s.Enabled() {
+		return false, nil
+	}
+	to := data.Item.Municipality.ContactEmail
+	if to == "" {
+		return false, nil
+	}
+	// Guessed and unverified office addresses never receive mail.
+	if ok, err := s.directory.Sendable(to); err != nil || !ok {
+		return false, err
+	}
+	settings, err := s.settings.Get(to)
+	if err != nil {
+		return false, err
+	}
+	if !settings.Wants(kind) {
+		return false, nil
+	}
+
+	var subject, body bytes.Buffer
+	if err := s.tmpl.ExecuteTemplate(&subject, kind+".subject", data); err != nil {
+		return false, err
+	}
+	if err := s.tmpl.ExecuteTemplate(&body, kind+".body", data); err != nil {
+		return false, err
+	}
+	return s.outbox.Enqueue(&model.OutboxMessage{
+		Kind:      kind,
+		DedupeKey: dedupeKey,
+		Recipient: to,
+		ReplyTo:   replyTo,
+		Subject:   subject.String(),
+		Body:      body.String(),
+	})
+}
+
+func (s *Service) data(item model.FoundItemResponse) mailData {
+	created, _ := time.Parse(time.RFC3339, item.CreatedAt)
+	return mailData{
+		Item:       item,
+		Category:   s.categories.Path(item.Item.Category),
+		ItemURL:    s.baseURL + "/rzeczy/" + item.ID,
+		ReceiptURL: s.baseURL + "/api/found-items/" + item.ID + "/receipt.pdf",
+		Deadline:   storageDeadline(created, item.Pickup.Deadline).Local().Format("2006-01-02"),
+	}
+}
+
+func storageDeadline(created time.Time, days int) time.Time {
+	return created.AddDate(0, 0, days)
+}
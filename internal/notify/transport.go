@@ -0,0 +1,160 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a plain-text e-mail.
+type Message struct {
+	From    string
+	To      string
+	ReplyTo string
+	Subject string
+	Body    string
+}
+
+// Transport delivers a message. Implementations must be safe for
+// concurrent use.
+type Transport interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// SMTPTransport sends through an SMTP relay, upgrading to TLS with
+// STARTTLS when the server offers it.
+type SMTPTransport struct {
+	Addr     string
+	Username string
+	Password string
+}
+
+func (t *SMTPTransport) Send(ctx context.Context, m Message) error {
+	host, _, err := net.SplitHostPort(t.Addr)
+	if err != nil {
+		return fmt.Errorf("smtp address: %w", err)
+	}
+	var auth smtp.Auth
+	if t.Username != "" {
+		auth = smtp.PlainAuth("", t.Username, t.Password, host)
+	}
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("from address: %w", err)
+	}
+
+	msg, err := compose(m)
+	if err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- smtp.SendMail(t.Addr, auth, from.Address, []string{m.To}, msg) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// FileTransport writes every message as an .eml file into Dir, for
+// development and tests.
+type FileTransport struct {
+	Dir string
+}
+
+func (t *FileTransport) Send(_ context.Context, m Message) error {
+	if err := os.MkdirAll(t.Dir, 0o750); err != nil {
+		return err
+	}
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000"), hex.EncodeToString(suffix))
+	msg, err := compose(m)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(t.Dir, name), msg, 0o640)
+}
+
+// WriterTransport prints messages to W, typically stdout, with the body
+// left readable instead of MIME-encoded.
+type WriterTransport struct {
+	W  io.Writer
+	mu sync.Mutex
+}
+
+func (t *WriterTransport) Send(_ context.Context, m Message) error {
+	if _, err := compose(m); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var reply string
+	if m.ReplyTo != "" {
+		reply = "Reply-To: " + m.ReplyTo + "\n"
+	}
+	_, err := fmt.Fprintf(t.W, "From: %s\nTo: %s\n%sSubject: %s\n\n%s\n----\n",
+		m.From, m.To, reply, m.Subject, m.Body)
+	return err
+}
+
+// ErrHeaderInjection is returned for a message with a line break in a
+// header value, which would let the value add headers or recipients.
+var ErrHeaderInjection = errors.New("line break in mail header")
+
+// compose renders m as an RFC 5322 message with a UTF-8 text body.
+func compose(m Message) ([]byte, error) {
+	var b bytes.Buffer
+	var err error
+	header := func(k, v string) {
+		if strings.ContainsAny(v, "\r\n") {
+			err = fmt.Errorf("%s: %w", k, ErrHeaderInjection)
+		}
+		if v != "" && err == nil {
+			fmt.Fprintf(&b, "%s: %s\r\n", k, v)
+		}
+	}
+	header("From", encodeAddress(m.From))
+	header("To", m.To)
+	header("Reply-To", m.ReplyTo)
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", `text/plain; charset="utf-8"`)
+	header("Content-Transfer-Encoding", "base64")
+	if err != nil {
+		return nil, err
+	}
+	b.WriteString("\r\n")
+
+	body := base64.StdEncoding.EncodeToString([]byte(strings.ReplaceAll(m.Body, "\n", "\r\n")))
+	for len(body) > 76 {
+		b.WriteString(body[:76] + "\r\n")
+		body = body[76:]
+	}
+	b.WriteString(body + "\r\n")
+	return b.Bytes(), nil
+}
+
+func encodeAddress(s string) string {
+	if a, err := mail.ParseAddress(s); err == nil {
+		return a.String()
+	}
+	return s
+}
@@ -3,17 +3,32 @@ package odata
 import (
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/geo"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
 )
 
 var allowedFilterFields = map[string]string{
-	"item_status":       "item_status",
-	"item_category":     "item_category",
-	"municipality_name": "municipality_name",
-	"item_name":         "item_name",
-	"item_description":  "item_description",
+	"item_status":        "item_status",
+	"item_category":      "item_category",
+	"municipality_name":  "municipality_name",
+	"municipality_type":  "municipality_type",
+	"municipality_email": "municipality_email",
+	"item_name":          "item_name",
+	"item_description":   "item_description",
+}
+
+// geoFields maps the Edm.GeographyPoint properties to their latitude and
+// longitude columns.
+var geoFields = map[string][2]string{
+	"item_geo":   {"item_lat", "item_lon"},
+	"pickup_geo": {"pickup_lat", "pickup_lon"},
 }
 
+var distanceOps = map[string]string{"lt": "<", "le": "<=", "gt": ">", "ge": ">="}
+
 var allowedOrderFields = map[string]string{
 	"created_at": "created_at",
 	"item_name":  "item_name",
@@ -33,6 +48,9 @@ func ParseFilter(filter string) (*FilterClause, error) {
 	eqRe := regexp.MustCompile(`(\w+)\s+eq\s+'([^']*)'`)
 	containsRe := regexp.MustCompile(`contains\((\w+),\s*'([^']*)'\)`)
 	startsWithRe := regexp.MustCompile(`startswith\((\w+),\s*'([^']*)'\)`)
+	// geo.distance(item_geo, geography'SRID=4326;POINT(21.01 52.23)') lt 1000,
+	// with the distance in metres.
+	distanceRe := regexp.MustCompile(`geo\.distance\((\w+),\s*geography'(?:SRID=4326;)?POINT\(\s*(-?[\d.]+)\s+(-?[\d.]+)\s*\)'\)\s+(lt|le|gt|ge)\s+([\d.]+)`)
 
 	var clauses []string
 	var args []any
@@ -64,6 +82,22 @@ func ParseFilter(filter string) (*FilterClause, error) {
 		args = append(args, match[2]+"%")
 	}
 
+	for _, match := range distanceRe.FindAllStringSubmatch(filter, -1) {
+		cols, ok := geoFields[match[1]]
+		if !ok {
+			return nil, fmt.Errorf("unsupported geo.distance field: %s", match[1])
+		}
+		lon, errLon := strconv.ParseFloat(match[2], 64)
+		lat, errLat := strconv.ParseFloat(match[3], 64)
+		dist, errDist := strconv.ParseFloat(match[5], 64)
+		if errLon != nil || errLat != nil || errDist != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+			return nil, fmt.Errorf("invalid geo.distance: %s", match[0])
+		}
+		expr, exprArgs := geo.SquaredDistanceSQL(cols[0], cols[1], model.GeoPoint{Lat: lat, Lon: lon})
+		clauses = append(clauses, fmt.Sprintf("%s IS NOT NULL AND %s %s ?", cols[0], expr, distanceOps[match[4]]))
+		args = append(args, append(exprArgs, dist*dist)...)
+	}
+
 	return &FilterClause{
 		Where: strings.Join(clauses, " AND "),
 		Args:  args,
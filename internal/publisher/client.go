@@ -0,0 +1,124 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	mediaJSONAPI = "application/vnd.api+json"
+	timeout      = 30 * time.Second
+	userAgent    = "zguba-gov-publisher/1.0"
+)
+
+// ErrNotFound is returned when the remote dataset or resource does not
+// exist.
+var ErrNotFound = errors.New("not found on dane.gov.pl")
+
+// Resource is what is sent to dane.gov.pl about one resource. dane.gov.pl
+// fetches the data itself from Link.
+type Resource struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Link        string `json:"link"`
+	Format      string `json:"format"`
+	// DataDate is the day the data was last changed (YYYY-MM-DD).
+	DataDate string `json:"data_date"`
+}
+
+// Client talks to the resource endpoints of the dane.gov.pl provider API,
+// which follows JSON:API.
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func NewClient(baseURL, token string) *Client {
+	return &Client{baseURL: strings.TrimSuffix(baseURL, "/"), token: token, http: &http.Client{Timeout: timeout}}
+}
+
+type resourceDocument struct {
+	Data resourceData `json:"data"`
+}
+
+type resourceData struct {
+	ID         string   `json:"id,omitempty"`
+	Type       string   `json:"type"`
+	Attributes Resource `json:"attributes"`
+}
+
+// CreateResource adds a resource to a dataset and returns its ID.
+func (c *Client) CreateResource(ctx context.Context, datasetID string, r Resource) (string, error) {
+	doc, err := c.do(ctx, http.MethodPost, "/datasets/"+url.PathEscape(datasetID)+"/resources", r)
+	if err != nil {
+		return "", err
+	}
+	if doc.Data.ID == "" {
+		return "", errors.New("dane.gov.pl returned no resource id")
+	}
+	return doc.Data.ID, nil
+}
+
+// UpdateResource replaces the attributes of an existing resource.
+func (c *Client) UpdateResource(ctx context.Context, resourceID string, r Resource) error {
+	_, err := c.do(ctx, http.MethodPatch, "/resources/"+url.PathEscape(resourceID), r)
+	return err
+}
+
+func (c *Client) do(ctx context.Context, method, path string, r Resource) (*resourceDocument, error) {
+	body, err := json.Marshal(resourceDocument{Data: resourceData{Type: "resource", Attributes: r}})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mediaJSONAPI)
+	req.Header.Set("Accept", mediaJSONAPI)
+	req.Header.Set("User-Agent", userAgent)
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%s %s: %w", method, path, ErrNotFound)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		msg := strings.TrimSpace(string(data))
+		if len(msg) > 200 {
+			msg = msg[:200]
+		}
+		if msg == "" {
+			return nil, fmt.Errorf("%s %s: HTTP %d", method, path, resp.StatusCode)
+		}
+		return nil, fmt.Errorf("%s %s: HTTP %d: %s", method, path, resp.StatusCode, msg)
+	}
+
+	var doc resourceDocument
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("%s %s: invalid response: %w", method, path, err)
+		}
+	}
+	return &doc, nil
+}
@@ -0,0 +1,180 @@
+// Package publisher pushes each office's dataset to dane.gov.pl. Offices
+// are linked to a remote dataset by hand; the publisher creates a CSV and a
+// JSON resource in it and updates them when the office's items change, at
+// most once per interval. Failed pushes are retried with backoff.
+package publisher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/export"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+	"github.com/kacperfilipiuk/zguba-gov/internal/repository"
+)
+
+const (
+	baseBackoff = time.Minute
+	maxBackoff  = 24 * time.Hour
+	// checkEvery is how often Run looks for due resources; the push
+	// schedule itself is kept per resource in next_attempt_at.
+	checkEvery = time.Minute
+)
+
+type Publisher struct {
+	client   *Client
+	pubs     *repository.PublicationRepo
+	items    *repository.FoundItemRepo
+	baseURL  string
+	interval time.Duration
+}
+
+// New returns a publisher that pushes changed resources at most once per
+// interval.
+func New(client *Client, pubs *repository.PublicationRepo, items *repository.FoundItemRepo, baseURL string, interval time.Duration) *Publisher {
+	return &Publisher{client: client, pubs: pubs, items: items, baseURL: baseURL, interval: interval}
+}
+
+// Options narrow or widen a Sync.
+type Options struct {
+	// Office limits the sync to one office.
+	Office string
+	// Now ignores the schedule and the retry backoff.
+	Now bool
+	// All also pushes resources whose items have not changed.
+	All bool
+}
+
+type Result struct {
+	Pushed int
+	Failed int
+}
+
+// Sync pushes every resource that is due: never pushed, failed before, or
+// with items changed since the last push.
+func (p *Publisher) Sync(ctx context.Context, now time.Time, opts Options) (Result, error) {
+	var res Result
+	pubs, err := p.pubs.List()
+	if err != nil {
+		return res, err
+	}
+	offices, err := p.items.OfficeSummaries()
+	if err != nil {
+		return res, err
+	}
+	byEmail := map[string]model.OfficeSummary{}
+	for _, o := range offices {
+		byEmail[o.Email] = o
+	}
+
+	only := strings.ToLower(strings.TrimSpace(opts.Office))
+	for _, pub := range pubs {
+		if only != "" && pub.Office != only {
+			continue
+		}
+		office, ok := byEmail[pub.Office]
+		if !ok {
+			office = model.OfficeSummary{Name: pub.Office, Email: pub.Office}
+		}
+		for _, r := range pub.Resources {
+			if ctx.Err() != nil {
+				return res, ctx.Err()
+			}
+			if !opts.Now && r.NextAttemptAt.After(now) {
+				continue
+			}
+			changed := r.ResourceID == "" || r.LastError != "" || office.Modified.After(r.SyncedModified)
+			if !changed && !opts.All {
+				continue
+			}
+
+			if err := p.push(ctx, pub, r, office, now); err != nil {
+				res.Failed++
+				log.Printf("publisher: %s %s: %v", pub.Office, r.Kind, err)
+				continue
+			}
+			res.Pushed++
+		}
+	}
+	return res, nil
+}
+
+func (p *Publisher) push(ctx context.Context, pub model.Publication, r model.PublishedResource, office model.OfficeSummary, now time.Time) error {
+	remote := p.resource(r.Kind, office)
+	id := r.ResourceID
+	var err error
+	if id == "" {
+		id, err = p.client.CreateResource(ctx, pub.DatasetID, remote)
+	} else {
+		err = p.client.UpdateResource(ctx, id, remote)
+	}
+	if err != nil {
+		// A resource deleted on dane.gov.pl is created again right away.
+		forget := id != "" && errors.Is(err, ErrNotFound)
+		next := now.Add(Backoff(r.Attempts + 1))
+		if forget {
+			next = now
+		}
+		if markErr := p.pubs.MarkFailed(pub.Office, r.Kind, err, next, forget); markErr != nil {
+			return markErr
+		}
+		return err
+	}
+	return p.pubs.MarkSynced(pub.Office, r.Kind, id, office.Modified, now.Add(p.interval))
+}
+
+// resource describes the resource of the given kind for an office. The
+// links are the same filtered endpoints as in the DCAT catalog.
+func (p *Publisher) resource(kind string, office model.OfficeSummary) Resource {
+	r := Resource{
+		Description: fmt.Sprintf("Rzeczy znalezione zarejestrowane przez urząd: %s. Liczba przedmiotów: %d.", office.Name, office.Count),
+		Format:      kind,
+	}
+	if !office.Modified.IsZero() {
+		r.DataDate = office.Modified.UTC().Format("2006-01-02")
+	}
+	switch kind {
+	case model.ResourceCSV:
+		r.Title = "Rzeczy znalezione – " + office.Name + " (CSV)"
+		r.Link = p.baseURL + "/api/found-items/export?" + url.Values{"format": {export.FormatCSV}, "office": {office.Email}}.Encode()
+	default:
+		r.Title = "Rzeczy znalezione – " + office.Name + " (JSON)"
+		r.Link = p.baseURL + "/api/found-items?" + url.Values{"office": {office.Email}}.Encode()
+	}
+	return r
+}
+
+// Run syncs due resources until ctx is cancelled.
+func (p *Publisher) Run(ctx context.Context) {
+	ticker := time.NewTicker(checkEvery)
+	defer ticker.Stop()
+	for {
+		res, err := p.Sync(ctx, time.Now(), Options{})
+		if err != nil && ctx.Err() == nil {
+			log.Printf("publisher: sync: %v", err)
+		}
+		if res.Pushed > 0 || res.Failed > 0 {
+			log.Printf("publisher: %d resources pushed, %d failed", res.Pushed, res.Failed)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Backoff is the delay before the next attempt after the given number of
+// failed attempts: a minute, doubling up to a day.
+func Backoff(attempts int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
@@ -0,0 +1,237 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/database"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+	"github.com/kacperfilipiuk/zguba-gov/internal/repository"
+)
+
+const (
+	testOffice  = "biuro@urzad.example.gov.pl"
+	testDataset = "ds-1"
+	testToken   = "secret-token"
+	interval    = time.Hour
+)
+
+// fakeDaneGov stands in for the resource endpoints of dane.gov.pl. It
+// answers each write with the next of statuses, repeating the last one.
+type fakeDaneGov struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	nextID   int
+	requests []string
+}
+
+func newFake(t *testing.T, statuses ...int) *fakeDaneGov {
+	t.Helper()
+	f := &fakeDaneGov{statuses: statuses}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeDaneGov) serve(w http.ResponseWriter, r *http.Request) {
+	var doc resourceDocument
+	if r.Header.Get("Authorization") != "Bearer "+testToken ||
+		r.Header.Get("Content-Type") != mediaJSONAPI ||
+		json.NewDecoder(r.Body).Decode(&doc) != nil || doc.Data.Type != "resource" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	status := f.statuses[0]
+	if len(f.statuses) > 1 {
+		f.statuses = f.statuses[1:]
+	}
+	if r.Method == http.MethodPost {
+		f.nextID++
+		doc.Data.ID = fmt.Sprintf("res-%d", f.nextID)
+	} else {
+		doc.Data.ID = strings.TrimPrefix(r.URL.Path, "/resources/")
+	}
+	f.mu.Unlock()
+
+	switch {
+	case status == http.StatusNotFound:
+		http.NotFound(w, r)
+	case status >= 300:
+		http.Error(w, `{"errors": [{"detail": "rejected"}]}`, status)
+	default:
+		w.Header().Set("Content-Type", mediaJSONAPI)
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(doc)
+	}
+}
+
+func (f *fakeDaneGov) received() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.requests...)
+}
+
+// newPublisher returns a publisher that pushes to fake the resources of
+// the test office, registered against a fresh database.
+func newPublisher(t *testing.T, fake *fakeDaneGov) *Publisher {
+	t.Helper()
+	db, err := database.Open(filepath.Join(t.TempDir(), "publisher.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	pubs := repository.NewPublicationRepo(db)
+	if _, err := pubs.Save(testOffice, testDataset); err != nil {
+		t.Fatal(err)
+	}
+	return New(NewClient(fake.URL, testToken), pubs, repository.NewFoundItemRepo(db, nil), "https://zguba.example", interval)
+}
+
+func resources(t *testing.T, p *Publisher) []model.PublishedResource {
+	t.Helper()
+	pub, err := p.pubs.Get(testOffice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub.Resources
+}
+
+func TestSync(t *testing.T) {
+	tests := []struct {
+		name string
+		// remote marks the resources as already created remotely.
+		remote       bool
+		statuses     []int
+		want         Result
+		wantRequest  string
+		wantAttempts int
+		wantRemote   bool
+		wantError    string
+		// wantNext is the delay before the next push.
+		wantNext time.Duration
+	}{
+		{
+			name: "created", statuses: []int{http.StatusCreated},
+			want: Result{Pushed: 2}, wantRequest: "POST /datasets/ds-1/resources",
+			wantRemote: true, wantNext: interval,
+		},
+		{
+			name: "updated", remote: true, statuses: []int{http.StatusOK},
+			want: Result{Pushed: 2}, wantRequest: "PATCH /resources/",
+			wantRemote: true, wantNext: interval,
+		},
+		{
+			name: "server error is retried", statuses: []int{http.StatusServiceUnavailable},
+			want: Result{Failed: 2}, wantRequest: "POST /datasets/ds-1/resources",
+			wantAttempts: 1, wantError: "HTTP 503", wantNext: baseBackoff,
+		},
+		{
+			name: "rejected resource backs off", remote: true, statuses: []int{http.StatusUnprocessableEntity},
+			want: Result{Failed: 2}, wantRequest: "PATCH /resources/",
+			wantAttempts: 1, wantRemote: true, wantError: "HTTP 422: " + `{"errors": [{"detail": "rejected"}]}`, wantNext: baseBackoff,
+		},
+		{
+			name: "missing dataset backs off", statuses: []int{http.StatusNotFound},
+			want: Result{Failed: 2}, wantRequest: "POST /datasets/ds-1/resources",
+			wantAttempts: 1, wantError: ErrNotFound.Error(), wantNext: baseBackoff,
+		},
+		{
+			name: "resource deleted remotely is created again", remote: true, statuses: []int{http.StatusNotFound},
+			want: Result{Failed: 2}, wantRequest: "PATCH /resources/",
+			wantAttempts: 1, wantError: ErrNotFound.Error(), wantNext: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFake(t, tt.statuses...)
+			p := newPublisher(t, fake)
+			now := time.Now().UTC()
+			if tt.remote {
+				for _, kind := range model.ResourceKinds {
+					if err := p.pubs.MarkSynced(testOffice, kind, "remote-"+kind, now.Add(-time.Hour), now.Add(-time.Minute)); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			res, err := p.Sync(context.Background(), now, Options{All: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res != tt.want {
+				t.Errorf("Sync = %+v, want %+v", res, tt.want)
+			}
+			for _, req := range fake.received() {
+				if !strings.HasPrefix(req, tt.wantRequest) {
+					t.Errorf("request %q, want %s…", req, tt.wantRequest)
+				}
+			}
+
+			for _, r := range resources(t, p) {
+				if r.Attempts != tt.wantAttempts {
+					t.Errorf("%s: %d attempts, want %d", r.Kind, r.Attempts, tt.wantAttempts)
+				}
+				if (r.ResourceID != "") != tt.wantRemote {
+					t.Errorf("%s: resource ID %q, want one: %v", r.Kind, r.ResourceID, tt.wantRemote)
+				}
+				if !strings.Contains(r.LastError, tt.wantError) || (tt.wantError == "") != (r.LastError == "") {
+					t.Errorf("%s: last error %q, want %q", r.Kind, r.LastError, tt.wantError)
+				}
+				if want := now.Add(tt.wantNext); r.NextAttemptAt.Sub(want).Abs() > time.Second {
+					t.Errorf("%s: next attempt at %v, want %v", r.Kind, r.NextAttemptAt, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSyncRetriesUntilPushed(t *testing.T) {
+	fake := newFake(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusBadGateway, http.StatusCreated)
+	p := newPublisher(t, fake)
+	now := time.Now().UTC()
+
+	steps := []struct {
+		at   time.Duration
+		want Result
+	}{
+		{0, Result{Failed: 2}},
+		// Not due before the backoff has passed.
+		{baseBackoff / 2, Result{}},
+		{baseBackoff, Result{Failed: 2}},
+		{baseBackoff + Backoff(1), Result{}},
+		{baseBackoff + Backoff(2), Result{Pushed: 2}},
+		// Nothing changed since the push.
+		{baseBackoff + Backoff(2) + interval, Result{}},
+	}
+	for _, s := range steps {
+		res, err := p.Sync(context.Background(), now.Add(s.at), Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != s.want {
+			t.Fatalf("Sync at +%v = %+v, want %+v", s.at, res, s.want)
+		}
+	}
+	for _, r := range resources(t, p) {
+		if r.ResourceID == "" || r.Attempts != 0 || r.LastError != "" {
+			t.Errorf("%s: %+v, want pushed", r.Kind, r)
+		}
+	}
+	if got := len(fake.received()); got != 6 {
+		t.Errorf("fake got %d requests, want 6", got)
+	}
+}
@@ -0,0 +1,213 @@
+// Package rdf is a minimal RDF graph with Turtle, RDF/XML and JSON-LD
+// writers, enough to publish DCAT metadata without a triple store.
+package rdf
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Common namespaces.
+const (
+	RDF   = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	XSD   = "http://www.w3.org/2001/XMLSchema#"
+	DCAT  = "http://www.w3.org/ns/dcat#"
+	DCT   = "http://purl.org/dc/terms/"
+	FOAF  = "http://xmlns.com/foaf/0.1/"
+	VCARD = "http://www.w3.org/2006/vcard/ns#"
+	ADMS  = "http://www.w3.org/ns/adms#"
+	VOID  = "http://rdfs.org/ns/void#"
+)
+
+// Term is an IRI, a blank node or a literal.
+type Term struct {
+	kind     termKind
+	Value    string
+	Lang     string
+	Datatype string
+}
+
+type termKind int
+
+const (
+	kindIRI termKind = iota
+	kindBlank
+	kindLiteral
+)
+
+func IRI(v string) Term    { return Term{kind: kindIRI, Value: v} }
+func Blank(id string) Term { return Term{kind: kindBlank, Value: id} }
+
+// Literal is a plain string literal.
+func Literal(v string) Term { return Term{kind: kindLiteral, Value: v} }
+
+// LangLiteral is a string in the given language.
+func LangLiteral(v, lang string) Term { return Term{kind: kindLiteral, Value: v, Lang: lang} }
+
+// Typed is a literal with an XSD or other datatype IRI.
+func Typed(v, datatype string) Term { return Term{kind: kindLiteral, Value: v, Datatype: datatype} }
+
+func Integer(n int) Term { return Typed(strconv.Itoa(n), XSD+"integer") }
+
+func (t Term) IsIRI() bool     { return t.kind == kindIRI }
+func (t Term) IsBlank() bool   { return t.kind == kindBlank }
+func (t Term) IsLiteral() bool { return t.kind == kindLiteral }
+
+type Triple struct {
+	Subject   Term
+	Predicate string
+	Object    Term
+}
+
+// Graph is an ordered set of triples. Subjects are written in the order
+// they were first used, so output is stable.
+type Graph struct {
+	prefixes map[string]string
+	triples  []Triple
+	blanks   int
+}
+
+// NewGraph returns a graph that abbreviates IRIs with the given prefixes
+// (prefix → namespace).
+func NewGraph(prefixes map[string]string) *Graph {
+	p := map[string]string{"rdf": RDF, "xsd": XSD}
+	for k, v := range prefixes {
+		p[k] = v
+	}
+	return &Graph{prefixes: p}
+}
+
+// Add appends a triple. Empty literals are skipped, which keeps optional
+// properties out of the output.
+func (g *Graph) Add(s Term, p string, o Term) {
+	if o.IsLiteral() && o.Value == "" {
+		return
+	}
+	g.triples = append(g.triples, Triple{s, p, o})
+}
+
+// Type adds an rdf:type triple.
+func (g *Graph) Type(s Term, class string) {
+	g.Add(s, RDF+"type", IRI(class))
+}
+
+// NewBlank returns a fresh blank node.
+func (g *Graph) NewBlank() Term {
+	g.blanks++
+	return Blank("b" + strconv.Itoa(g.blanks))
+}
+
+// Subgraph returns the triples about s and, recursively, about the blank
+// nodes it refers to, sharing the prefixes of g.
+func (g *Graph) Subgraph(s Term) *Graph {
+	out := &Graph{prefixes: g.prefixes}
+	seen := map[Term]bool{}
+	var walk func(Term)
+	walk = func(s Term) {
+		if seen[s] {
+			return
+		}
+		seen[s] = true
+		for _, t := range g.triples {
+			if t.Subject == s {
+				out.triples = append(out.triples, t)
+				if t.Object.IsBlank() {
+					walk(t.Object)
+				}
+			}
+		}
+	}
+	walk(s)
+	return out
+}
+
+// Len is the number of triples.
+func (g *Graph) Len() int {
+	return len(g.triples)
+}
+
+// subject groups the triples of one subject, predicates in first-use
+// order.
+type subject struct {
+	term       Term
+	predicates []string
+	objects    map[string][]Term
+}
+
+func (g *Graph) subjects() []*subject {
+	var order []*subject
+	index := map[Term]*subject{}
+	for _, t := range g.triples {
+		s := index[t.Subject]
+		if s == nil {
+			s = &subject{term: t.Subject, objects: map[string][]Term{}}
+			index[t.Subject] = s
+			order = append(order, s)
+		}
+		if _, ok := s.objects[t.Predicate]; !ok {
+			s.predicates = append(s.predicates, t.Predicate)
+		}
+		s.objects[t.Predicate] = append(s.objects[t.Predicate], t.Object)
+	}
+	return order
+}
+
+// usedPrefixes returns the prefixes needed by the triples, sorted.
+func (g *Graph) usedPrefixes() []string {
+	used := map[string]bool{}
+	mark := func(iri string) {
+		if p, _, ok := g.split(iri); ok {
+			used[p] = true
+		}
+	}
+	for _, t := range g.triples {
+		mark(t.Predicate)
+		if t.Object.IsIRI() {
+			mark(t.Object.Value)
+		}
+		if t.Object.Datatype != "" {
+			mark(t.Object.Datatype)
+		}
+	}
+	var out []string
+	for p := range used {
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// split abbreviates iri to prefix and local name when a prefix matches and
+// the local name is safe in every serialisation.
+func (g *Graph) split(iri string) (prefix, local string, ok bool) {
+	best := ""
+	for p, ns := range g.prefixes {
+		if strings.HasPrefix(iri, ns) && len(ns) > len(g.prefixes[best]) {
+			best = p
+		}
+	}
+	if best == "" {
+		return "", "", false
+	}
+	local = strings.TrimPrefix(iri, g.prefixes[best])
+	if !isLocalName(local) {
+		return "", "", false
+	}
+	return best, local, true
+}
+
+func isLocalName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+		case i > 0 && (r >= '0' && r <= '9' || r == '-'):
+		default:
+			return false
+		}
+	}
+	return true
+}
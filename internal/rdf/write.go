@@ -0,0 +1,234 @@
+package rdf
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Serialisation media types.
+const (
+	MediaJSONLD = "application/ld+json"
+	MediaTurtle = "text/turtle"
+	MediaRDFXML = "application/rdf+xml"
+)
+
+// Write serialises g in the format named by its media type.
+func (g *Graph) Write(w io.Writer, mediaType string) error {
+	switch mediaType {
+	case MediaTurtle:
+		return g.WriteTurtle(w)
+	case MediaRDFXML:
+		return g.WriteRDFXML(w)
+	case MediaJSONLD:
+		return g.WriteJSONLD(w)
+	}
+	return fmt.Errorf("rdf: unsupported media type %q", mediaType)
+}
+
+// WriteTurtle writes g as Turtle.
+func (g *Graph) WriteTurtle(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, p := range g.usedPrefixes() {
+		fmt.Fprintf(bw, "@prefix %s: <%s> .\n", p, g.prefixes[p])
+	}
+
+	for _, s := range g.subjects() {
+		bw.WriteString("\n" + g.turtleTerm(s.term))
+		for i, p := range s.predicates {
+			if i > 0 {
+				bw.WriteString(" ;")
+			}
+			pred := g.turtleIRI(p)
+			if p == RDF+"type" {
+				pred = "a"
+			}
+			bw.WriteString("\n    " + pred + " ")
+			for j, o := range s.objects[p] {
+				if j > 0 {
+					bw.WriteString(", ")
+				}
+				bw.WriteString(g.turtleTerm(o))
+			}
+		}
+		bw.WriteString(" .\n")
+	}
+	return bw.Flush()
+}
+
+func (g *Graph) turtleTerm(t Term) string {
+	switch t.kind {
+	case kindBlank:
+		return "_:" + t.Value
+	case kindLiteral:
+		lit := `"` + turtleEscaper.Replace(t.Value) + `"`
+		if t.Lang != "" {
+			return lit + "@" + t.Lang
+		}
+		if t.Datatype != "" {
+			return lit + "^^" + g.turtleIRI(t.Datatype)
+		}
+		return lit
+	}
+	return g.turtleIRI(t.Value)
+}
+
+func (g *Graph) turtleIRI(iri string) string {
+	if p, local, ok := g.split(iri); ok {
+		return p + ":" + local
+	}
+	return "<" + iriEscaper.Replace(iri) + ">"
+}
+
+var turtleEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+var iriEscaper = strings.NewReplacer(">", "%3E", "<", "%3C", `"`, "%22", " ", "%20", "{", "%7B", "}", "%7D", "|", "%7C", `\`, "%5C", "^", "%5E", "`", "%60")
+
+// WriteRDFXML writes g as RDF/XML, one rdf:Description per subject.
+func (g *Graph) WriteRDFXML(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(xml.Header + "<rdf:RDF")
+	for _, p := range g.usedPrefixes() {
+		fmt.Fprintf(bw, "\n    xmlns:%s=\"%s\"", p, xmlEscape(g.prefixes[p]))
+	}
+	if !containsString(g.usedPrefixes(), "rdf") {
+		fmt.Fprintf(bw, "\n    xmlns:rdf=\"%s\"", RDF)
+	}
+	bw.WriteString(">\n")
+
+	for _, s := range g.subjects() {
+		if s.term.IsBlank() {
+			fmt.Fprintf(bw, "  <rdf:Description rdf:nodeID=\"%s\">\n", xmlEscape(s.term.Value))
+		} else {
+			fmt.Fprintf(bw, "  <rdf:Description rdf:about=\"%s\">\n", xmlEscape(s.term.Value))
+		}
+		for _, p := range s.predicates {
+			name, decl := g.xmlName(p)
+			for _, o := range s.objects[p] {
+				switch o.kind {
+				case kindIRI:
+					fmt.Fprintf(bw, "    <%s%s rdf:resource=\"%s\"/>\n", name, decl, xmlEscape(o.Value))
+				case kindBlank:
+					fmt.Fprintf(bw, "    <%s%s rdf:nodeID=\"%s\"/>\n", name, decl, xmlEscape(o.Value))
+				default:
+					attr := ""
+					if o.Lang != "" {
+						attr = fmt.Sprintf(" xml:lang=\"%s\"", xmlEscape(o.Lang))
+					} else if o.Datatype != "" {
+						attr = fmt.Sprintf(" rdf:datatype=\"%s\"", xmlEscape(o.Datatype))
+					}
+					fmt.Fprintf(bw, "    <%s%s%s>%s</%s>\n", name, decl, attr, xmlEscape(o.Value), name)
+				}
+			}
+		}
+		bw.WriteString("  </rdf:Description>\n")
+	}
+	bw.WriteString("</rdf:RDF>\n")
+	return bw.Flush()
+}
+
+// xmlName returns the element name for a predicate and, for IRIs without
+// a registered prefix, the namespace declaration it needs.
+func (g *Graph) xmlName(iri string) (name, decl string) {
+	if p, local, ok := g.split(iri); ok {
+		return p + ":" + local, ""
+	}
+	i := strings.LastIndexAny(iri, "#/")
+	return "ns0:" + iri[i+1:], fmt.Sprintf(" xmlns:ns0=\"%s\"", xmlEscape(iri[:i+1]))
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteJSONLD writes g as a JSON-LD document with a prefix @context and
+// one flattened node object per subject in @graph.
+func (g *Graph) WriteJSONLD(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("{\n  \"@context\": {")
+	for i, p := range g.usedPrefixes() {
+		if i > 0 {
+			bw.WriteString(",")
+		}
+		fmt.Fprintf(bw, "\n    %s: %s", jsonString(p), jsonString(g.prefixes[p]))
+	}
+	bw.WriteString("\n  },\n  \"@graph\": [")
+
+	for i, s := range g.subjects() {
+		if i > 0 {
+			bw.WriteString(",")
+		}
+		bw.WriteString("\n    {\n      \"@id\": " + jsonString(g.jsonID(s.term)))
+		for _, p := range s.predicates {
+			objs := s.objects[p]
+			key := g.jsonKey(p)
+			var vals []string
+			for _, o := range objs {
+				if p == RDF+"type" {
+					vals = append(vals, jsonString(g.jsonKey(o.Value)))
+				} else {
+					vals = append(vals, g.jsonValue(o))
+				}
+			}
+			if p == RDF+"type" {
+				key = "@type"
+			}
+			bw.WriteString(",\n      " + jsonString(key) + ": ")
+			if len(vals) == 1 {
+				bw.WriteString(vals[0])
+			} else {
+				bw.WriteString("[" + strings.Join(vals, ", ") + "]")
+			}
+		}
+		bw.WriteString("\n    }")
+	}
+	bw.WriteString("\n  ]\n}\n")
+	return bw.Flush()
+}
+
+func (g *Graph) jsonKey(iri string) string {
+	if p, local, ok := g.split(iri); ok {
+		return p + ":" + local
+	}
+	return iri
+}
+
+func (g *Graph) jsonID(t Term) string {
+	if t.IsBlank() {
+		return "_:" + t.Value
+	}
+	return t.Value
+}
+
+func (g *Graph) jsonValue(t Term) string {
+	switch t.kind {
+	case kindIRI, kindBlank:
+		return `{"@id": ` + jsonString(g.jsonID(t)) + `}`
+	}
+	switch {
+	case t.Lang != "":
+		return `{"@value": ` + jsonString(t.Value) + `, "@language": ` + jsonString(t.Lang) + `}`
+	case t.Datatype != "":
+		return `{"@value": ` + jsonString(t.Value) + `, "@type": ` + jsonString(g.jsonKey(t.Datatype)) + `}`
+	}
+	return jsonString(t.Value)
+}
+
+func jsonString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
@@ -0,0 +1,215 @@
+package receipt
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+	"github.com/kacperfilipiuk/zguba-gov/internal/municipality"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// The core PDF fonts cannot encode Polish letters, so a UTF-8 font is embedded.
+//
+//go:embed fonts/DejaVuSansCondensed.ttf fonts/DejaVuSansCondensed-Bold.ttf
+var fontFS embed.FS
+
+const font = "DejaVu"
+
+var statusLabels = map[string]string{
+	"available": "Oczekuje na odbiór",
+	"claimed":   "Odebrana",
+	"expired":   "Przekazana",
+}
+
+// Receipt holds everything printed on the handover protocol. Office may be
+// nil when the item's municipality is not in the territorial dataset.
+type Receipt struct {
+	Item   model.FoundItemResponse
+	Office *municipality.TerritorialUnit
+	// Category is the display label of the item category; the code is
+	// printed when empty.
+	Category string
+	ItemURL  string
+	// IssuedAt is when the item was registered, so that the receipt
+	// printed again later carries the same date.
+	IssuedAt time.Time
+}
+
+// Render writes the receipt (protokół przyjęcia rzeczy znalezionej) as PDF.
+func Render(w io.Writer, r Receipt) error {
+	regular, err := fontFS.ReadFile("fonts/DejaVuSansCondensed.ttf")
+	if err != nil {
+		return err
+	}
+	bold, err := fontFS.ReadFile("fonts/DejaVuSansCondensed-Bold.ttf")
+	if err != nil {
+		return err
+	}
+
+	qr, err := qrcode.Encode(r.ItemURL, qrcode.Medium, 256)
+	if err != nil {
+		return fmt.Errorf("qr code: %w", err)
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle("Protokół przyjęcia rzeczy znalezionej", true)
+	pdf.SetAuthor(officeName(r), true)
+	pdf.SetCreationDate(r.IssuedAt)
+	pdf.SetModificationDate(r.IssuedAt)
+	pdf.AddUTF8FontFromBytes(font, "", regular)
+	pdf.AddUTF8FontFromBytes(font, "B", bold)
+	pdf.SetMargins(20, 20, 20)
+	pdf.SetAutoPageBreak(true, 20)
+	pdf.AddPage()
+
+	writeHeader(pdf, r)
+
+	pdf.Ln(8)
+	pdf.SetFont(font, "B", 15)
+	pdf.CellFormat(0, 8, "PROTOKÓŁ PRZYJĘCIA RZECZY ZNALEZIONEJ", "", 1, "C", false, 0, "")
+	pdf.SetFont(font, "", 10)
+	pdf.CellFormat(0, 6, "nr "+r.Item.ID, "", 1, "C", false, 0, "")
+	pdf.CellFormat(0, 6, "sporządzony dnia "+r.IssuedAt.Format("02.01.2006"), "", 1, "C", false, 0, "")
+
+	section(pdf, "Dane rzeczy")
+	row(pdf, "Nazwa przedmiotu", r.Item.Item.Name)
+	category := r.Category
+	if category == "" {
+		category = r.Item.Item.Category
+	}
+	row(pdf, "Kategoria", category)
+	if len(r.Item.Categories) > 0 {
+		row(pdf, "Kategorie dodatkowe", strings.Join(r.Item.Categories, ", "))
+	}
+	row(pdf, "Data znalezienia", formatDate(r.Item.Item.Date))
+	row(pdf, "Miejsce znalezienia", r.Item.Item.Location)
+	row(pdf, "Status", statusLabel(r.Item.Item.Status))
+	if r.Item.Item.Description != "" {
+		row(pdf, "Opis", r.Item.Item.Description)
+	}
+
+	section(pdf, "Warunki odbioru")
+	row(pdf, "Miejsce odbioru", r.Item.Pickup.Location)
+	if r.Item.Pickup.Hours != "" {
+		row(pdf, "Godziny odbioru", r.Item.Pickup.Hours)
+	}
+	if r.Item.Pickup.Contact != "" {
+		row(pdf, "Osoba kontaktowa", r.Item.Pickup.Contact)
+	}
+	row(pdf, "Termin przechowania", fmt.Sprintf("%d dni, do %s", r.Item.Pickup.Deadline, deadline(r).Format("02.01.2006")))
+
+	pdf.Ln(3)
+	pdf.SetFont(font, "", 9)
+	pdf.MultiCell(0, 4.5, "Rzecz zostanie wydana osobie uprawnionej po okazaniu dokumentu tożsamości "+
+		"i wykazaniu prawa do rzeczy (np. przez jej opis lub dowód zakupu). Po upływie terminu "+
+		"przechowania rzecz niepodjęta podlega dalszemu postępowaniu zgodnie z ustawą z dnia "+
+		"20 lutego 2015 r. o rzeczach znalezionych.", "", "J", false)
+
+	writeQRAndSignatures(pdf, r, qr)
+
+	if err := pdf.Error(); err != nil {
+		return err
+	}
+	return pdf.Output(w)
+}
+
+func writeHeader(pdf *fpdf.Fpdf, r Receipt) {
+	pdf.SetFont(font, "B", 12)
+	pdf.CellFormat(0, 6, officeName(r), "", 1, "L", false, 0, "")
+	pdf.SetFont(font, "", 9)
+	if o := r.Office; o != nil {
+		var region []string
+		if o.Voivodeship != "" {
+			region = append(region, "woj. "+o.Voivodeship)
+		}
+		if o.County != "" {
+			region = append(region, "pow. "+o.County)
+		}
+		if len(region) > 0 {
+			pdf.CellFormat(0, 5, strings.Join(region, ", "), "", 1, "L", false, 0, "")
+		}
+		if o.ID != "" {
+			pdf.CellFormat(0, 5, "TERYT: "+string(o.ID), "", 1, "L", false, 0, "")
+		}
+	}
+	if email := r.Item.Municipality.ContactEmail; email != "" {
+		pdf.CellFormat(0, 5, "e-mail: "+email, "", 1, "L", false, 0, "")
+	}
+	y := pdf.GetY() + 2
+	pdf.Line(20, y, 190, y)
+	pdf.SetY(y)
+}
+
+func writeQRAndSignatures(pdf *fpdf.Fpdf, r Receipt, qr []byte) {
+	const qrSize = 32
+	if pdf.GetY()+qrSize+20 > 277 {
+		pdf.AddPage()
+	}
+	top := pdf.GetY() + 10
+
+	opts := fpdf.ImageOptions{ImageType: "PNG"}
+	pdf.RegisterImageOptionsReader("qr", opts, bytes.NewReader(qr))
+	pdf.ImageOptions("qr", 190-qrSize, top, qrSize, qrSize, false, opts, 0, r.ItemURL)
+	pdf.SetFont(font, "", 7)
+	pdf.SetXY(190-qrSize-10, top+qrSize)
+	pdf.CellFormat(qrSize+10, 4, "Status rzeczy online", "", 0, "C", false, 0, r.ItemURL)
+
+	pdf.SetFont(font, "", 9)
+	lineY := top + 20
+	pdf.Line(20, lineY, 70, lineY)
+	pdf.Line(85, lineY, 135, lineY)
+	pdf.SetXY(20, lineY+1)
+	pdf.CellFormat(50, 5, "podpis znalazcy", "", 0, "C", false, 0, "")
+	pdf.SetXY(85, lineY+1)
+	pdf.CellFormat(50, 5, "podpis przyjmującego", "", 0, "C", false, 0, "")
+}
+
+func section(pdf *fpdf.Fpdf, title string) {
+	pdf.Ln(6)
+	pdf.SetFont(font, "B", 11)
+	pdf.SetFillColor(235, 235, 235)
+	pdf.CellFormat(0, 7, title, "", 1, "L", true, 0, "")
+	pdf.Ln(1)
+}
+
+func row(pdf *fpdf.Fpdf, label, value string) {
+	pdf.SetFont(font, "B", 9)
+	pdf.CellFormat(50, 6, label+":", "", 0, "L", false, 0, "")
+	pdf.SetFont(font, "", 9)
+	pdf.MultiCell(0, 6, value, "", "L", false)
+}
+
+func officeName(r Receipt) string {
+	if r.Office != nil && r.Office.OfficeName != "" {
+		return r.Office.OfficeName
+	}
+	return r.Item.Municipality.Name
+}
+
+func deadline(r Receipt) time.Time {
+	start, err := time.Parse(time.RFC3339, r.Item.CreatedAt)
+	if err != nil {
+		start = r.IssuedAt
+	}
+	return start.AddDate(0, 0, r.Item.Pickup.Deadline)
+}
+
+func formatDate(s string) string {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t.Format("02.01.2006")
+	}
+	return s
+}
+
+func statusLabel(s string) string {
+	if l, ok := statusLabels[s]; ok {
+		return l
+	}
+	return s
+}
@@ -0,0 +1,334 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/i18n"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+)
+
+var (
+	ErrCategoryExists = i18n.NewError("category.exists")
+	ErrCategoryInUse  = i18n.NewError("category.in_use")
+	ErrCategoryCode   = i18n.NewError("category.invalid_code")
+	ErrCategoryParent = i18n.NewError("category.unknown_parent")
+	ErrCategoryCycle  = i18n.NewError("category.cycle")
+)
+
+// CategoryRepo manages the item taxonomy. The table is small and read on
+// every wizard render, so it is cached in memory and reloaded after writes.
+type CategoryRepo struct {
+	db *sql.DB
+
+	mu    sync.RWMutex
+	cache []model.Category
+	// gen counts invalidations. A list loaded while the taxonomy changed
+	// may be stale and is not cached.
+	gen uint64
+}
+
+func NewCategoryRepo(db *sql.DB) *CategoryRepo {
+	return &CategoryRepo{db: db}
+}
+
+// List returns all categories depth-first, children after their parent,
+// siblings by sort order and label.
+func (r *CategoryRepo) List() ([]model.Category, error) {
+	r.mu.RLock()
+	cached, gen := r.cache, r.gen
+	r.mu.RUnlock()
+	if cached != nil {
+		return cached, nil
+	}
+
+	rows, err := r.db.Query("SELECT code, COALESCE(parent_code, ''), label_pl, label_en, label_uk, sort_order, created_at, updated_at FROM categories")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	children := map[string][]model.Category{}
+	for rows.Next() {
+		var c model.Category
+		var createdAt, updatedAt string
+		if err := rows.Scan(&c.Code, &c.ParentCode, &c.LabelPL, &c.LabelEN, &c.LabelUK, &c.SortOrder, &createdAt, &updatedAt); err != nil {
+			return nil, err
+		}
+		c.CreatedAt = parseTime(createdAt)
+		c.UpdatedAt = parseTime(updatedAt)
+		children[c.ParentCode] = append(children[c.ParentCode], c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	list := []model.Category{}
+	var walk func(parent, path string)
+	walk = func(parent, path string) {
+		siblings := children[parent]
+		sort.Slice(siblings, func(i, j int) bool {
+			if siblings[i].SortOrder != siblings[j].SortOrder {
+				return siblings[i].SortOrder < siblings[j].SortOrder
+			}
+			return siblings[i].LabelPL < siblings[j].LabelPL
+		})
+		for _, c := range siblings {
+			c.Path = c.LabelPL
+			if path != "" {
+				c.Path = path + " > " + c.LabelPL
+			}
+			list = append(list, c)
+			walk(c.Code, c.Path)
+		}
+	}
+	walk("", "")
+
+	r.mu.Lock()
+	if r.gen == gen {
+		r.cache = list
+	}
+	r.mu.Unlock()
+	return list, nil
+}
+
+// ListIn is List with labels and paths in lang.
+func (r *CategoryRepo) ListIn(lang string) ([]model.Category, error) {
+	list, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]model.Category, len(list))
+	paths := map[string]string{}
+	for i, c := range list {
+		c.Path = c.Label(lang)
+		if parent, ok := paths[c.ParentCode]; ok {
+			c.Path = parent + " > " + c.Path
+		}
+		paths[c.Code] = c.Path
+		out[i] = c
+	}
+	return out, nil
+}
+
+func (r *CategoryRepo) Get(code string) (*model.Category, error) {
+	list, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		if list[i].Code == code {
+			c := list[i]
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+// Label returns the Polish label of a code, or the code itself when it is
+// not in the taxonomy.
+func (r *CategoryRepo) Label(code string) string {
+	if c, err := r.Get(code); err == nil && c != nil {
+		return c.LabelPL
+	}
+	return code
+}
+
+// LabelIn is like Label but returns the label in lang, falling back to the
+// Polish one.
+func (r *CategoryRepo) LabelIn(code, lang string) string {
+	if c, err := r.Get(code); err == nil && c != nil {
+		return c.Label(lang)
+	}
+	return code
+}
+
+// Path is like Label but includes the parent labels, e.g.
+// "Elektronika > Telefony".
+func (r *CategoryRepo) Path(code string) string {
+	if c, err := r.Get(code); err == nil && c != nil {
+		return c.Path
+	}
+	return code
+}
+
+// IsWithin reports whether code is ancestor or one of its descendants.
+func (r *CategoryRepo) IsWithin(code, ancestor string) bool {
+	for seen := 0; code != "" && seen < 32; seen++ {
+		if code == ancestor {
+			return true
+		}
+		c, err := r.Get(code)
+		if err != nil || c == nil {
+			return false
+		}
+		code = c.ParentCode
+	}
+	return false
+}
+
+// Resolve finds the category for a code or a label in any language, as
+// typed in imported registers.
+func (r *CategoryRepo) Resolve(value string) (string, bool) {
+	list, err := r.List()
+	if err != nil {
+		return "", false
+	}
+	value = strings.TrimSpace(value)
+	code := model.CategoryCode(value)
+	for _, c := range list {
+		if c.Code == value || c.Code == code ||
+			strings.EqualFold(c.LabelPL, value) || strings.EqualFold(c.LabelEN, value) ||
+			strings.EqualFold(c.LabelUK, value) {
+			return c.Code, true
+		}
+	}
+	return "", false
+}
+
+// Unknown returns the codes that are not in the taxonomy.
+func (r *CategoryRepo) Unknown(codes ...string) ([]string, error) {
+	var unknown []string
+	for _, code := range codes {
+		c, err := r.Get(code)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			unknown = append(unknown, code)
+		}
+	}
+	return unknown, nil
+}
+
+func (r *CategoryRepo) Create(c model.CategoryCreate) (*model.Category, error) {
+	code := c.Code
+	if code == "" {
+		code = model.CategoryCode(c.LabelPL)
+	}
+	if code == "" || code != model.CategoryCode(code) {
+		return nil, ErrCategoryCode
+	}
+	if existing, err := r.Get(code); err != nil {
+		return nil, err
+	} else if existing != nil {
+		return nil, ErrCategoryExists
+	}
+	if c.ParentCode != "" {
+		if err := r.checkParent(code, c.ParentCode); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now().UTC()
+	if _, err := r.db.Exec(
+		"INSERT INTO categories (code, parent_code, label_pl, label_en, label_uk, sort_order, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		code, nullStr(c.ParentCode), c.LabelPL, c.LabelEN, c.LabelUK, c.SortOrder, now, now,
+	); err != nil {
+		return nil, fmt.Errorf("insert category: %w", err)
+	}
+	r.invalidate()
+	return r.Get(code)
+}
+
+// Update changes labels, order or parent. The code itself is immutable.
+func (r *CategoryRepo) Update(code string, u model.CategoryUpdate) (*model.Category, error) {
+	existing, err := r.Get(code)
+	if err != nil || existing == nil {
+		return nil, err
+	}
+
+	sets := []string{}
+	args := []any{}
+	if u.ParentCode != nil {
+		if *u.ParentCode != "" {
+			if err := r.checkParent(code, *u.ParentCode); err != nil {
+				return nil, err
+			}
+		}
+		sets = append(sets, "parent_code = ?")
+		args = append(args, nullStr(*u.ParentCode))
+	}
+	if u.LabelPL != nil {
+		sets = append(sets, "label_pl = ?")
+		args = append(args, *u.LabelPL)
+	}
+	if u.LabelEN != nil {
+		sets = append(sets, "label_en = ?")
+		args = append(args, *u.LabelEN)
+	}
+	if u.LabelUK != nil {
+		sets = append(sets, "label_uk = ?")
+		args = append(args, *u.LabelUK)
+	}
+	if u.SortOrder != nil {
+		sets = append(sets, "sort_order = ?")
+		args = append(args, *u.SortOrder)
+	}
+	if len(sets) == 0 {
+		return existing, nil
+	}
+
+	sets = append(sets, "updated_at = ?")
+	args = append(args, time.Now().UTC(), code)
+	if _, err := r.db.Exec("UPDATE categories SET "+strings.Join(sets, ", ")+" WHERE code = ?", args...); err != nil {
+		return nil, fmt.Errorf("update category: %w", err)
+	}
+	r.invalidate()
+	return r.Get(code)
+}
+
+// Delete removes a category that no item and no subcategory refers to.
+func (r *CategoryRepo) Delete(code string) error {
+	var n int
+	if err := r.db.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM categories WHERE parent_code = ?)
+		     + (SELECT COUNT(*) FROM found_items WHERE item_category = ?
+		        OR (json_valid(categories) AND EXISTS (SELECT 1 FROM json_each(categories) WHERE json_each.value = ?)))`,
+		code, code, code,
+	).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrCategoryInUse
+	}
+
+	result, err := r.db.Exec("DELETE FROM categories WHERE code = ?", code)
+	if err != nil {
+		return err
+	}
+	r.invalidate()
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// checkParent ensures the parent exists and is not code or a descendant.
+func (r *CategoryRepo) checkParent(code, parent string) error {
+	for p := parent; p != ""; {
+		if p == code {
+			return ErrCategoryCycle
+		}
+		c, err := r.Get(p)
+		if err != nil {
+			return err
+		}
+		if c == nil {
+			return ErrCategoryParent
+		}
+		p = c.ParentCode
+	}
+	return nil
+}
+
+func (r *CategoryRepo) invalidate() {
+	r.mu.Lock()
+	r.cache = nil
+	r.gen++
+	r.mu.Unlock()
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+)
+
+type DraftRepo struct {
+	db *sql.DB
+}
+
+func NewDraftRepo(db *sql.DB) *DraftRepo {
+	return &DraftRepo{db: db}
+}
+
+// Save inserts the draft when it has no ID yet, otherwise updates it. Only
+// a draft of d.SessionID is updated: a draft that no longer exists (e.g.
+// expired) or belongs to another session is saved under a new ID.
+func (r *DraftRepo) Save(d *model.Draft) error {
+	fields, err := json.Marshal(d.Fields)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+
+	if d.ID != "" {
+		result, err := r.db.Exec(
+			"UPDATE drafts SET step = ?, fields = ?, updated_at = ? WHERE id = ? AND session_id = ?",
+			d.Step, string(fields), now, d.ID, d.SessionID,
+		)
+		if err != nil {
+			return fmt.Errorf("update draft: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			d.UpdatedAt = now
+			return nil
+		}
+	}
+
+	d.ID = uuid.New().String()
+	if _, err := r.db.Exec(
+		"INSERT INTO drafts (id, session_id, step, fields, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)",
+		d.ID, d.SessionID, d.Step, string(fields), now, now,
+	); err != nil {
+		return fmt.Errorf("insert draft: %w", err)
+	}
+	d.CreatedAt, d.UpdatedAt = now, now
+	return nil
+}
+
+func (r *DraftRepo) Get(id string) (*model.Draft, error) {
+	drafts, err := r.query("SELECT id, session_id, step, fields, created_at, updated_at FROM drafts WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(drafts) == 0 {
+		return nil, nil
+	}
+	return &drafts[0], nil
+}
+
+func (r *DraftRepo) ListBySession(sessionID string) ([]model.Draft, error) {
+	return r.query("SELECT id, session_id, step, fields, created_at, updated_at FROM drafts WHERE session_id = ? ORDER BY updated_at DESC", sessionID)
+}
+
+func (r *DraftRepo) Delete(id, sessionID string) error {
+	_, err := r.db.Exec("DELETE FROM drafts WHERE id = ? AND session_id = ?", id, sessionID)
+	return err
+}
+
+// DeleteExpired removes drafts not touched since before.
+func (r *DraftRepo) DeleteExpired(before time.Time) (int64, error) {
+	result, err := r.db.Exec("DELETE FROM drafts WHERE updated_at < ?", before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *DraftRepo) query(query string, args ...any) ([]model.Draft, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var drafts []model.Draft
+	for rows.Next() {
+		var d model.Draft
+		var fields, createdStr, updatedStr string
+		if err := rows.Scan(&d.ID, &d.SessionID, &d.Step, &fields, &createdStr, &updatedStr); err != nil {
+			return nil, err
+		}
+		_ = json.Unmarshal([]byte(fields), &d.Fields)
+		d.CreatedAt = parseTime(createdStr)
+		d.UpdatedAt = parseTime(updatedStr)
+		drafts = append(drafts, d)
+	}
+	return drafts, rows.Err()
+}
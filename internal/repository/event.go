@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+)
+
+// EventRepo is the append-only log of item lifecycle events.
+type EventRepo struct {
+	db *sql.DB
+}
+
+func NewEventRepo(db *sql.DB) *EventRepo {
+	return &EventRepo{db: db}
+}
+
+// Append stores e and fills in its ID and OccurredAt.
+func (r *EventRepo) Append(e *model.Event) error {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	result, err := r.db.Exec("INSERT INTO events (type, item_id, previous_status, data, created_at) VALUES (?, ?, ?, ?, ?)",
+		e.Type, e.ItemID, nullStr(e.PreviousStatus), string(data), now)
+	if err != nil {
+		return fmt.Errorf("append event: %w", err)
+	}
+	e.ID, _ = result.LastInsertId()
+	e.OccurredAt = now
+	return nil
+}
+
+const eventColumns = "id, type, item_id, COALESCE(previous_status, ''), data, created_at"
+
+func (r *EventRepo) Get(id int64) (*model.Event, error) {
+	events, err := r.query("SELECT "+eventColumns+" FROM events WHERE id = ?", id)
+	if err != nil || len(events) == 0 {
+		return nil, err
+	}
+	return &events[0], nil
+}
+
+// Since returns up to limit events recorded after the event with the given
+// ID, oldest first.
+func (r *EventRepo) Since(afterID int64, limit int) ([]model.Event, error) {
+	return r.query("SELECT "+eventColumns+" FROM events WHERE id > ? ORDER BY id LIMIT ?", afterID, limit)
+}
+
+// Purge deletes events older than before, except those with webhook
+// deliveries that have not succeeded yet.
+func (r *EventRepo) Purge(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`
+		DELETE FROM events WHERE created_at < ?
+		AND id NOT IN (SELECT event_id FROM webhook_deliveries WHERE status != ?)`,
+		before.UTC(), model.DeliveryDelivered)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *EventRepo) query(query string, args ...any) ([]model.Event, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var events []model.Event
+	for rows.Next() {
+		var e model.Event
+		var data, created string
+		if err := rows.Scan(&e.ID, &e.Type, &e.ItemID, &e.PreviousStatus, &data, &created); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &e.Data); err != nil {
+			return nil, fmt.Errorf("event %d: %w", e.ID, err)
+		}
+		e.OccurredAt = parseTime(created)
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
@@ -8,56 +8,192 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/kacperfilipiuk/zguba-gov/internal/finder"
+	"github.com/kacperfilipiuk/zguba-gov/internal/geo"
+	"github.com/kacperfilipiuk/zguba-gov/internal/i18n"
 	"github.com/kacperfilipiuk/zguba-gov/internal/model"
 )
 
+// ErrFinderDisabled is returned when finder data is submitted but no
+// encryption key is configured.
+var ErrFinderDisabled = i18n.NewError("finder.disabled")
+
 type FoundItemRepo struct {
-	db *sql.DB
+	db     *sql.DB
+	finder *finder.Cipher
 }
 
-func NewFoundItemRepo(db *sql.DB) *FoundItemRepo {
-	return &FoundItemRepo{db: db}
+// NewFoundItemRepo returns the item repository. finderCipher may be nil, in
+// which case items cannot carry finder data.
+func NewFoundItemRepo(db *sql.DB, finderCipher *finder.Cipher) *FoundItemRepo {
+	return &FoundItemRepo{db: db, finder: finderCipher}
 }
 
+// ItemColumns is the column list scanned into model.FoundItem. The finder
+// columns are deliberately not part of it, so finder data cannot leak
+// through listings, OData or exports.
+const ItemColumns = "id, municipality_name, municipality_type, municipality_email, item_name, item_category, item_date, item_location, item_status, item_description, pickup_deadline, pickup_location, pickup_hours, pickup_contact, categories, attributes, created_at, updated_at, item_lat, item_lon, pickup_lat, pickup_lon"
+
 func (r *FoundItemRepo) List(p model.ListParams) ([]model.FoundItem, error) {
-	query := "SELECT id, municipality_name, municipality_type, municipality_email, item_name, item_category, item_date, item_location, item_status, item_description, pickup_deadline, pickup_location, pickup_hours, pickup_contact, categories, created_at, updated_at FROM found_items WHERE 1=1"
+	query, args := listQuery(p)
+	return r.queryItems(query, args...)
+}
+
+// eachBatchSize bounds how many rows Each holds in memory and how long it
+// keeps the single database connection busy between callbacks.
+const eachBatchSize = 500
+
+// Each streams every item matching the filters in p to fn in insertion
+// order, ignoring p.Skip and p.Limit. Rows are fetched in batches so the
+// database stays available to other requests while fn runs. Iteration stops
+// at the first error returned by fn.
+func (r *FoundItemRepo) Each(p model.ListParams, fn func(model.FoundItem) error) error {
+	where, args := listFilter(p)
+	var lastRowID int64
+
+	for {
+		batch, err := r.eachBatch(where, args, lastRowID)
+		if err != nil {
+			return err
+		}
+		for _, b := range batch {
+			if err := fn(b.item); err != nil {
+				return err
+			}
+			lastRowID = b.rowID
+		}
+		if len(batch) < eachBatchSize {
+			return nil
+		}
+	}
+}
+
+type batchItem struct {
+	rowID int64
+	item  model.FoundItem
+}
+
+func (r *FoundItemRepo) eachBatch(where string, args []any, afterRowID int64) ([]batchItem, error) {
+	query := "SELECT rowid, " + ItemColumns + " FROM found_items WHERE " + where + " AND rowid > ? ORDER BY rowid LIMIT ?"
+	rows, err := r.db.Query(query, append(args[:len(args):len(args)], afterRowID, eachBatchSize)...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var batch []batchItem
+	for rows.Next() {
+		var b batchItem
+		if b.item, err = scanItem(rows, &b.rowID); err != nil {
+			return nil, err
+		}
+		batch = append(batch, b)
+	}
+	return batch, rows.Err()
+}
+
+func listQuery(p model.ListParams) (string, []any) {
+	where, args := listFilter(p)
+	query := "SELECT " + ItemColumns + " FROM found_items WHERE " + where + " ORDER BY created_at DESC"
+
+	if p.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", p.Limit)
+	}
+	if p.Skip > 0 {
+		query += fmt.Sprintf(" OFFSET %d", p.Skip)
+	}
+
+	return query, args
+}
+
+func listFilter(p model.ListParams) (string, []any) {
+	where := "1=1"
 	var args []any
 
 	if p.Category != "" {
-		query += " AND item_category = ?"
+		// A parent category also matches items in its subcategories.
+		where += ` AND item_category IN (
+			WITH RECURSIVE sub(code) AS (
+				SELECT ? UNION SELECT c.code FROM categories c JOIN sub ON c.parent_code = sub.code
+			) SELECT code FROM sub)`
 		args = append(args, p.Category)
 	}
 	if p.Municipality != "" {
-		query += " AND LOWER(municipality_name) LIKE LOWER(?)"
+		where += " AND LOWER(municipality_name) LIKE LOWER(?)"
 		args = append(args, "%"+p.Municipality+"%")
 	}
+	if p.Office != "" {
+		where += " AND LOWER(municipality_email) = LOWER(?)"
+		args = append(args, p.Office)
+	}
+	if p.Stale {
+		where += ` AND EXISTS (SELECT 1 FROM stale_units s WHERE s.municipality_name = found_items.municipality_name
+			AND s.municipality_type = found_items.municipality_type AND s.municipality_email = LOWER(found_items.municipality_email))`
+	}
 	if p.Status != "" {
-		query += " AND item_status = ?"
+		where += " AND item_status = ?"
 		args = append(args, p.Status)
 	}
+	if p.DateFrom != "" {
+		where += " AND item_date >= ?"
+		args = append(args, p.DateFrom)
+	}
+	if p.DateTo != "" {
+		where += " AND item_date <= ?"
+		args = append(args, p.DateTo)
+	}
+	if p.Located {
+		where += " AND item_lat IS NOT NULL AND item_lon IS NOT NULL"
+	}
+	if p.BBox != nil {
+		cond, condArgs := geo.BBoxSQL("item_lat", "item_lon", *p.BBox)
+		where += " AND " + cond
+		args = append(args, condArgs...)
+	}
+	if p.Near != nil {
+		cond, condArgs := geo.WithinSQL("item_lat", "item_lon", p.Near.Point, p.Near.Radius)
+		where += " AND " + cond
+		args = append(args, condArgs...)
+	}
 	if p.Search != "" {
-		query += " AND (LOWER(item_name) LIKE LOWER(?) OR LOWER(item_description) LIKE LOWER(?) OR LOWER(item_location) LIKE LOWER(?))"
+		where += " AND (LOWER(item_name) LIKE LOWER(?) OR LOWER(item_description) LIKE LOWER(?) OR LOWER(item_location) LIKE LOWER(?))"
 		s := "%" + p.Search + "%"
 		args = append(args, s, s, s)
 	}
 
-	query += " ORDER BY created_at DESC"
+	return where, args
+}
 
-	if p.Limit > 0 {
-		query += fmt.Sprintf(" LIMIT %d", p.Limit)
-	}
-	if p.Skip > 0 {
-		query += fmt.Sprintf(" OFFSET %d", p.Skip)
+// MapCells groups the located items matching p into a grid of cells of
+// cellLon by cellLat degrees, at most limit of them, largest first.
+func (r *FoundItemRepo) MapCells(p model.ListParams, cellLon, cellLat float64, limit int) ([]model.MapCell, error) {
+	p.Located = true
+	where, args := listFilter(p)
+	// In a cell of one item the bare columns are that item's.
+	query := `SELECT COUNT(*), AVG(item_lat), AVG(item_lon), MIN(item_lat) = MAX(item_lat) AND MIN(item_lon) = MAX(item_lon),
+		id, item_name, item_category, item_date
+		FROM found_items WHERE ` + where + `
+		GROUP BY CAST((item_lon + 180) / ? AS INTEGER), CAST((item_lat + 90) / ? AS INTEGER)
+		ORDER BY COUNT(*) DESC LIMIT ?`
+	rows, err := r.db.Query(query, append(args, cellLon, cellLat, limit)...)
+	if err != nil {
+		return nil, err
 	}
+	defer func() { _ = rows.Close() }()
 
-	return r.queryItems(query, args...)
+	var cells []model.MapCell
+	for rows.Next() {
+		var c model.MapCell
+		if err := rows.Scan(&c.Count, &c.Point.Lat, &c.Point.Lon, &c.Stacked, &c.ID, &c.Name, &c.Category, &c.Date); err != nil {
+			return nil, err
+		}
+		cells = append(cells, c)
+	}
+	return cells, rows.Err()
 }
 
 func (r *FoundItemRepo) GetByID(id string) (*model.FoundItem, error) {
-	items, err := r.queryItems(
-		"SELECT id, municipality_name, municipality_type, municipality_email, item_name, item_category, item_date, item_location, item_status, item_description, pickup_deadline, pickup_location, pickup_hours, pickup_contact, categories, created_at, updated_at FROM found_items WHERE id = ?",
-		id,
-	)
+	items, err := r.queryItems("SELECT "+ItemColumns+" FROM found_items WHERE id = ?", id)
 	if err != nil {
 		return nil, err
 	}
@@ -68,35 +204,84 @@ func (r *FoundItemRepo) GetByID(id string) (*model.FoundItem, error) {
 }
 
 func (r *FoundItemRepo) Create(c model.FoundItemCreate) (*model.FoundItem, error) {
+	id, err := r.insertItem(r.db, c)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetByID(id)
+}
+
+// CreateBatch inserts all items in a single transaction and returns their
+// IDs. Either every item is stored or, on the first failure, none of them
+// are.
+func (r *FoundItemRepo) CreateBatch(creates []model.FoundItemCreate) ([]string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	ids := make([]string, 0, len(creates))
+	for i, c := range creates {
+		id, err := r.insertItem(tx, c)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i+1, err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit: %w", err)
+	}
+	return ids, nil
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func (r *FoundItemRepo) insertItem(db execer, c model.FoundItemCreate) (string, error) {
 	id := uuid.New().String()
 	now := time.Now().UTC()
 
+	sealed, err := r.sealFinder(id, c.Finder)
+	if err != nil {
+		return "", err
+	}
+
 	catsJSON := "[]"
 	if len(c.Categories) > 0 {
 		b, _ := json.Marshal(c.Categories)
 		catsJSON = string(b)
 	}
 
+	var attrsJSON sql.NullString
+	if len(c.Attributes) > 0 {
+		b, _ := json.Marshal(c.Attributes)
+		attrsJSON = sql.NullString{String: string(b), Valid: true}
+	}
+
 	status := c.Item.Status
 	if status == "" {
 		status = "available"
 	}
 
-	_, err := r.db.Exec(`
-		INSERT INTO found_items (id, municipality_name, municipality_type, municipality_email, item_name, item_category, item_date, item_location, item_status, item_description, pickup_deadline, pickup_location, pickup_hours, pickup_contact, categories, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+	_, err = db.Exec(`
+		INSERT INTO found_items (`+ItemColumns+`, finder, registered_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		id,
 		c.Municipality.Name, c.Municipality.Type, c.Municipality.ContactEmail,
 		c.Item.Name, c.Item.Category, c.Item.Date, c.Item.Location, status, nullStr(c.Item.Description),
 		c.Pickup.Deadline, c.Pickup.Location, nullStr(c.Pickup.Hours), nullStr(c.Pickup.Contact),
-		catsJSON,
+		catsJSON, attrsJSON,
 		now, now,
+		lat(c.Item.Geo), lon(c.Item.Geo), lat(c.Pickup.Geo), lon(c.Pickup.Geo),
+		sealed, c.Municipality.ContactEmail,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("insert: %w", err)
+		return "", fmt.Errorf("insert: %w", err)
 	}
-
-	return r.GetByID(id)
+	return id, nil
 }
 
 func (r *FoundItemRepo) Update(id string, u model.FoundItemUpdate) (*model.FoundItem, error) {
@@ -116,18 +301,28 @@ func (r *FoundItemRepo) Update(id string, u model.FoundItemUpdate) (*model.Found
 		args = append(args, u.Municipality.Name, u.Municipality.Type, u.Municipality.ContactEmail)
 	}
 	if u.Item != nil {
-		sets = append(sets, "item_name = ?", "item_category = ?", "item_date = ?", "item_location = ?", "item_status = ?", "item_description = ?")
-		args = append(args, u.Item.Name, u.Item.Category, u.Item.Date, u.Item.Location, u.Item.Status, nullStr(u.Item.Description))
+		// An item without a status keeps the current one.
+		status := u.Item.Status
+		if status == "" {
+			status = existing.ItemStatus
+		}
+		sets = append(sets, "item_name = ?", "item_category = ?", "item_date = ?", "item_location = ?", "item_status = ?", "item_description = ?", "item_lat = ?", "item_lon = ?")
+		args = append(args, u.Item.Name, u.Item.Category, u.Item.Date, u.Item.Location, status, nullStr(u.Item.Description), lat(u.Item.Geo), lon(u.Item.Geo))
 	}
 	if u.Pickup != nil {
-		sets = append(sets, "pickup_deadline = ?", "pickup_location = ?", "pickup_hours = ?", "pickup_contact = ?")
-		args = append(args, u.Pickup.Deadline, u.Pickup.Location, nullStr(u.Pickup.Hours), nullStr(u.Pickup.Contact))
+		sets = append(sets, "pickup_deadline = ?", "pickup_location = ?", "pickup_hours = ?", "pickup_contact = ?", "pickup_lat = ?", "pickup_lon = ?")
+		args = append(args, u.Pickup.Deadline, u.Pickup.Location, nullStr(u.Pickup.Hours), nullStr(u.Pickup.Contact), lat(u.Pickup.Geo), lon(u.Pickup.Geo))
 	}
 	if u.Categories != nil {
 		b, _ := json.Marshal(*u.Categories)
 		sets = append(sets, "categories = ?")
 		args = append(args, string(b))
 	}
+	if u.Attributes != nil {
+		b, _ := json.Marshal(*u.Attributes)
+		sets = append(sets, "attributes = ?")
+		args = append(args, string(b))
+	}
 
 	if len(sets) == 0 {
 		return existing, nil
@@ -145,6 +340,125 @@ func (r *FoundItemRepo) Update(id string, u model.FoundItemUpdate) (*model.Found
 	return r.GetByID(id)
 }
 
+// RegisteredBy returns the e-mail address of the office that registered an
+// item. Unlike the contact address of the item it never changes, so it
+// decides who may access the finder data. It returns sql.ErrNoRows when the
+// item does not exist.
+func (r *FoundItemRepo) RegisteredBy(id string) (string, error) {
+	var office sql.NullString
+	if err := r.db.QueryRow("SELECT registered_by FROM found_items WHERE id = ?", id).Scan(&office); err != nil {
+		return "", err
+	}
+	return office.String, nil
+}
+
+// SetFinder replaces the finder data of an item; empty data erases it. It
+// returns sql.ErrNoRows when the item does not exist.
+func (r *FoundItemRepo) SetFinder(id string, f *model.Finder) error {
+	sealed, err := r.sealFinder(id, f)
+	if err != nil {
+		return err
+	}
+	result, err := r.db.Exec("UPDATE found_items SET finder = ?, finder_anonymised_at = NULL, updated_at = ? WHERE id = ?", sealed, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("update finder: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetFinder returns the decrypted finder data of an item, or nil when the
+// item does not exist.
+func (r *FoundItemRepo) GetFinder(id string) (*model.FinderResponse, error) {
+	var sealed, anonymisedAt sql.NullString
+	err := r.db.QueryRow("SELECT finder, finder_anonymised_at FROM found_items WHERE id = ?", id).Scan(&sealed, &anonymisedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &model.FinderResponse{ItemID: id}
+	if anonymisedAt.Valid {
+		resp.AnonymisedAt = parseTime(anonymisedAt.String).Format(time.RFC3339)
+	}
+	if !sealed.Valid {
+		return resp, nil
+	}
+	if r.finder == nil {
+		return nil, ErrFinderDisabled
+	}
+	plain, err := r.finder.Open(sealed.String, []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt finder of %s: %w", id, err)
+	}
+	resp.Finder = &model.Finder{}
+	if err := json.Unmarshal(plain, resp.Finder); err != nil {
+		return nil, fmt.Errorf("decode finder of %s: %w", id, err)
+	}
+	return resp, nil
+}
+
+// EraseFinder removes the finder data of an item, keeping a record of when
+// it was erased. It returns sql.ErrNoRows when the item does not exist.
+func (r *FoundItemRepo) EraseFinder(id string) error {
+	now := time.Now().UTC()
+	result, err := r.db.Exec("UPDATE found_items SET finder = NULL, finder_anonymised_at = ?, updated_at = ? WHERE id = ?", now, now, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// AnonymiseFinders erases finder data of items registered before the
+// given time and returns how many items were affected.
+func (r *FoundItemRepo) AnonymiseFinders(before time.Time) (int64, error) {
+	result, err := r.db.Exec(
+		"UPDATE found_items SET finder = NULL, finder_anonymised_at = ? WHERE finder IS NOT NULL AND created_at < ?",
+		time.Now().UTC(), before.UTC(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// sealFinder encrypts f for the item id. Empty finder data maps to NULL.
+func (r *FoundItemRepo) sealFinder(id string, f *model.Finder) (sql.NullString, error) {
+	if f.IsEmpty() {
+		return sql.NullString{}, nil
+	}
+	if r.finder == nil {
+		return sql.NullString{}, ErrFinderDisabled
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	sealed, err := r.finder.Seal(b, []byte(id))
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("encrypt finder: %w", err)
+	}
+	return sql.NullString{String: sealed, Valid: true}, nil
+}
+
+func (r *FoundItemRepo) SetStatus(id, status string) (*model.FoundItem, error) {
+	result, err := r.db.Exec("UPDATE found_items SET item_status = ?, updated_at = ? WHERE id = ?", status, time.Now().UTC(), id)
+	if err != nil {
+		return nil, fmt.Errorf("update status: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	return r.GetByID(id)
+}
+
 func (r *FoundItemRepo) Delete(id string) error {
 	result, err := r.db.Exec("DELETE FROM found_items WHERE id = ?", id)
 	if err != nil {
@@ -157,8 +471,13 @@ func (r *FoundItemRepo) Delete(id string) error {
 	return nil
 }
 
+// Categories returns the categories that items are registered under,
+// labelled from the taxonomy.
 func (r *FoundItemRepo) Categories() ([]map[string]string, error) {
-	rows, err := r.db.Query("SELECT DISTINCT item_category FROM found_items ORDER BY item_category")
+	rows, err := r.db.Query(`
+		SELECT DISTINCT f.item_category, COALESCE(c.label_pl, '')
+		FROM found_items f LEFT JOIN categories c ON c.code = f.item_category
+		ORDER BY COALESCE(c.label_pl, f.item_category)`)
 	if err != nil {
 		return nil, err
 	}
@@ -166,13 +485,12 @@ func (r *FoundItemRepo) Categories() ([]map[string]string, error) {
 
 	var result []map[string]string
 	for rows.Next() {
-		var cat string
-		if err := rows.Scan(&cat); err != nil {
+		var cat, label string
+		if err := rows.Scan(&cat, &label); err != nil {
 			return nil, err
 		}
-		label := cat
-		if len(label) > 0 {
-			label = strings.ToUpper(label[:1]) + label[1:]
+		if label == "" {
+			label = model.Capitalize(cat)
 		}
 		result = append(result, map[string]string{"value": cat, "label": label})
 	}
@@ -228,6 +546,72 @@ func (r *FoundItemRepo) Stats() (*model.StatsResponse, error) {
 	return stats, nil
 }
 
+// Summary returns the item count, the earliest created_at and the latest
+// updated_at of the items matching p.
+func (r *FoundItemRepo) Summary(p model.ListParams) (model.DatasetSummary, error) {
+	where, args := listFilter(p)
+	query := "SELECT COUNT(*), COALESCE(MIN(created_at), ''), COALESCE(MAX(updated_at), '') FROM found_items"
+	if where != "" {
+		query += " WHERE " + where
+	}
+	var s model.DatasetSummary
+	var issued, modified string
+	if err := r.db.QueryRow(query, args...).Scan(&s.Count, &issued, &modified); err != nil {
+		return s, err
+	}
+	if s.Count > 0 {
+		s.Issued, s.Modified = parseTime(issued), parseTime(modified)
+	}
+	return s, nil
+}
+
+// OfficeSummaries returns a summary per office e-mail address, ordered by
+// municipality name.
+func (r *FoundItemRepo) OfficeSummaries() ([]model.OfficeSummary, error) {
+	rows, err := r.db.Query(`
+		SELECT MAX(municipality_name), MAX(municipality_type), LOWER(municipality_email),
+			COUNT(*), MIN(created_at), MAX(updated_at)
+		FROM found_items GROUP BY LOWER(municipality_email) ORDER BY 1`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []model.OfficeSummary
+	for rows.Next() {
+		var s model.OfficeSummary
+		var issued, modified string
+		if err := rows.Scan(&s.Name, &s.Type, &s.Email, &s.Count, &issued, &modified); err != nil {
+			return nil, err
+		}
+		s.Issued, s.Modified = parseTime(issued), parseTime(modified)
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// MunicipalityRefs returns the distinct municipalities found items refer
+// to.
+func (r *FoundItemRepo) MunicipalityRefs() ([]model.MunicipalityRef, error) {
+	rows, err := r.db.Query(`
+		SELECT DISTINCT municipality_name, municipality_type, LOWER(municipality_email)
+		FROM found_items ORDER BY 1, 2, 3`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []model.MunicipalityRef
+	for rows.Next() {
+		var m model.MunicipalityRef
+		if err := rows.Scan(&m.Name, &m.Type, &m.Email); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
 func (r *FoundItemRepo) Count(where string, args ...any) (int, error) {
 	query := "SELECT COUNT(*) FROM found_items"
 	if where != "" {
@@ -238,6 +622,11 @@ func (r *FoundItemRepo) Count(where string, args ...any) (int, error) {
 	return count, err
 }
 
+func (r *FoundItemRepo) CountList(p model.ListParams) (int, error) {
+	where, args := listFilter(p)
+	return r.Count(where, args...)
+}
+
 func (r *FoundItemRepo) QueryRaw(query string, args ...any) ([]model.FoundItem, error) {
 	return r.queryItems(query, args...)
 }
@@ -251,34 +640,66 @@ func (r *FoundItemRepo) queryItems(query string, args ...any) ([]model.FoundItem
 
 	var items []model.FoundItem
 	for rows.Next() {
-		var fi model.FoundItem
-		var createdStr, updatedStr string
-		if err := rows.Scan(
-			&fi.ID, &fi.MunicipalityName, &fi.MunicipalityType, &fi.MunicipalityEmail,
-			&fi.ItemName, &fi.ItemCategory, &fi.ItemDate, &fi.ItemLocation,
-			&fi.ItemStatus, &fi.ItemDescription,
-			&fi.PickupDeadline, &fi.PickupLocation, &fi.PickupHours, &fi.PickupContact,
-			&fi.Categories,
-			&createdStr, &updatedStr,
-		); err != nil {
+		fi, err := scanItem(rows)
+		if err != nil {
 			return nil, err
 		}
-		fi.CreatedAt, _ = time.Parse("2006-01-02 15:04:05", createdStr)
-		fi.UpdatedAt, _ = time.Parse("2006-01-02 15:04:05", updatedStr)
-		if fi.CreatedAt.IsZero() {
-			fi.CreatedAt, _ = time.Parse(time.RFC3339, createdStr)
-		}
-		if fi.UpdatedAt.IsZero() {
-			fi.UpdatedAt, _ = time.Parse(time.RFC3339, updatedStr)
-		}
 		items = append(items, fi)
 	}
 	return items, rows.Err()
 }
 
+// scanItem scans a row selected with ItemColumns. Extra destinations are
+// filled from columns selected before ItemColumns.
+func scanItem(rows *sql.Rows, extra ...any) (model.FoundItem, error) {
+	var fi model.FoundItem
+	var createdStr, updatedStr string
+	if err := rows.Scan(append(extra,
+		&fi.ID, &fi.MunicipalityName, &fi.MunicipalityType, &fi.MunicipalityEmail,
+		&fi.ItemName, &fi.ItemCategory, &fi.ItemDate, &fi.ItemLocation,
+		&fi.ItemStatus, &fi.ItemDescription,
+		&fi.PickupDeadline, &fi.PickupLocation, &fi.PickupHours, &fi.PickupContact,
+		&fi.Categories, &fi.Attributes,
+		&createdStr, &updatedStr,
+		&fi.ItemLat, &fi.ItemLon, &fi.PickupLat, &fi.PickupLon,
+	)...); err != nil {
+		return fi, err
+	}
+	fi.CreatedAt = parseTime(createdStr)
+	fi.UpdatedAt = parseTime(updatedStr)
+	return fi, nil
+}
+
+func parseTime(s string) time.Time {
+	t, _ := time.Parse("2006-01-02 15:04:05", s)
+	if t.IsZero() {
+		t, _ = time.Parse(time.RFC3339, s)
+	}
+	if t.IsZero() {
+		// time.Time values bound as parameters are stored in their
+		// String() form, which is what aggregates like MAX() return.
+		t, _ = time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", s)
+	}
+	return t
+}
+
 func nullStr(s string) sql.NullString {
 	if s == "" {
 		return sql.NullString{}
 	}
 	return sql.NullString{String: s, Valid: true}
 }
+
+func lat(p *model.GeoPoint) sql.NullFloat64 {
+	if p == nil {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: p.Lat, Valid: true}
+}
+
+func lon(p *model.GeoPoint) sql.NullFloat64 {
+	if p == nil {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: p.Lon, Valid: true}
+}
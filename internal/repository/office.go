@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+)
+
+// OfficeRepo stores the office directory: contact details of the offices
+// of territorial units, keyed by TERYT code.
+type OfficeRepo struct {
+	db *sql.DB
+}
+
+func NewOfficeRepo(db *sql.DB) *OfficeRepo {
+	return &OfficeRepo{db: db}
+}
+
+const officeColumns = "teryt, name, email, email_verified, epuap, phone, address, hours, updated_at"
+
+func (r *OfficeRepo) List() ([]model.OfficeContact, error) {
+	return r.query("SELECT " + officeColumns + " FROM offices ORDER BY teryt")
+}
+
+// Get returns the directory entry of a unit, or nil when it has none.
+func (r *OfficeRepo) Get(teryt string) (*model.OfficeContact, error) {
+	offices, err := r.query("SELECT "+officeColumns+" FROM offices WHERE teryt = ?", teryt)
+	if err != nil || len(offices) == 0 {
+		return nil, err
+	}
+	return &offices[0], nil
+}
+
+// ByEmail returns the entries with the given e-mail address.
+func (r *OfficeRepo) ByEmail(email string) ([]model.OfficeContact, error) {
+	return r.query("SELECT "+officeColumns+" FROM offices WHERE LOWER(email) = ? ORDER BY teryt", normalizeEmail(email))
+}
+
+// Save creates or replaces directory entries in one transaction.
+func (r *OfficeRepo) Save(offices ...model.OfficeContact) error {
+	now := time.Now().UTC()
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, o := range offices {
+		if _, err := tx.Exec(`
+			INSERT INTO offices (`+officeColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(teryt) DO UPDATE SET name = excluded.name, email = excluded.email,
+				email_verified = excluded.email_verified, epuap = excluded.epuap, phone = excluded.phone,
+				address = excluded.address, hours = excluded.hours, updated_at = excluded.updated_at`,
+			o.Teryt, o.Name, normalizeEmail(o.Email), o.EmailVerified, o.EPUAP, o.Phone, o.Address, o.Hours, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Delete removes a directory entry. It returns sql.ErrNoRows when the unit
+// has none.
+func (r *OfficeRepo) Delete(teryt string) error {
+	result, err := r.db.Exec("DELETE FROM offices WHERE teryt = ?", teryt)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *OfficeRepo) query(query string, args ...any) ([]model.OfficeContact, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	out := []model.OfficeContact{}
+	for rows.Next() {
+		o := model.OfficeContact{Source: model.ContactDirectory}
+		var updated string
+		if err := rows.Scan(&o.Teryt, &o.Name, &o.Email, &o.EmailVerified, &o.EPUAP, &o.Phone, &o.Address, &o.Hours, &updated); err != nil {
+			return nil, err
+		}
+		o.UpdatedAt = parseTime(updated)
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
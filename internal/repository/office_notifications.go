@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+)
+
+type OfficeNotificationsRepo struct {
+	db *sql.DB
+}
+
+func NewOfficeNotificationsRepo(db *sql.DB) *OfficeNotificationsRepo {
+	return &OfficeNotificationsRepo{db: db}
+}
+
+// Get returns the settings of an office. Offices that never saved settings
+// get everything switched off.
+func (r *OfficeNotificationsRepo) Get(email string) (model.OfficeNotifications, error) {
+	s := model.OfficeNotifications{Email: normalizeEmail(email)}
+	var updated string
+	err := r.db.QueryRow(
+		"SELECT item_registered, claim_submitted, deadline_approaching, updated_at FROM office_notifications WHERE email = ?",
+		s.Email,
+	).Scan(&s.ItemRegistered, &s.ClaimSubmitted, &s.DeadlineApproaching, &updated)
+	if err == sql.ErrNoRows {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	s.UpdatedAt = parseTime(updated)
+	return s, nil
+}
+
+func (r *OfficeNotificationsRepo) Save(s *model.OfficeNotifications) error {
+	s.Email = normalizeEmail(s.Email)
+	s.UpdatedAt = time.Now().UTC()
+	_, err := r.db.Exec(`
+		INSERT INTO office_notifications (email, item_registered, claim_submitted, deadline_approaching, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(email) DO UPDATE SET
+			item_registered = excluded.item_registered,
+			claim_submitted = excluded.claim_submitted,
+			deadline_approaching = excluded.deadline_approaching,
+			updated_at = excluded.updated_at`,
+		s.Email, s.ItemRegistered, s.ClaimSubmitted, s.DeadlineApproaching, s.UpdatedAt,
+	)
+	return err
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
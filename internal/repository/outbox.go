@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+)
+
+type OutboxRepo struct {
+	db *sql.DB
+}
+
+func NewOutboxRepo(db *sql.DB) *OutboxRepo {
+	return &OutboxRepo{db: db}
+}
+
+const outboxColumns = "id, kind, COALESCE(dedupe_key, ''), recipient, COALESCE(reply_to, ''), subject, body, status, attempts, next_attempt_at, COALESCE(last_error, ''), created_at, COALESCE(sent_at, '')"
+
+// Enqueue stores a pending message due immediately. A message whose dedupe
+// key was already used is dropped and Enqueue reports false.
+func (r *OutboxRepo) Enqueue(m *model.OutboxMessage) (bool, error) {
+	now := time.Now().UTC()
+	result, err := r.db.Exec(`
+		INSERT INTO outbox (kind, dedupe_key, recipient, reply_to, subject, body, status, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(dedupe_key) DO NOTHING`,
+		m.Kind, nullStr(m.DedupeKey), m.Recipient, nullStr(m.ReplyTo), m.Subject, m.Body, model.OutboxPending, now, now,
+	)
+	if err != nil {
+		return false, fmt.Errorf("enqueue: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	m.ID, _ = result.LastInsertId()
+	m.Status = model.OutboxPending
+	m.NextAttemptAt, m.CreatedAt = now, now
+	return true, nil
+}
+
+// Due returns up to limit pending messages whose next attempt is due.
+func (r *OutboxRepo) Due(now time.Time, limit int) ([]model.OutboxMessage, error) {
+	return r.query("SELECT "+outboxColumns+" FROM outbox WHERE status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at LIMIT ?",
+		model.OutboxPending, now.UTC(), limit)
+}
+
+// List returns the newest messages, optionally only those with status.
+func (r *OutboxRepo) List(status string, limit int) ([]model.OutboxMessage, error) {
+	where := "1=1"
+	var args []any
+	if status != "" {
+		where = "status = ?"
+		args = append(args, status)
+	}
+	args = append(args, limit)
+	return r.query("SELECT "+outboxColumns+" FROM outbox WHERE "+where+" ORDER BY id DESC LIMIT ?", args...)
+}
+
+func (r *OutboxRepo) MarkSent(id int64) error {
+	now := time.Now().UTC()
+	_, err := r.db.Exec("UPDATE outbox SET status = ?, attempts = attempts + 1, sent_at = ?, last_error = NULL WHERE id = ?",
+		model.OutboxSent, now, id)
+	return err
+}
+
+// MarkFailed records a failed attempt. The message is retried at next, or
+// given up on when final is set.
+func (r *OutboxRepo) MarkFailed(id int64, cause error, next time.Time, final bool) error {
+	status := model.OutboxPending
+	if final {
+		status = model.OutboxFailed
+	}
+	_, err := r.db.Exec("UPDATE outbox SET status = ?, attempts = attempts + 1, next_attempt_at = ?, last_error = ? WHERE id = ?",
+		status, next.UTC(), cause.Error(), id)
+	return err
+}
+
+// Retry makes a failed message pending again with a fresh attempt budget.
+// It returns sql.ErrNoRows when no failed message has the id.
+func (r *OutboxRepo) Retry(id int64) error {
+	result, err := r.db.Exec("UPDATE outbox SET status = ?, attempts = 0, next_attempt_at = ? WHERE id = ? AND status = ?",
+		model.OutboxPending, time.Now().UTC(), id, model.OutboxFailed)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// PurgeSent deletes delivered messages older than before. Their bodies may
+// contain personal data of claimants, so they are not kept indefinitely.
+// Dedupe keys of deleted messages can be reused.
+func (r *OutboxRepo) PurgeSent(before time.Time) (int64, error) {
+	result, err := r.db.Exec("DELETE FROM outbox WHERE status = ? AND sent_at < ?", model.OutboxSent, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *OutboxRepo) query(query string, args ...any) ([]model.OutboxMessage, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var msgs []model.OutboxMessage
+	for rows.Next() {
+		var m model.OutboxMessage
+		var next, created, sent string
+		if err := rows.Scan(&m.ID, &m.Kind, &m.DedupeKey, &m.Recipient, &m.ReplyTo, &m.Subject, &m.Body,
+			&m.Status, &m.Attempts, &next, &m.LastError, &created, &sent); err != nil {
+			return nil, err
+		}
+		m.NextAttemptAt = parseTime(next)
+		m.CreatedAt = parseTime(created)
+		if strings.TrimSpace(sent) != "" {
+			m.SentAt = parseTime(sent)
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, rows.Err()
+}
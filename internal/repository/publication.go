@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+)
+
+// PublicationRepo stores which dane.gov.pl dataset each office publishes
+// to and the sync state of its resources.
+type PublicationRepo struct {
+	db *sql.DB
+}
+
+func NewPublicationRepo(db *sql.DB) *PublicationRepo {
+	return &PublicationRepo{db: db}
+}
+
+func (r *PublicationRepo) List() ([]model.Publication, error) {
+	return r.query("SELECT office, dataset_id, created_at, updated_at FROM publications ORDER BY office")
+}
+
+// Get returns the publication of an office, or nil when it has none.
+func (r *PublicationRepo) Get(office string) (*model.Publication, error) {
+	pubs, err := r.query("SELECT office, dataset_id, created_at, updated_at FROM publications WHERE office = ?", normalizeEmail(office))
+	if err != nil || len(pubs) == 0 {
+		return nil, err
+	}
+	return &pubs[0], nil
+}
+
+// Save links office to a remote dataset. Moving an office to another
+// dataset forgets its remote resources, so they are created again there.
+func (r *PublicationRepo) Save(office, datasetID string) (*model.Publication, error) {
+	office = normalizeEmail(office)
+	now := time.Now().UTC()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var current string
+	err = tx.QueryRow("SELECT dataset_id FROM publications WHERE office = ?", office).Scan(&current)
+	switch {
+	case err == sql.ErrNoRows:
+		if _, err := tx.Exec("INSERT INTO publications (office, dataset_id, created_at, updated_at) VALUES (?, ?, ?, ?)",
+			office, datasetID, now, now); err != nil {
+			return nil, fmt.Errorf("insert publication: %w", err)
+		}
+	case err != nil:
+		return nil, err
+	case current != datasetID:
+		if _, err := tx.Exec("UPDATE publications SET dataset_id = ?, updated_at = ? WHERE office = ?", datasetID, now, office); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec("DELETE FROM published_resources WHERE office = ?", office); err != nil {
+			return nil, err
+		}
+	}
+	for _, kind := range model.ResourceKinds {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO published_resources (office, kind, next_attempt_at) VALUES (?, ?, ?)",
+			office, kind, now); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.Get(office)
+}
+
+// Delete removes the publication of an office. The remote dataset is left
+// alone. It returns sql.ErrNoRows when the office has no publication.
+func (r *PublicationRepo) Delete(office string) error {
+	result, err := r.db.Exec("DELETE FROM publications WHERE office = ?", normalizeEmail(office))
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// MarkSynced records a successful push of the items changed up to
+// modified. The resource is not pushed again before next.
+func (r *PublicationRepo) MarkSynced(office, kind, resourceID string, modified, next time.Time) error {
+	now := time.Now().UTC()
+	_, err := r.db.Exec(`
+		UPDATE published_resources SET resource_id = ?, synced_modified = ?, synced_at = ?,
+			attempts = 0, last_error = NULL, next_attempt_at = ?
+		WHERE office = ? AND kind = ?`,
+		resourceID, modified.UTC(), now, next.UTC(), office, kind)
+	return err
+}
+
+// MarkFailed records a failed push, to be retried at next. forget clears
+// the remote resource ID, for resources that no longer exist remotely.
+func (r *PublicationRepo) MarkFailed(office, kind string, cause error, next time.Time, forget bool) error {
+	query := "UPDATE published_resources SET attempts = attempts + 1, last_error = ?, next_attempt_at = ?"
+	if forget {
+		query += ", resource_id = NULL"
+	}
+	_, err := r.db.Exec(query+" WHERE office = ? AND kind = ?", cause.Error(), next.UTC(), office, kind)
+	return err
+}
+
+func (r *PublicationRepo) query(query string, args ...any) ([]model.Publication, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var pubs []model.Publication
+	for rows.Next() {
+		var p model.Publication
+		var created, updated string
+		if err := rows.Scan(&p.Office, &p.DatasetID, &created, &updated); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		p.CreatedAt, p.UpdatedAt = parseTime(created), parseTime(updated)
+		pubs = append(pubs, p)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// The connection pool has a single connection, so resources are read
+	// after the publication rows are closed.
+	for i := range pubs {
+		if pubs[i].Resources, err = r.resources(pubs[i].Office); err != nil {
+			return nil, err
+		}
+	}
+	return pubs, nil
+}
+
+func (r *PublicationRepo) resources(office string) ([]model.PublishedResource, error) {
+	rows, err := r.db.Query(`
+		SELECT kind, COALESCE(resource_id, ''), COALESCE(synced_modified, ''), COALESCE(synced_at, ''),
+			attempts, next_attempt_at, COALESCE(last_error, '')
+		FROM published_resources WHERE office = ? ORDER BY kind`, office)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	res := []model.PublishedResource{}
+	for rows.Next() {
+		var p model.PublishedResource
+		var modified, synced, next string
+		if err := rows.Scan(&p.Kind, &p.ResourceID, &modified, &synced, &p.Attempts, &next, &p.LastError); err != nil {
+			return nil, err
+		}
+		p.SyncedModified, p.SyncedAt, p.NextAttemptAt = parseTime(modified), parseTime(synced), parseTime(next)
+		res = append(res, p)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+)
+
+// StaleUnitRepo records municipalities referenced by found items that
+// disappeared from the territorial dataset.
+type StaleUnitRepo struct {
+	db *sql.DB
+}
+
+func NewStaleUnitRepo(db *sql.DB) *StaleUnitRepo {
+	return &StaleUnitRepo{db: db}
+}
+
+// List returns the flagged municipalities with the number of items still
+// referring to them.
+func (r *StaleUnitRepo) List() ([]model.StaleUnit, error) {
+	rows, err := r.db.Query(`
+		SELECT s.municipality_name, s.municipality_type, s.municipality_email, s.dataset_version, s.flagged_at,
+			(SELECT COUNT(*) FROM found_items f WHERE f.municipality_name = s.municipality_name
+				AND f.municipality_type = s.municipality_type AND LOWER(f.municipality_email) = s.municipality_email)
+		FROM stale_units s ORDER BY s.municipality_name, s.municipality_type, s.municipality_email`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	out := []model.StaleUnit{}
+	for rows.Next() {
+		var u model.StaleUnit
+		var flagged string
+		if err := rows.Scan(&u.Name, &u.Type, &u.Email, &u.DatasetVersion, &flagged, &u.Items); err != nil {
+			return nil, err
+		}
+		u.FlaggedAt = parseTime(flagged)
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+// Update flags the municipalities in flag as missing from the dataset
+// version and clears the flags of those in clear. Municipalities already
+// flagged keep their original version and time.
+func (r *StaleUnitRepo) Update(flag, clear []model.MunicipalityRef, version string) error {
+	now := time.Now().UTC()
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, m := range flag {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO stale_units (municipality_name, municipality_type, municipality_email, dataset_version, flagged_at)
+			VALUES (?, ?, ?, ?, ?)`, m.Name, m.Type, normalizeEmail(m.Email), version, now); err != nil {
+			return err
+		}
+	}
+	for _, m := range clear {
+		if _, err := tx.Exec("DELETE FROM stale_units WHERE municipality_name = ? AND municipality_type = ? AND municipality_email = ?",
+			m.Name, m.Type, normalizeEmail(m.Email)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,324 @@
+package repository
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kacperfilipiuk/zguba-gov/internal/i18n"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+)
+
+var (
+	ErrWebhookURL    = i18n.NewError("webhook.invalid_url")
+	ErrWebhookEvents = i18n.NewError("webhook.invalid_events", strings.Join(model.EventTypes, ", "))
+	ErrWebhookSecret = i18n.NewError("webhook.invalid_secret")
+)
+
+// WebhookRepo stores webhook subscriptions and their delivery queue.
+type WebhookRepo struct {
+	db *sql.DB
+}
+
+func NewWebhookRepo(db *sql.DB) *WebhookRepo {
+	return &WebhookRepo{db: db}
+}
+
+const subscriptionColumns = "id, url, secret, events, COALESCE(description, ''), active, created_at, updated_at"
+
+func (r *WebhookRepo) List() ([]model.WebhookSubscription, error) {
+	return r.querySubscriptions("SELECT " + subscriptionColumns + " FROM webhook_subscriptions ORDER BY created_at")
+}
+
+// Get returns the subscription including its secret, or nil when it does
+// not exist.
+func (r *WebhookRepo) Get(id string) (*model.WebhookSubscription, error) {
+	subs, err := r.querySubscriptions("SELECT "+subscriptionColumns+" FROM webhook_subscriptions WHERE id = ?", id)
+	if err != nil || len(subs) == 0 {
+		return nil, err
+	}
+	return &subs[0], nil
+}
+
+func (r *WebhookRepo) Create(c model.WebhookSubscriptionCreate) (*model.WebhookSubscription, error) {
+	if err := checkWebhook(c.URL, c.Events, c.Secret); err != nil {
+		return nil, err
+	}
+	secret := c.Secret
+	if secret == "" {
+		secret = newWebhookSecret()
+	}
+	active := c.Active == nil || *c.Active
+
+	id := uuid.New().String()
+	now := time.Now().UTC()
+	events, _ := json.Marshal(c.Events)
+	if _, err := r.db.Exec(
+		"INSERT INTO webhook_subscriptions (id, url, secret, events, description, active, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		id, c.URL, secret, string(events), nullStr(c.Description), active, now, now,
+	); err != nil {
+		return nil, fmt.Errorf("insert subscription: %w", err)
+	}
+	return r.Get(id)
+}
+
+func (r *WebhookRepo) Update(id string, u model.WebhookSubscriptionUpdate) (*model.WebhookSubscription, error) {
+	existing, err := r.Get(id)
+	if err != nil || existing == nil {
+		return nil, err
+	}
+
+	merged := *existing
+	if u.URL != nil {
+		merged.URL = *u.URL
+	}
+	if u.Events != nil {
+		merged.Events = *u.Events
+	}
+	if u.Description != nil {
+		merged.Description = *u.Description
+	}
+	if u.Secret != nil {
+		if *u.Secret == "" {
+			return nil, ErrWebhookSecret
+		}
+		merged.Secret = *u.Secret
+	}
+	if u.Active != nil {
+		merged.Active = *u.Active
+	}
+	if err := checkWebhook(merged.URL, merged.Events, merged.Secret); err != nil {
+		return nil, err
+	}
+
+	events, _ := json.Marshal(merged.Events)
+	if _, err := r.db.Exec(
+		"UPDATE webhook_subscriptions SET url = ?, secret = ?, events = ?, description = ?, active = ?, updated_at = ? WHERE id = ?",
+		merged.URL, merged.Secret, string(events), nullStr(merged.Description), merged.Active, time.Now().UTC(), id,
+	); err != nil {
+		return nil, fmt.Errorf("update subscription: %w", err)
+	}
+	return r.Get(id)
+}
+
+// Delete removes the subscription together with its deliveries.
+func (r *WebhookRepo) Delete(id string) error {
+	result, err := r.db.Exec("DELETE FROM webhook_subscriptions WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Enqueue schedules delivery of e to every active subscription that asked
+// for its type and reports how many deliveries were queued.
+func (r *WebhookRepo) Enqueue(e model.Event) (int64, error) {
+	now := time.Now().UTC()
+	result, err := r.db.Exec(`
+		INSERT INTO webhook_deliveries (subscription_id, event_id, status, next_attempt_at, created_at)
+		SELECT s.id, ?, ?, ?, ?
+		FROM webhook_subscriptions s
+		WHERE s.active = 1 AND EXISTS (SELECT 1 FROM json_each(s.events) WHERE json_each.value = ?)`,
+		e.ID, model.DeliveryPending, now, now, e.Type,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("enqueue deliveries: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// DueDelivery is a pending delivery with everything needed to send it.
+type DueDelivery struct {
+	model.WebhookDelivery
+	URL    string
+	Secret string
+}
+
+// Due returns up to limit pending deliveries of active subscriptions whose
+// next attempt is due, oldest first.
+func (r *WebhookRepo) Due(now time.Time, limit int) ([]DueDelivery, error) {
+	rows, err := r.db.Query(`
+		SELECT `+deliveryColumns+`, s.url, s.secret
+		FROM webhook_deliveries d
+		JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		JOIN events e ON e.id = d.event_id
+		WHERE d.status = ? AND d.next_attempt_at <= ? AND s.active = 1
+		ORDER BY d.next_attempt_at, d.id
+		LIMIT ?`,
+		model.DeliveryPending, now.UTC(), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var due []DueDelivery
+	for rows.Next() {
+		var d DueDelivery
+		var err error
+		if d.WebhookDelivery, err = scanDelivery(rows, &d.URL, &d.Secret); err != nil {
+			return nil, err
+		}
+		due = append(due, d)
+	}
+	return due, rows.Err()
+}
+
+// ListDeliveries returns the newest deliveries, optionally filtered by
+// status and subscription.
+func (r *WebhookRepo) ListDeliveries(status, subscriptionID string, limit int) ([]model.WebhookDelivery, error) {
+	where := []string{"1=1"}
+	var args []any
+	if status != "" {
+		where = append(where, "d.status = ?")
+		args = append(args, status)
+	}
+	if subscriptionID != "" {
+		where = append(where, "d.subscription_id = ?")
+		args = append(args, subscriptionID)
+	}
+	args = append(args, limit)
+
+	rows, err := r.db.Query(`
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries d JOIN events e ON e.id = d.event_id
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY d.id DESC LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []model.WebhookDelivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func (r *WebhookRepo) MarkDelivered(id int64, responseStatus int) error {
+	_, err := r.db.Exec(
+		"UPDATE webhook_deliveries SET status = ?, attempts = attempts + 1, response_status = ?, last_error = NULL, delivered_at = ? WHERE id = ?",
+		model.DeliveryDelivered, responseStatus, time.Now().UTC(), id)
+	return err
+}
+
+// MarkFailed records a failed attempt. The delivery is retried at next, or
+// moved to the dead letters when final is set. responseStatus is 0 when no
+// response was received.
+func (r *WebhookRepo) MarkFailed(id int64, responseStatus int, cause error, next time.Time, final bool) error {
+	status := model.DeliveryPending
+	if final {
+		status = model.DeliveryDead
+	}
+	var code sql.NullInt64
+	if responseStatus != 0 {
+		code = sql.NullInt64{Int64: int64(responseStatus), Valid: true}
+	}
+	_, err := r.db.Exec(
+		"UPDATE webhook_deliveries SET status = ?, attempts = attempts + 1, response_status = ?, last_error = ?, next_attempt_at = ? WHERE id = ?",
+		status, code, cause.Error(), next.UTC(), id)
+	return err
+}
+
+// Retry makes a dead delivery pending again with a fresh attempt budget.
+// It returns sql.ErrNoRows when no dead delivery has the id.
+func (r *WebhookRepo) Retry(id int64) error {
+	result, err := r.db.Exec("UPDATE webhook_deliveries SET status = ?, attempts = 0, next_attempt_at = ? WHERE id = ? AND status = ?",
+		model.DeliveryPending, time.Now().UTC(), id, model.DeliveryDead)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// PurgeDelivered deletes deliveries that succeeded before the given time.
+func (r *WebhookRepo) PurgeDelivered(before time.Time) (int64, error) {
+	result, err := r.db.Exec("DELETE FROM webhook_deliveries WHERE status = ? AND delivered_at < ?", model.DeliveryDelivered, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deliveryColumns = "d.id, d.subscription_id, d.event_id, e.type, d.status, d.attempts, d.next_attempt_at, COALESCE(d.last_error, ''), COALESCE(d.response_status, 0), d.created_at, COALESCE(d.delivered_at, '')"
+
+// scanDelivery scans deliveryColumns followed by extra destinations.
+func scanDelivery(rows *sql.Rows, extra ...any) (model.WebhookDelivery, error) {
+	var d model.WebhookDelivery
+	var next, created, delivered string
+	dest := append([]any{&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Status, &d.Attempts,
+		&next, &d.LastError, &d.ResponseStatus, &created, &delivered}, extra...)
+	if err := rows.Scan(dest...); err != nil {
+		return d, err
+	}
+	d.NextAttemptAt = parseTime(next)
+	d.CreatedAt = parseTime(created)
+	if strings.TrimSpace(delivered) != "" {
+		d.DeliveredAt = parseTime(delivered)
+	}
+	return d, nil
+}
+
+func (r *WebhookRepo) querySubscriptions(query string, args ...any) ([]model.WebhookSubscription, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var subs []model.WebhookSubscription
+	for rows.Next() {
+		var s model.WebhookSubscription
+		var events, created, updated string
+		if err := rows.Scan(&s.ID, &s.URL, &s.Secret, &events, &s.Description, &s.Active, &created, &updated); err != nil {
+			return nil, err
+		}
+		_ = json.Unmarshal([]byte(events), &s.Events)
+		s.CreatedAt = parseTime(created)
+		s.UpdatedAt = parseTime(updated)
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
+
+func checkWebhook(rawURL string, events []string, secret string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrWebhookURL
+	}
+	if len(events) == 0 {
+		return ErrWebhookEvents
+	}
+	for _, e := range events {
+		if !model.IsEventType(e) {
+			return ErrWebhookEvents
+		}
+	}
+	if secret != "" && len(secret) < 16 {
+		return ErrWebhookSecret
+	}
+	return nil
+}
+
+func newWebhookSecret() string {
+	b := make([]byte, 24)
+	_, _ = rand.Read(b)
+	return "whsec_" + hex.EncodeToString(b)
+}
@@ -0,0 +1,169 @@
+package teryt
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+	"github.com/kacperfilipiuk/zguba-gov/internal/municipality"
+	"github.com/kacperfilipiuk/zguba-gov/internal/repository"
+)
+
+// Status describes the loaded territorial dataset.
+type Status struct {
+	// Source is the TERC file, or "embedded" for the dataset built into
+	// the binary.
+	Source   string    `json:"source"`
+	Version  string    `json:"version"`
+	Units    int       `json:"units"`
+	LoadedAt time.Time `json:"loadedAt,omitzero"`
+	// LastError is why the file could not be loaded the last time it
+	// changed; the previous version stays in use.
+	LastError string `json:"lastError,omitempty"`
+	// LastDiff is what the last load changed.
+	LastDiff *municipality.Diff `json:"lastDiff,omitempty"`
+}
+
+// Loader keeps the territorial dataset in sync with a TERC file. The file
+// is checked every interval and loaded again when it changes.
+type Loader struct {
+	svc      *municipality.Service
+	items    *repository.FoundItemRepo
+	stale    *repository.StaleUnitRepo
+	path     string
+	interval time.Duration
+
+	mu      sync.Mutex
+	status  Status
+	modTime time.Time
+	size    int64
+}
+
+// New returns a loader for the TERC file at path. With an empty path the
+// embedded dataset stays in use and Load does nothing.
+func New(svc *municipality.Service, items *repository.FoundItemRepo, stale *repository.StaleUnitRepo, path string, interval time.Duration) *Loader {
+	source := path
+	if source == "" {
+		source = "embedded"
+	}
+	return &Loader{svc: svc, items: items, stale: stale, path: path, interval: interval, status: Status{Source: source}}
+}
+
+// Status returns the state of the loaded dataset.
+func (l *Loader) Status() Status {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := l.status
+	s.Version, s.Units = l.svc.Version(), l.svc.Len()
+	return s
+}
+
+// Load loads the file if it changed since the last attempt. Found items
+// referring to units that disappeared are flagged; flags of units that are
+// back are cleared.
+func (l *Loader) Load() error {
+	if l.path == "" {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	fi, err := os.Stat(l.path)
+	if err != nil {
+		return l.fail(err)
+	}
+	if fi.ModTime().Equal(l.modTime) && fi.Size() == l.size {
+		return nil
+	}
+	l.modTime, l.size = fi.ModTime(), fi.Size()
+
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return l.fail(err)
+	}
+	version := municipality.Version(data)
+	if version == l.svc.Version() {
+		return nil
+	}
+	units, err := Parse(data)
+	if err != nil {
+		return l.fail(err)
+	}
+
+	refs, err := l.items.MunicipalityRefs()
+	if err != nil {
+		return l.fail(err)
+	}
+	before := make([][]municipality.TerritorialUnit, len(refs))
+	for i, m := range refs {
+		before[i] = l.svc.Match(m.Name, m.Type, m.Email)
+	}
+
+	diff := l.svc.Replace(units, version)
+
+	var flag, clear []model.MunicipalityRef
+	for i, m := range refs {
+		switch {
+		case len(l.svc.Match(m.Name, m.Type, m.Email)) > 0 || l.anyExists(before[i]):
+			clear = append(clear, m)
+		case len(before[i]) > 0:
+			flag = append(flag, m)
+		}
+	}
+	if err := l.stale.Update(flag, clear, version); err != nil {
+		return l.fail(fmt.Errorf("flag stale municipalities: %w", err))
+	}
+
+	l.status.LoadedAt = time.Now().UTC()
+	l.status.LastError = ""
+	l.status.LastDiff = &diff
+	log.Printf("teryt: loaded %s (%d units): %d added, %d removed, %d changed; %d municipalities of found items flagged",
+		l.path, l.svc.Len(), len(diff.Added), len(diff.Removed), len(diff.Changed), len(flag))
+	return nil
+}
+
+// anyExists reports whether one of units is still in the dataset, possibly
+// renamed.
+func (l *Loader) anyExists(units []municipality.TerritorialUnit) bool {
+	for _, u := range units {
+		if _, ok := l.svc.Get(string(u.ID)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *Loader) fail(err error) error {
+	l.status.LastError = err.Error()
+	return err
+}
+
+// Run checks the file every interval until ctx is cancelled.
+func (l *Loader) Run(ctx context.Context) {
+	if l.path == "" {
+		return
+	}
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+	var last string
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// A missing file is reported once, not on every check.
+			err := l.Load()
+			if err != nil && err.Error() != last {
+				log.Printf("teryt: %s: %v", l.path, err)
+			}
+			last = ""
+			if err != nil {
+				last = err.Error()
+			}
+		}
+	}
+}
@@ -0,0 +1,201 @@
+// Package teryt loads the territorial dataset from TERC exports of the
+// TERYT register published by GUS, keeps it current while the server runs
+// and flags found items whose unit has disappeared.
+package teryt
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/municipality"
+)
+
+// ErrSIMC is returned for SIMC exports, which list localities rather than
+// territorial units.
+var ErrSIMC = errors.New("the file is a SIMC export of localities; territorial units are read from TERC")
+
+// Gmina kinds (RODZ) that are units of their own. Kinds 4 and 5 are the
+// town and rural parts of an urban-rural gmina, 8 and 9 districts and
+// delegations of cities.
+var gminaKinds = map[string]bool{"1": true, "2": true, "3": true}
+
+// row is one TERC record. Empty POW means a voivodeship, empty GMI a
+// county.
+type row struct {
+	woj, pow, gmi, rodz, name string
+}
+
+// Parse reads a TERC export, either the XML or the semicolon-separated CSV
+// from the TERYT site, and returns its voivodeships, counties and gminas.
+// Cities with county rights are returned once, as "miasto".
+func Parse(data []byte) ([]municipality.TerritorialUnit, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	var (
+		records []map[string]string
+		err     error
+	)
+	if t := bytes.TrimSpace(data); len(t) > 0 && t[0] == '<' {
+		records, err = parseXML(data)
+	} else {
+		records, err = parseCSV(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("no TERC records found")
+	}
+	if _, ok := records[0]["SYM"]; ok {
+		return nil, ErrSIMC
+	}
+
+	rows := make([]row, 0, len(records))
+	for i, rec := range records {
+		r := row{woj: rec["WOJ"], pow: rec["POW"], gmi: rec["GMI"], rodz: rec["RODZ"], name: strings.TrimSpace(rec["NAZWA"])}
+		if len(r.woj) != 2 || r.name == "" || r.pow != "" && len(r.pow) != 2 || r.gmi != "" && len(r.gmi) != 2 {
+			return nil, fmt.Errorf("record %d: not a TERC record", i+1)
+		}
+		rows = append(rows, r)
+	}
+	return units(rows), nil
+}
+
+func units(rows []row) []municipality.TerritorialUnit {
+	voivodeships := map[string]string{}
+	counties := map[string]string{}
+	for _, r := range rows {
+		switch {
+		case r.pow == "":
+			voivodeships[r.woj] = strings.ToLower(r.name)
+		case r.gmi == "":
+			counties[r.woj+r.pow] = r.name
+		}
+	}
+
+	var out []municipality.TerritorialUnit
+	for _, r := range rows {
+		v := voivodeships[r.woj]
+		switch {
+		case r.pow == "":
+			out = append(out, municipality.TerritorialUnit{
+				ID: municipality.FlexString(r.woj + "00000"), Name: "Województwo " + v, Type: "wojewodztwo", Voivodeship: v,
+			})
+		case r.gmi == "":
+			// A city with county rights (county code from 61) is a unit
+			// only through its gmina record.
+			if cityCounty(r.pow) {
+				continue
+			}
+			out = append(out, municipality.TerritorialUnit{
+				ID: municipality.FlexString(r.woj + r.pow + "000"), Name: "Powiat " + capitalize(r.name), Type: "powiat",
+				Voivodeship: v, County: r.name,
+			})
+		case gminaKinds[r.rodz]:
+			unitType := "gmina"
+			if cityCounty(r.pow) {
+				unitType = "miasto"
+			}
+			out = append(out, municipality.TerritorialUnit{
+				ID: municipality.FlexString(r.woj + r.pow + r.gmi + r.rodz), Name: r.name, Type: unitType,
+				Voivodeship: v, County: counties[r.woj+r.pow],
+			})
+		}
+	}
+	return out
+}
+
+func cityCounty(pow string) bool {
+	return pow >= "61"
+}
+
+func capitalize(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[n:]
+}
+
+// parseXML reads <row> elements. Both the current layout, with one element
+// per column, and the older <col name="..."> layout are accepted.
+func parseXML(data []byte) ([]map[string]string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var (
+		records []map[string]string
+		current map[string]string
+		field   string
+		text    strings.Builder
+	)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid XML: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Local == "row":
+				current = map[string]string{}
+			case current != nil:
+				field = t.Name.Local
+				for _, a := range t.Attr {
+					if t.Name.Local == "col" && a.Name.Local == "name" {
+						field = a.Value
+					}
+				}
+				text.Reset()
+			}
+		case xml.CharData:
+			if field != "" {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			switch {
+			case t.Name.Local == "row" && current != nil:
+				records = append(records, current)
+				current = nil
+			case field != "":
+				current[strings.ToUpper(field)] = strings.TrimSpace(text.String())
+				field = ""
+			}
+		}
+	}
+}
+
+func parseCSV(data []byte) ([]map[string]string, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = ';'
+	if line, _, _ := bytes.Cut(data, []byte("\n")); !bytes.ContainsRune(line, ';') {
+		r.Comma = ','
+	}
+	r.FieldsPerRecord = -1
+	all, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	if len(all) < 2 {
+		return nil, nil
+	}
+	header := all[0]
+	for i := range header {
+		header[i] = strings.ToUpper(strings.TrimSpace(header[i]))
+	}
+	records := make([]map[string]string, 0, len(all)-1)
+	for _, line := range all[1:] {
+		rec := map[string]string{}
+		for i, v := range line {
+			if i < len(header) {
+				rec[header[i]] = strings.TrimSpace(v)
+			}
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
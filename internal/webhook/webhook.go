@@ -0,0 +1,269 @@
+// Package webhook delivers item lifecycle events to subscribed URLs. Every
+// event is queued per subscription in webhook_deliveries and sent by a
+// background worker; failed deliveries are retried with exponential
+// backoff and end up in the dead letters after MaxAttempts.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+	"github.com/kacperfilipiuk/zguba-gov/internal/repository"
+)
+
+const (
+	// MaxAttempts is how often delivery is tried before it is moved to
+	// the dead letters.
+	MaxAttempts = 10
+	// TestEvent is the type of the payload sent by Test.
+	TestEvent = "webhook.test"
+
+	batchSize   = 20
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 12 * time.Hour
+	timeout     = 10 * time.Second
+	keepDone    = 30 * 24 * time.Hour
+	userAgent   = "zguba-gov-webhooks/1.0"
+)
+
+// Signature headers. SignatureHeader has the form "t=<unix>,v1=<hex>",
+// where v1 is the HMAC-SHA256 of "<unix>.<body>" keyed with the
+// subscription secret.
+const (
+	SignatureHeader = "X-Zguba-Signature"
+	EventHeader     = "X-Zguba-Event"
+	DeliveryHeader  = "X-Zguba-Delivery"
+)
+
+// ErrAddressNotAllowed is returned for a receiver that resolves to a
+// loopback, private, link-local or otherwise non-public address, so that
+// subscriptions cannot be used to reach internal services.
+var ErrAddressNotAllowed = errors.New("receiver address not allowed")
+
+// blockedPrefixes are non-public ranges that net/netip does not classify:
+// "this network", shared address space (CGNAT), IETF protocol assignments,
+// benchmarking, reserved, NAT64, 6to4 and deprecated site-local addresses.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("fec0::/10"),
+}
+
+type Service struct {
+	repo   *repository.WebhookRepo
+	events *repository.EventRepo
+	client *http.Client
+}
+
+func New(repo *repository.WebhookRepo, events *repository.EventRepo) *Service {
+	return &Service{repo: repo, events: events, client: newClient()}
+}
+
+// newClient returns the client deliveries are sent with. It connects only
+// to public addresses, checked after name resolution, and does not follow
+// redirects or use a proxy, which would bypass the check.
+func newClient() *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: checkAddress}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// checkAddress is the dialer's Control function: it runs for every
+// address a connection is attempted to, after DNS resolution.
+func checkAddress(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil || !Public(ip) {
+		return fmt.Errorf("%w: %s", ErrAddressNotAllowed, host)
+	}
+	return nil
+}
+
+// Public reports whether ip is a public unicast address.
+func Public(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Enqueue queues e for every matching subscription. It is registered as an
+// events.Bus listener.
+func (s *Service) Enqueue(e model.Event) {
+	if _, err := s.repo.Enqueue(e); err != nil {
+		log.Printf("webhook: event %d: %v", e.ID, err)
+	}
+}
+
+// Deliver sends the deliveries that are due and reports how many
+// succeeded.
+func (s *Service) Deliver(ctx context.Context, now time.Time) (int, error) {
+	due, err := s.repo.Due(now, batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, d := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		status, err := s.deliver(ctx, d)
+		if err == nil {
+			if err := s.repo.MarkDelivered(d.ID, status); err != nil {
+				return delivered, err
+			}
+			delivered++
+			continue
+		}
+
+		attempts := d.Attempts + 1
+		final := attempts >= MaxAttempts
+		if err := s.repo.MarkFailed(d.ID, status, err, now.Add(Backoff(attempts)), final); err != nil {
+			return delivered, err
+		}
+		if final {
+			log.Printf("webhook: delivery %d to %s is dead after %d attempts: %v", d.ID, d.URL, attempts, err)
+		}
+	}
+	return delivered, nil
+}
+
+func (s *Service) deliver(ctx context.Context, d repository.DueDelivery) (int, error) {
+	e, err := s.events.Get(d.EventID)
+	if err != nil {
+		return 0, err
+	}
+	if e == nil {
+		return 0, fmt.Errorf("event %d no longer exists", d.EventID)
+	}
+	body, err := json.Marshal(e)
+	if err != nil {
+		return 0, err
+	}
+	return s.post(ctx, d.URL, d.Secret, e.Type, strconv.FormatInt(d.ID, 10), body)
+}
+
+// Test sends a TestEvent to the subscription right away, bypassing the
+// queue, and reports the status of the response. Nothing else about the
+// receiver's answer is reported, so the endpoint cannot be used to read
+// responses of other services.
+func (s *Service) Test(ctx context.Context, sub model.WebhookSubscription) model.WebhookTestResult {
+	body, _ := json.Marshal(model.Event{Type: TestEvent, OccurredAt: time.Now().UTC()})
+	status, err := s.post(ctx, sub.URL, sub.Secret, TestEvent, "test", body)
+	return model.WebhookTestResult{Delivered: err == nil, ResponseStatus: status}
+}
+
+// post sends a signed payload. Any 2xx response counts as delivered; the
+// returned status is 0 when no response was received. The response body is
+// discarded.
+func (s *Service) post(ctx context.Context, rawURL, secret, event, deliveryID string, body []byte) (int, error) {
+	if u, err := url.Parse(rawURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return 0, repository.ErrWebhookURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(DeliveryHeader, deliveryID)
+	req.Header.Set(SignatureHeader, Sign(secret, time.Now(), body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the SignatureHeader value for body sent at t.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Run delivers due webhooks every interval and purges old successful
+// deliveries, until ctx is cancelled.
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var lastPurge time.Time
+	for {
+		now := time.Now()
+		if _, err := s.Deliver(ctx, now); err != nil {
+			log.Printf("webhook: deliver: %v", err)
+		}
+		if now.Sub(lastPurge) >= time.Hour {
+			if _, err := s.repo.PurgeDelivered(now.Add(-keepDone)); err != nil {
+				log.Printf("webhook: purge deliveries: %v", err)
+			}
+			lastPurge = now
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Backoff is the delay before the next attempt after the given number of
+// failed attempts: thirty seconds, doubling up to twelve hours.
+func Backoff(attempts int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
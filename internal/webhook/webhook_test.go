@@ -0,0 +1,367 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kacperfilipiuk/zguba-gov/internal/database"
+	"github.com/kacperfilipiuk/zguba-gov/internal/model"
+	"github.com/kacperfilipiuk/zguba-gov/internal/repository"
+)
+
+const testSecret = "0123456789abcdef0123"
+
+// receiver is a stand-in webhook receiver. It answers with status and
+// records the requests it got, checking their signatures with testSecret.
+type receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	status   int
+	requests []received
+}
+
+type received struct {
+	event, delivery string
+	signed          bool
+	body            model.Event
+}
+
+func newReceiver(t *testing.T, status int) *receiver {
+	t.Helper()
+	r := &receiver{status: status}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		got := received{
+			event:    req.Header.Get(EventHeader),
+			delivery: req.Header.Get(DeliveryHeader),
+			signed:   verify(req.Header.Get(SignatureHeader), body),
+		}
+		_ = json.Unmarshal(body, &got.body)
+
+		r.mu.Lock()
+		r.requests = append(r.requests, got)
+		status := r.status
+		r.mu.Unlock()
+
+		if status == http.StatusFound {
+			http.Redirect(w, req, "/elsewhere", status)
+			return
+		}
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, "internal details of the receiver")
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) received() []received {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]received(nil), r.requests...)
+}
+
+func (r *receiver) answer(status int) {
+	r.mu.Lock()
+	r.status = status
+	r.mu.Unlock()
+}
+
+// verify checks a signature the way receivers are told to in the README.
+func verify(header string, body []byte) bool {
+	ts, sig, ok := strings.Cut(header, ",v1=")
+	ts, found := strings.CutPrefix(ts, "t=")
+	if !ok || !found {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(testSecret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(sig))
+}
+
+type fixture struct {
+	service  *Service
+	webhooks *repository.WebhookRepo
+	events   *repository.EventRepo
+}
+
+// newFixture returns a service on a fresh database. Its client may reach
+// the loopback address of the stand-in receiver, which the client of New
+// refuses.
+func newFixture(t *testing.T) fixture {
+	t.Helper()
+	db, err := database.Open(filepath.Join(t.TempDir(), "webhooks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	f := fixture{webhooks: repository.NewWebhookRepo(db), events: repository.NewEventRepo(db)}
+	f.service = New(f.webhooks, f.events)
+	f.service.client = &http.Client{
+		Timeout:       timeout,
+		CheckRedirect: newClient().CheckRedirect,
+	}
+	return f
+}
+
+func (f fixture) subscribe(t *testing.T, url string) *model.WebhookSubscription {
+	t.Helper()
+	sub, err := f.webhooks.Create(model.WebhookSubscriptionCreate{
+		URL:    url,
+		Events: []string{model.EventItemCreated},
+		Secret: testSecret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sub
+}
+
+// publish appends an item.created event and queues its deliveries.
+func (f fixture) publish(t *testing.T) model.Event {
+	t.Helper()
+	e := model.Event{Type: model.EventItemCreated, ItemID: "item-1", Data: &model.FoundItemResponse{ID: "item-1"}}
+	if err := f.events.Append(&e); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := f.webhooks.Enqueue(e); err != nil || n != 1 {
+		t.Fatalf("Enqueue = %d, %v; want 1 delivery", n, err)
+	}
+	return e
+}
+
+func (f fixture) delivery(t *testing.T) model.WebhookDelivery {
+	t.Helper()
+	deliveries, err := f.webhooks.ListDeliveries("", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(deliveries))
+	}
+	return deliveries[0]
+}
+
+func TestDeliverSignsEvent(t *testing.T) {
+	f := newFixture(t)
+	rcv := newReceiver(t, http.StatusOK)
+	f.subscribe(t, rcv.URL)
+	e := f.publish(t)
+
+	n, err := f.service.Deliver(context.Background(), time.Now())
+	if err != nil || n != 1 {
+		t.Fatalf("Deliver = %d, %v; want 1", n, err)
+	}
+
+	got := rcv.received()
+	if len(got) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(got))
+	}
+	d := f.delivery(t)
+	switch {
+	case !got[0].signed:
+		t.Error("signature does not verify with the subscription secret")
+	case got[0].event != model.EventItemCreated:
+		t.Errorf("%s = %q, want %q", EventHeader, got[0].event, model.EventItemCreated)
+	case got[0].delivery != jsonID(d.ID):
+		t.Errorf("%s = %q, want %d", DeliveryHeader, got[0].delivery, d.ID)
+	case got[0].body.ID != e.ID || got[0].body.ItemID != "item-1":
+		t.Errorf("body = %+v, want event %d of item-1", got[0].body, e.ID)
+	}
+	if d.Status != model.DeliveryDelivered || d.ResponseStatus != http.StatusOK {
+		t.Errorf("delivery = %s (HTTP %d), want delivered (HTTP 200)", d.Status, d.ResponseStatus)
+	}
+}
+
+func jsonID(id int64) string {
+	b, _ := json.Marshal(id)
+	return string(b)
+}
+
+func TestDeliverRetries(t *testing.T) {
+	tests := []struct {
+		name string
+		// failed is the number of attempts that failed before.
+		failed       int
+		status       int
+		wantStatus   string
+		wantAttempts int
+		wantError    string
+	}{
+		{"success", 0, http.StatusNoContent, model.DeliveryDelivered, 1, ""},
+		{"server error is retried", 0, http.StatusInternalServerError, model.DeliveryPending, 1, "HTTP 500"},
+		{"redirect is not followed", 0, http.StatusFound, model.DeliveryPending, 1, "HTTP 302"},
+		{"success after failures", MaxAttempts - 1, http.StatusOK, model.DeliveryDelivered, MaxAttempts, ""},
+		{"last attempt goes to the dead letters", MaxAttempts - 1, http.StatusServiceUnavailable, model.DeliveryDead, MaxAttempts, "HTTP 503"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			rcv := newReceiver(t, tt.status)
+			f.subscribe(t, rcv.URL)
+			f.publish(t)
+
+			now := time.Now()
+			id := f.delivery(t).ID
+			for range tt.failed {
+				if err := f.webhooks.MarkFailed(id, 500, errors.New("HTTP 500"), now.Add(-time.Second), false); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if _, err := f.service.Deliver(context.Background(), now); err != nil {
+				t.Fatal(err)
+			}
+			d := f.delivery(t)
+			if d.Status != tt.wantStatus || d.Attempts != tt.wantAttempts {
+				t.Errorf("delivery = %s after %d attempts, want %s after %d", d.Status, d.Attempts, tt.wantStatus, tt.wantAttempts)
+			}
+			if d.LastError != tt.wantError {
+				t.Errorf("last error = %q, want %q", d.LastError, tt.wantError)
+			}
+			if tt.wantStatus == model.DeliveryPending {
+				want := now.Add(Backoff(tt.wantAttempts))
+				if d.NextAttemptAt.Sub(want).Abs() > time.Second {
+					t.Errorf("next attempt at %v, want %v", d.NextAttemptAt, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryDeadLetter(t *testing.T) {
+	f := newFixture(t)
+	rcv := newReceiver(t, http.StatusInternalServerError)
+	f.subscribe(t, rcv.URL)
+	f.publish(t)
+
+	now := time.Now()
+	id := f.delivery(t).ID
+	for range MaxAttempts - 1 {
+		if err := f.webhooks.MarkFailed(id, 500, errors.New("HTTP 500"), now.Add(-time.Second), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := f.service.Deliver(context.Background(), now); err != nil {
+		t.Fatal(err)
+	}
+	if d := f.delivery(t); d.Status != model.DeliveryDead {
+		t.Fatalf("delivery = %s, want dead", d.Status)
+	}
+
+	// Dead deliveries are not sent until retried by hand.
+	if n, err := f.service.Deliver(context.Background(), now.Add(maxBackoff)); err != nil || n != 0 {
+		t.Fatalf("Deliver of dead letters = %d, %v; want 0", n, err)
+	}
+	if err := f.webhooks.Retry(id); err != nil {
+		t.Fatal(err)
+	}
+	rcv.answer(http.StatusOK)
+	if n, err := f.service.Deliver(context.Background(), time.Now()); err != nil || n != 1 {
+		t.Fatalf("Deliver after retry = %d, %v; want 1", n, err)
+	}
+	if d := f.delivery(t); d.Status != model.DeliveryDelivered || d.Attempts != 1 {
+		t.Errorf("delivery = %s after %d attempts, want delivered after 1", d.Status, d.Attempts)
+	}
+}
+
+func TestTestReportsOnlyStatus(t *testing.T) {
+	f := newFixture(t)
+	rcv := newReceiver(t, http.StatusInternalServerError)
+	sub := f.subscribe(t, rcv.URL)
+
+	res := f.service.Test(context.Background(), *sub)
+	if res != (model.WebhookTestResult{ResponseStatus: http.StatusInternalServerError}) {
+		t.Errorf("Test = %+v, want only HTTP 500", res)
+	}
+	got := rcv.received()
+	if len(got) != 1 || got[0].event != TestEvent || !got[0].signed {
+		t.Errorf("receiver got %+v, want one signed %s", got, TestEvent)
+	}
+}
+
+func TestClientRefusesInternalAddresses(t *testing.T) {
+	f := newFixture(t)
+	f.service.client = newClient()
+	rcv := newReceiver(t, http.StatusOK)
+	sub := f.subscribe(t, rcv.URL)
+
+	status, err := f.service.post(context.Background(), sub.URL, testSecret, TestEvent, "test", []byte("{}"))
+	if !errors.Is(err, ErrAddressNotAllowed) || status != 0 {
+		t.Errorf("post to %s = %d, %v; want %v", sub.URL, status, err, ErrAddressNotAllowed)
+	}
+	if res := f.service.Test(context.Background(), *sub); res != (model.WebhookTestResult{}) {
+		t.Errorf("Test = %+v, want nothing delivered", res)
+	}
+	if got := rcv.received(); len(got) != 0 {
+		t.Errorf("receiver on loopback got %d requests", len(got))
+	}
+
+	if _, err := f.service.post(context.Background(), "file:///etc/passwd", testSecret, TestEvent, "test", nil); !errors.Is(err, repository.ErrWebhookURL) {
+		t.Errorf("post to a file URL: %v, want %v", err, repository.ErrWebhookURL)
+	}
+}
+
+func TestPublic(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"2001:4860:4860::8888", true},
+		{"::ffff:8.8.8.8", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"fd00::1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"100.64.0.1", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"64:ff9b::a00:1", false},
+	}
+	for _, tt := range tests {
+		if got := Public(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("Public(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{5, 8 * time.Minute},
+		{MaxAttempts, 256 * time.Minute},
+		{100, maxBackoff},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
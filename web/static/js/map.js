@@ -0,0 +1,378 @@
+// Map of found items. A small Web Mercator map: tiles come from the
+// configured tile server (self-hosted by default, so the map works offline)
+// and items from the clusters endpoint, which groups them per zoom level.
+(function () {
+    'use strict';
+
+    var TILE = 256;
+    var MIN_ZOOM = 3;
+    var MAX_LAT = 85.0511;
+
+    var el = document.getElementById('map');
+    if (!el) return;
+    var form = document.getElementById('map-filters');
+    var tilesURL = el.dataset.tiles;
+    var maxZoom = parseInt(el.dataset.maxZoom, 10) || 18;
+    var messages = JSON.parse(el.dataset.messages || '{}');
+
+    // The middle of Poland, or the view saved in the URL as #zoom/lat/lon.
+    var view = { lat: 52.0, lon: 19.4, zoom: 6 };
+    var saved = location.hash.slice(1).split('/').map(parseFloat);
+    if (saved.length === 3 && saved.every(isFinite)) {
+        view = { zoom: clamp(Math.round(saved[0]), MIN_ZOOM, maxZoom), lat: clamp(saved[1], -MAX_LAT, MAX_LAT), lon: clamp(saved[2], -180, 180) };
+    }
+
+    el.innerHTML = '';
+    el.tabIndex = 0;
+    var tileLayer = layer('map-tiles');
+    var markerLayer = layer('map-markers');
+    var popup = layer('map-popup');
+    popup.hidden = true;
+    var status = layer('map-status');
+    status.setAttribute('role', 'status');
+
+    var controls = layer('map-controls');
+    button(controls, 'map-zoom', '+', t('zoom_in'), function () { zoomTo(view.zoom + 1); });
+    button(controls, 'map-zoom', '−', t('zoom_out'), function () { zoomTo(view.zoom - 1); });
+
+    var attribution = layer('map-attribution');
+    attribution.textContent = el.dataset.attribution || '';
+
+    var tiles = {};
+    var features = [];
+    var popupAt = null;
+    var request = null;
+    var loadTimer = null;
+
+    function layer(className) {
+        var div = document.createElement('div');
+        div.className = className;
+        el.appendChild(div);
+        return div;
+    }
+
+    function button(parent, className, text, label, onClick) {
+        var b = document.createElement('button');
+        b.type = 'button';
+        b.className = className;
+        b.textContent = text;
+        b.setAttribute('aria-label', label);
+        b.addEventListener('click', onClick);
+        parent.appendChild(b);
+        return b;
+    }
+
+    function clamp(v, lo, hi) {
+        return Math.max(lo, Math.min(hi, v));
+    }
+
+    function project(lat, lon, zoom) {
+        var size = TILE * Math.pow(2, zoom);
+        var sin = Math.sin(clamp(lat, -MAX_LAT, MAX_LAT) * Math.PI / 180);
+        return {
+            x: (lon + 180) / 360 * size,
+            y: (0.5 - Math.log((1 + sin) / (1 - sin)) / (4 * Math.PI)) * size
+        };
+    }
+
+    function unproject(x, y, zoom) {
+        var size = TILE * Math.pow(2, zoom);
+        var n = Math.PI - 2 * Math.PI * y / size;
+        return {
+            lat: clamp(180 / Math.PI * Math.atan(Math.sinh(n)), -MAX_LAT, MAX_LAT),
+            lon: x / size * 360 - 180
+        };
+    }
+
+    // origin is the world pixel at the top left corner of the map.
+    function origin() {
+        var c = project(view.lat, view.lon, view.zoom);
+        return { x: c.x - el.clientWidth / 2, y: c.y - el.clientHeight / 2 };
+    }
+
+    function toScreen(lat, lon) {
+        var o = origin();
+        var p = project(lat, lon, view.zoom);
+        return { x: p.x - o.x, y: p.y - o.y };
+    }
+
+    function renderTiles() {
+        if (!tilesURL) return;
+        var o = origin();
+        var n = Math.pow(2, view.zoom);
+        var wanted = {};
+        for (var x = Math.floor(o.x / TILE); x <= Math.floor((o.x + el.clientWidth) / TILE); x++) {
+            for (var y = Math.floor(o.y / TILE); y <= Math.floor((o.y + el.clientHeight) / TILE); y++) {
+                if (y < 0 || y >= n) continue;
+                var tx = ((x % n) + n) % n;
+                var key = view.zoom + '/' + x + '/' + y;
+                var img = tiles[key];
+                if (!img) {
+                    img = document.createElement('img');
+                    img.alt = '';
+                    img.draggable = false;
+                    img.onerror = function () { this.style.visibility = 'hidden'; };
+                    img.src = tilesURL.replace('{z}', view.zoom).replace('{x}', tx).replace('{y}', y);
+                    tiles[key] = img;
+                    tileLayer.appendChild(img);
+                }
+                img.style.left = (x * TILE - o.x) + 'px';
+                img.style.top = (y * TILE - o.y) + 'px';
+                wanted[key] = true;
+            }
+        }
+        Object.keys(tiles).forEach(function (key) {
+            if (!wanted[key]) {
+                tileLayer.removeChild(tiles[key]);
+                delete tiles[key];
+            }
+        });
+    }
+
+    function renderMarkers() {
+        features.forEach(function (f) {
+            var p = toScreen(f.geometry.coordinates[1], f.geometry.coordinates[0]);
+            f.marker.style.transform = 'translate(' + p.x + 'px,' + p.y + 'px)';
+        });
+        if (popupAt) {
+            var p = toScreen(popupAt.lat, popupAt.lon);
+            popup.style.transform = 'translate(' + p.x + 'px,' + p.y + 'px)';
+        }
+    }
+
+    function render() {
+        renderTiles();
+        renderMarkers();
+    }
+
+    function filters() {
+        var q = new URLSearchParams();
+        if (!form) return q;
+        var map = { kategoria: 'category', od: 'dateFrom', do: 'dateTo' };
+        Object.keys(map).forEach(function (name) {
+            var v = form.elements[name] && form.elements[name].value;
+            if (v) q.set(map[name], v);
+        });
+        return q;
+    }
+
+    function bbox() {
+        var o = origin();
+        var nw = unproject(o.x, o.y, view.zoom);
+        var se = unproject(o.x + el.clientWidth, o.y + el.clientHeight, view.zoom);
+        return [clamp(nw.lon, -180, 180), se.lat, clamp(se.lon, -180, 180), nw.lat].map(function (v) { return v.toFixed(5); }).join(',');
+    }
+
+    function scheduleLoad() {
+        clearTimeout(loadTimer);
+        loadTimer = setTimeout(load, 200);
+        history.replaceState(null, '', location.pathname + location.search + '#' + view.zoom + '/' + view.lat.toFixed(4) + '/' + view.lon.toFixed(4));
+    }
+
+    function load() {
+        var q = filters();
+        q.set('zoom', view.zoom);
+        q.set('bbox', bbox());
+        if (request) request.abort();
+        request = new AbortController();
+        status.textContent = t('loading');
+        fetch(el.dataset.endpoint + '?' + q, { signal: request.signal })
+            .then(function (r) {
+                if (!r.ok) throw new Error(r.status);
+                return r.json();
+            })
+            .then(function (data) {
+                show(data.features);
+                var total = data.features.reduce(function (n, f) { return n + f.properties.count; }, 0);
+                status.textContent = total === 0 ? t('empty') : t('count', total);
+            })
+            .catch(function (err) {
+                if (err.name !== 'AbortError') status.textContent = t('failed');
+            });
+    }
+
+    function show(list) {
+        markerLayer.innerHTML = '';
+        features = list;
+        features.forEach(function (f) {
+            var p = f.properties;
+            var b;
+            if (p.cluster) {
+                b = button(markerLayer, 'map-cluster' + (p.count >= 100 ? ' map-cluster-large' : p.count >= 10 ? ' map-cluster-medium' : ''),
+                    String(p.count), t('group', p.count), function () { openCluster(f); });
+            } else {
+                b = button(markerLayer, 'map-marker', '', p.name, function () { openItem(f); });
+            }
+            f.marker = b;
+        });
+        renderMarkers();
+    }
+
+    function at(f) {
+        return { lat: f.geometry.coordinates[1], lon: f.geometry.coordinates[0] };
+    }
+
+    function openCluster(f) {
+        // Items at one position stay together at any zoom; list them.
+        if (!f.properties.stacked && view.zoom < maxZoom) {
+            zoomAround(toScreen(at(f).lat, at(f).lon), Math.min(view.zoom + 2, maxZoom));
+            return;
+        }
+        var pos = at(f);
+        var q = filters();
+        var d = 0.00001;
+        q.set('bbox', [pos.lon - d, pos.lat - d, pos.lon + d, pos.lat + d].join(','));
+        fetch(el.dataset.items + '?' + q)
+            .then(function (r) { return r.json(); })
+            .then(function (data) {
+                var list = document.createElement('ul');
+                data.features.forEach(function (item) {
+                    var li = document.createElement('li');
+                    li.appendChild(itemLink(item.properties.item.name, '/rzeczy/' + item.id));
+                    li.appendChild(document.createTextNode(' – ' + item.properties.item.date));
+                    list.appendChild(li);
+                });
+                openPopup(pos, [heading(t('stacked', f.properties.count)), list]);
+            });
+    }
+
+    function openItem(f) {
+        var p = f.properties;
+        var details = document.createElement('p');
+        details.textContent = t('found', p.categoryLabel || p.category, p.date);
+        var more = itemLink(t('more'), p.url);
+        more.className = 'map-popup-more';
+        openPopup(at(f), [heading(p.name), details, more]);
+    }
+
+    // t returns the message of the page language, with %d and %s replaced
+    // by the arguments in turn.
+    function t(key) {
+        var args = Array.prototype.slice.call(arguments, 1);
+        return (messages[key] || key).replace(/%[ds]/g, function () { return String(args.shift()); });
+    }
+
+    function heading(text) {
+        var h = document.createElement('strong');
+        h.textContent = text;
+        return h;
+    }
+
+    function itemLink(text, href) {
+        var a = document.createElement('a');
+        a.href = href;
+        a.textContent = text;
+        return a;
+    }
+
+    function openPopup(pos, nodes) {
+        popup.innerHTML = '';
+        var box = document.createElement('div');
+        box.className = 'map-popup-box';
+        button(box, 'map-popup-close', '×', t('close'), closePopup);
+        nodes.forEach(function (n) { box.appendChild(n); });
+        popup.appendChild(box);
+        popup.hidden = false;
+        popupAt = pos;
+        renderMarkers();
+    }
+
+    function closePopup() {
+        popup.hidden = true;
+        popupAt = null;
+    }
+
+    // zoomAround changes the zoom keeping the position under the screen
+    // point p in place.
+    function zoomAround(p, zoom) {
+        zoom = clamp(zoom, MIN_ZOOM, maxZoom);
+        if (zoom === view.zoom) return;
+        var o = origin();
+        var pos = unproject(o.x + p.x, o.y + p.y, view.zoom);
+        var q = project(pos.lat, pos.lon, zoom);
+        var c = unproject(q.x - p.x + el.clientWidth / 2, q.y - p.y + el.clientHeight / 2, zoom);
+        view = { lat: c.lat, lon: c.lon, zoom: zoom };
+        render();
+        scheduleLoad();
+    }
+
+    function zoomTo(zoom) {
+        zoomAround({ x: el.clientWidth / 2, y: el.clientHeight / 2 }, zoom);
+    }
+
+    function panBy(dx, dy) {
+        var c = project(view.lat, view.lon, view.zoom);
+        var pos = unproject(c.x + dx, c.y + dy, view.zoom);
+        view.lat = pos.lat;
+        view.lon = ((pos.lon + 540) % 360) - 180;
+        render();
+    }
+
+    var drag = null;
+    el.addEventListener('pointerdown', function (e) {
+        if (e.button !== 0 || e.target.closest('button, a, .map-popup')) return;
+        drag = { x: e.clientX, y: e.clientY, moved: false };
+        el.setPointerCapture(e.pointerId);
+        el.classList.add('map-dragging');
+    });
+    el.addEventListener('pointermove', function (e) {
+        if (!drag) return;
+        panBy(drag.x - e.clientX, drag.y - e.clientY);
+        drag = { x: e.clientX, y: e.clientY, moved: true };
+    });
+    function endDrag() {
+        if (!drag) return;
+        if (drag.moved) scheduleLoad();
+        drag = null;
+        el.classList.remove('map-dragging');
+    }
+    el.addEventListener('pointerup', endDrag);
+    el.addEventListener('pointercancel', endDrag);
+
+    el.addEventListener('wheel', function (e) {
+        e.preventDefault();
+        var r = el.getBoundingClientRect();
+        zoomAround({ x: e.clientX - r.left, y: e.clientY - r.top }, view.zoom + (e.deltaY < 0 ? 1 : -1));
+    }, { passive: false });
+
+    el.addEventListener('dblclick', function (e) {
+        if (e.target.closest('button, a, .map-popup')) return;
+        var r = el.getBoundingClientRect();
+        zoomAround({ x: e.clientX - r.left, y: e.clientY - r.top }, view.zoom + 1);
+    });
+
+    el.addEventListener('keydown', function (e) {
+        var step = 80;
+        var moves = { ArrowLeft: [-step, 0], ArrowRight: [step, 0], ArrowUp: [0, -step], ArrowDown: [0, step] };
+        if (moves[e.key]) {
+            panBy(moves[e.key][0], moves[e.key][1]);
+            scheduleLoad();
+        } else if (e.key === '+' || e.key === '=') {
+            zoomTo(view.zoom + 1);
+        } else if (e.key === '-') {
+            zoomTo(view.zoom - 1);
+        } else if (e.key === 'Escape') {
+            closePopup();
+        } else {
+            return;
+        }
+        e.preventDefault();
+    });
+
+    if (form) {
+        var applyFilters = function (e) {
+            if (e) e.preventDefault();
+            var q = new URLSearchParams(new FormData(form));
+            Array.from(q.keys()).forEach(function (k) { if (!q.get(k)) q.delete(k); });
+            history.replaceState(null, '', location.pathname + (q.toString() ? '?' + q : '') + location.hash);
+            closePopup();
+            load();
+        };
+        form.addEventListener('submit', applyFilters);
+        form.addEventListener('change', function () { applyFilters(); });
+    }
+
+    window.addEventListener('resize', render);
+    render();
+    load();
+})();